@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/blang/semver"
@@ -14,7 +15,7 @@ func selfUpdate(slug string) error {
 	selfupdate.EnableLog()
 
 	previous := semver.MustParse(version)
-	latest, err := selfupdate.UpdateSelf(previous, slug)
+	latest, err := selfupdate.UpdateSelf(context.Background(), previous, slug)
 	if err != nil {
 		return err
 	}
@@ -29,7 +30,7 @@ func selfUpdate(slug string) error {
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "Usage: selfupdate-example [flags]\n")
+	fmt.Fprint(os.Stderr, "Usage: selfupdate-example [flags]\n\n")
 	flag.PrintDefaults()
 }
 
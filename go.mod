@@ -1,18 +1,34 @@
 module github.com/rhysd/go-github-selfupdate
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/bodgit/sevenzip v1.2.0
 	github.com/google/go-github/v30 v30.1.0
 	github.com/inconshreveable/go-update v0.0.0-20160112193335-8152e7eb6ccf
-	github.com/kr/pretty v0.1.0 // indirect
-	github.com/onsi/gomega v1.4.2 // indirect
+	github.com/klauspost/compress v1.11.13
 	github.com/tcnksm/go-gitconfig v0.1.2
-	github.com/ulikunitz/xz v0.5.9
-	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad // indirect
-	golang.org/x/oauth2 v0.0.0-20181106182150-f42d05182288
-	golang.org/x/text v0.3.5 // indirect
-	google.golang.org/appengine v1.3.0 // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	github.com/ulikunitz/xz v0.5.10
+	golang.org/x/crypto v0.17.0
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
+)
+
+require (
+	github.com/bodgit/plumbing v1.1.1 // indirect
+	github.com/bodgit/windows v1.0.0 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	github.com/connesc/cipherio v0.2.1 // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/google/go-querystring v1.0.0 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/onsi/gomega v1.4.2 // indirect
+	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
+	golang.org/x/net v0.10.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/appengine v1.6.5 // indirect
 )
 
-go 1.13
+go 1.18
@@ -0,0 +1,208 @@
+package selfupdate
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// nthBackupPath returns the path of the n-th newest backup whose newest one (n = 0) is 'old'. Older ones are suffixed
+// with their numbers such as '.<cmd>.old.1'.
+func nthBackupPath(old string, n int) string {
+	if n == 0 {
+		return old
+	}
+	return fmt.Sprintf("%s.%d", old, n)
+}
+
+// backupPathOf returns the path where the previous binary is kept after updating the binary at cmdPath. It is
+// backupPath(cmdPath) unless Config.BackupDir is set.
+func (up *Updater) backupPathOf(cmdPath string) string {
+	if up.backupDir == "" {
+		return backupPath(cmdPath)
+	}
+	return filepath.Join(up.backupDir, "."+filepath.Base(cmdPath)+".old")
+}
+
+// DefaultBackupDir returns the directory for backups of the application following the platform conventions such as
+// '$XDG_CACHE_HOME/<app>/backups' on Linux and '%LocalAppData%\<app>\backups' on Windows. It can be set to
+// Config.BackupDir.
+func DefaultBackupDir(app string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to determine backup directory for %s: %s", app, err)
+	}
+	return filepath.Join(dir, app, "backups"), nil
+}
+
+// copyFile copies the regular file at src to dst durably. The copy is synced to disk before it is put at dst by
+// atomic rename so that dst is never partially written.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s to copy: %s", src, err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("Failed to stat %s to copy: %s", src, err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file to copy %s: %s", src, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed to copy %s to %s: %s", src, tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed to sync %s: %s", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Failed to copy %s to %s: %s", src, tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("Failed to set permissions of %s: %s", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("Failed to put copy of %s at %s: %s", src, dst, err)
+	}
+	return nil
+}
+
+// moveFile moves the file at src to dst. It is an atomic rename when both are on the same filesystem. Otherwise the
+// file is copied durably and src is removed. A symbolic link is moved as a link to the same target.
+func moveFile(src, dst string) error {
+	if sameFilesystem(filepath.Dir(src), filepath.Dir(dst)) {
+		return os.Rename(src, dst)
+	}
+	if isSymlink(src) {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return fmt.Errorf("Failed to read symlink %s: %s", src, err)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(src), target)
+		}
+		link := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".new")
+		os.Remove(link)
+		if err := os.Symlink(target, link); err != nil {
+			return fmt.Errorf("Failed to create symlink to %s: %s", target, err)
+		}
+		if err := os.Rename(link, dst); err != nil {
+			os.Remove(link)
+			return fmt.Errorf("Failed to put symlink at %s: %s", dst, err)
+		}
+	} else if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// removeBackup removes the backup at path. When the backup is a symbolic link kept by SymlinkVersioned, the versioned
+// binary it points to is also removed unless cmdPath still points to it.
+func removeBackup(path, cmdPath string) error {
+	if isSymlink(path) {
+		target, terr := filepath.EvalSymlinks(path)
+		current, cerr := filepath.EvalSymlinks(cmdPath)
+		if terr == nil && (cerr != nil || target != current) {
+			if err := os.Remove(target); err != nil {
+				return fmt.Errorf("Failed to remove old binary %s: %s", target, err)
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove backup %s: %s", path, err)
+	}
+	return nil
+}
+
+// rotateBackups shifts the backups of cmdPath whose newest one is 'old' to older numbers so that the backup made by
+// the next update does not overwrite the previous one. At most 'keep' backups are retained and the oldest one is
+// removed. When keep is 1 or less, nothing is done since the next update simply replaces the single backup.
+func rotateBackups(old, cmdPath string, keep int) error {
+	if keep <= 1 || !exists(old) {
+		return nil
+	}
+	if oldest := nthBackupPath(old, keep-1); exists(oldest) {
+		if err := removeBackup(oldest, cmdPath); err != nil {
+			return err
+		}
+	}
+	for n := keep - 2; n >= 0; n-- {
+		p := nthBackupPath(old, n)
+		if !exists(p) {
+			continue
+		}
+		if err := os.Rename(p, nthBackupPath(old, n+1)); err != nil {
+			return fmt.Errorf("Failed to rotate backup %s: %s", p, err)
+		}
+	}
+	return nil
+}
+
+// shiftBackups moves the older backups to newer numbers when the newest backup 'old' is missing, such as after a
+// rollback consumed it or a failed update did not make it. It is the reverse of rotateBackups.
+func shiftBackups(old string) {
+	if exists(old) {
+		return
+	}
+	for n := 1; exists(nthBackupPath(old, n)); n++ {
+		if err := os.Rename(nthBackupPath(old, n), nthBackupPath(old, n-1)); err != nil {
+			return
+		}
+	}
+}
+
+// isBackupOf returns whether the file name is a backup of the command such as '.foo.old' or '.foo.old.2'.
+func isBackupOf(name, cmd string) bool {
+	prefix := fmt.Sprintf(".%s.old", cmd)
+	if name == prefix {
+		return true
+	}
+	if !strings.HasPrefix(name, prefix+".") {
+		return false
+	}
+	_, err := strconv.Atoi(name[len(prefix)+1:])
+	return err == nil
+}
+
+// CleanupBackups removes all backups of cmdPath kept by previous updates, including the versioned binaries which
+// are no longer pointed by cmdPath when SymlinkVersioned is used. After the cleanup, the update cannot be rolled back.
+// Backups are looked up in Config.BackupDir when it is set.
+func (up *Updater) CleanupBackups(cmdPath string) error {
+	dir, cmd := filepath.Split(cmdPath)
+	if up.backupDir != "" {
+		dir = up.backupDir
+	}
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("Failed to read directory %s to clean up backups: %s", dir, err)
+	}
+	for _, e := range entries {
+		if !isBackupOf(e.Name(), cmd) {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		if err := removeBackup(p, cmdPath); err != nil {
+			return err
+		}
+		up.infof("Removed backup %s", p)
+	}
+	return nil
+}
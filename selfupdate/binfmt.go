@@ -0,0 +1,103 @@
+package selfupdate
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+)
+
+var elfMachines = map[string]elf.Machine{
+	"386":      elf.EM_386,
+	"amd64":    elf.EM_X86_64,
+	"arm":      elf.EM_ARM,
+	"arm64":    elf.EM_AARCH64,
+	"mips":     elf.EM_MIPS,
+	"mipsle":   elf.EM_MIPS,
+	"mips64":   elf.EM_MIPS,
+	"mips64le": elf.EM_MIPS,
+	"ppc64":    elf.EM_PPC64,
+	"ppc64le":  elf.EM_PPC64,
+	"riscv64":  elf.EM_RISCV,
+	"s390x":    elf.EM_S390,
+}
+
+var machoCpus = map[string]macho.Cpu{
+	"386":   macho.Cpu386,
+	"amd64": macho.CpuAmd64,
+	"arm":   macho.CpuArm,
+	"arm64": macho.CpuArm64,
+}
+
+var peMachines = map[string]uint16{
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"arm":   pe.IMAGE_FILE_MACHINE_ARMNT,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+}
+
+// verifyBinaryFormat checks that the file at path is an executable for the OS and arch by parsing its header. ELF
+// is expected on Linux and other Unix-like systems, Mach-O on macOS and PE on Windows. When the arch is unknown
+// to this function, only the executable format is checked.
+func verifyBinaryFormat(path, goos, goarch string) error {
+	switch goos {
+	case "darwin", "ios":
+		return verifyMachO(path, goarch)
+	case "windows":
+		return verifyPE(path, goarch)
+	case "plan9", "js", "wasip1":
+		return nil
+	}
+	return verifyELF(path, goarch)
+}
+
+func verifyELF(path, goarch string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("Downloaded file is not an ELF executable: %s", err)
+	}
+	defer f.Close()
+	if want, ok := elfMachines[goarch]; ok && f.Machine != want {
+		return fmt.Errorf("Downloaded executable is built for machine %s but %s is expected for %s", f.Machine, want, goarch)
+	}
+	return nil
+}
+
+func verifyMachO(path, goarch string) error {
+	want, known := machoCpus[goarch]
+	if f, err := macho.OpenFat(path); err == nil {
+		// Universal binary contains executables for several archs
+		defer f.Close()
+		if !known {
+			return nil
+		}
+		for _, a := range f.Arches {
+			if a.Cpu == want {
+				return nil
+			}
+		}
+		return fmt.Errorf("Downloaded universal binary does not contain an executable for %s", goarch)
+	}
+
+	f, err := macho.Open(path)
+	if err != nil {
+		return fmt.Errorf("Downloaded file is not a Mach-O executable: %s", err)
+	}
+	defer f.Close()
+	if known && f.Cpu != want {
+		return fmt.Errorf("Downloaded executable is built for CPU %s but %s is expected for %s", f.Cpu, want, goarch)
+	}
+	return nil
+}
+
+func verifyPE(path, goarch string) error {
+	f, err := pe.Open(path)
+	if err != nil {
+		return fmt.Errorf("Downloaded file is not a PE executable: %s", err)
+	}
+	defer f.Close()
+	if want, ok := peMachines[goarch]; ok && f.Machine != want {
+		return fmt.Errorf("Downloaded executable is built for machine %#x but %#x is expected for %s", f.Machine, want, goarch)
+	}
+	return nil
+}
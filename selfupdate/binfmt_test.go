@@ -0,0 +1,53 @@
+package selfupdate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBinaryFormat(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyBinaryFormat(exe, runtime.GOOS, runtime.GOARCH); err != nil {
+		t.Fatal("Test binary should be verified:", err)
+	}
+
+	other := "arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "amd64"
+	}
+	err = verifyBinaryFormat(exe, runtime.GOOS, other)
+	if err == nil || !strings.Contains(err.Error(), other) {
+		t.Error("Executable for other arch should be rejected:", err)
+	}
+
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+	if err := verifyBinaryFormat(exe, otherOS, runtime.GOARCH); err == nil {
+		t.Error("Executable for other OS should be rejected")
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	readme := filepath.Join(dir, "README.md")
+	if err := ioutil.WriteFile(readme, []byte("# This is not an executable\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		if err := verifyBinaryFormat(readme, goos, "amd64"); err == nil {
+			t.Error("Text file should be rejected on", goos)
+		}
+	}
+}
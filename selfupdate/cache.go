@@ -0,0 +1,37 @@
+package selfupdate
+
+import (
+	"sync"
+
+	"github.com/google/go-github/v30/github"
+)
+
+type releaseCacheEntry struct {
+	etag     string
+	releases []*github.RepositoryRelease
+	nextPage int
+}
+
+// releaseCache is an in-memory cache of releases fetched from GitHub API. Each entry is stored with the ETag
+// of the response so that the next request can be a conditional request. It is safe for concurrent use.
+type releaseCache struct {
+	mu      sync.Mutex
+	entries map[string]releaseCacheEntry
+}
+
+func newReleaseCache() *releaseCache {
+	return &releaseCache{entries: map[string]releaseCacheEntry{}}
+}
+
+func (c *releaseCache) get(key string) (releaseCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *releaseCache) set(key, etag string, releases []*github.RepositoryRelease, nextPage int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = releaseCacheEntry{etag, releases, nextPage}
+}
@@ -0,0 +1,54 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheReleasesWithETag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var requests, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": "foo_%s_%s.tar.gz"}]}]`, runtime.GOOS, runtime.GOARCH)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, CacheReleases: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		rels, err := up.DetectVersions(ctx, "foo/bar", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rels) != 1 || rels[0].Version.String() != "1.2.3" {
+			t.Fatal("Unexpected releases:", rels)
+		}
+	}
+	if requests != 3 {
+		t.Error("API should be called for each detection but called", requests, "times")
+	}
+	if notModified != 2 {
+		t.Error("Conditional requests should be sent after the first one but sent", notModified, "times")
+	}
+}
@@ -0,0 +1,216 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blang/semver"
+)
+
+// checkCacheRelease is the release recorded in the check cache file. It keeps the fields needed to update with the
+// release without calling GitHub API again.
+type checkCacheRelease struct {
+	Version           string      `json:"version"`
+	AssetURL          string      `json:"asset_url"`
+	AssetSize         int         `json:"asset_size"`
+	AssetID           int64       `json:"asset_id"`
+	AssetName         string      `json:"asset_name,omitempty"`
+	ValidationAssetID int64       `json:"validation_asset_id"`
+	ValidatorSuffix   string      `json:"validator_suffix,omitempty"`
+	URL               string      `json:"url,omitempty"`
+	ReleaseNotes      string      `json:"release_notes,omitempty"`
+	Name              string      `json:"name,omitempty"`
+	PublishedAt       *time.Time  `json:"published_at,omitempty"`
+	Prerelease        bool        `json:"prerelease"`
+	Draft             bool        `json:"draft"`
+	RepoOwner         string      `json:"repo_owner"`
+	RepoName          string      `json:"repo_name"`
+	Format            AssetFormat `json:"format,omitempty"`
+	AssetPartIDs      []int64     `json:"asset_part_ids,omitempty"`
+}
+
+// checkCacheRecord is the result of the last check of a repository. Release is nil when no release was found on the
+// check.
+type checkCacheRecord struct {
+	CheckedAt time.Time          `json:"checked_at"`
+	Release   *checkCacheRelease `json:"release"`
+}
+
+// checkCache is the content of the check cache file. Records are keyed by the slug of the repository so that
+// an updater checking several repositories has a record for each of them.
+type checkCache struct {
+	Repos map[string]*checkCacheRecord `json:"repos"`
+}
+
+func newCheckCacheRelease(rel *Release) *checkCacheRelease {
+	if rel == nil {
+		return nil
+	}
+	c := &checkCacheRelease{
+		Version:           rel.Version.String(),
+		AssetURL:          rel.AssetURL,
+		AssetSize:         rel.AssetByteSize,
+		AssetID:           rel.AssetID,
+		AssetName:         rel.AssetName,
+		ValidationAssetID: rel.ValidationAssetID,
+		URL:               rel.URL,
+		ReleaseNotes:      rel.ReleaseNotes,
+		Name:              rel.Name,
+		PublishedAt:       rel.PublishedAt,
+		Prerelease:        rel.Prerelease,
+		Draft:             rel.Draft,
+		RepoOwner:         rel.RepoOwner,
+		RepoName:          rel.RepoName,
+		Format:            rel.Format,
+		AssetPartIDs:      rel.AssetPartIDs,
+	}
+	if rel.validator != nil {
+		c.ValidatorSuffix = rel.validator.Suffix()
+	}
+	return c
+}
+
+// cachedRelease restores the cached release. The validator is looked up from the configured ones and the manifest
+// by its suffix.
+func (up *Updater) cachedRelease(c *checkCacheRelease) (*Release, error) {
+	v, err := semver.Make(c.Version)
+	if err != nil {
+		return nil, fmt.Errorf("Broken version %q in check cache: %s", c.Version, err)
+	}
+	rel := &Release{
+		Version:           v,
+		AssetURL:          c.AssetURL,
+		AssetByteSize:     c.AssetSize,
+		AssetID:           c.AssetID,
+		AssetName:         c.AssetName,
+		ValidationAssetID: c.ValidationAssetID,
+		URL:               c.URL,
+		ReleaseNotes:      c.ReleaseNotes,
+		Name:              c.Name,
+		PublishedAt:       c.PublishedAt,
+		Prerelease:        c.Prerelease,
+		Draft:             c.Draft,
+		RepoOwner:         c.RepoOwner,
+		RepoName:          c.RepoName,
+		Format:            c.Format,
+		AssetPartIDs:      c.AssetPartIDs,
+	}
+	if up.api != nil && up.api.BaseURL != nil {
+		rel.apiBaseURL = up.api.BaseURL.String()
+	}
+	for _, v := range up.configuredValidators() {
+		if c.ValidatorSuffix != "" && v.Suffix() == c.ValidatorSuffix {
+			rel.validator = v
+			break
+		}
+	}
+	if rel.validator == nil && c.ValidatorSuffix != "" && c.ValidatorSuffix == up.manifestAssetName {
+		rel.validator = manifestValidator{up.manifestAssetName}
+	}
+	return rel, nil
+}
+
+// readCheckCache reads the check cache file at Config.CheckCachePath.
+func (up *Updater) readCheckCache() (*checkCache, error) {
+	b, err := ioutil.ReadFile(up.checkCachePath)
+	if err != nil {
+		return nil, err
+	}
+	var c checkCache
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("Broken check cache file %s: %s", up.checkCachePath, err)
+	}
+	return &c, nil
+}
+
+// writeCheckCache records the result of the check to the check cache file at Config.CheckCachePath. The file is
+// replaced atomically so that a concurrent reader never sees a partially written file. Records of other repositories
+// are kept.
+func (up *Updater) writeCheckCache(slug string, rel *Release) error {
+	// Detections of several repositories such as DetectLatestMulti run in parallel
+	up.checkCacheMu.Lock()
+	defer up.checkCacheMu.Unlock()
+
+	c, err := up.readCheckCache()
+	if err != nil {
+		c = &checkCache{}
+	}
+	if c.Repos == nil {
+		c.Repos = map[string]*checkCacheRecord{}
+	}
+	c.Repos[slug] = &checkCacheRecord{CheckedAt: time.Now(), Release: newCheckCacheRelease(rel)}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("Failed to encode check cache: %s", err)
+	}
+	dir := filepath.Dir(up.checkCachePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create directory for check cache %s: %s", up.checkCachePath, err)
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(up.checkCachePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Failed to create check cache %s: %s", up.checkCachePath, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed to write check cache %s: %s", up.checkCachePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Failed to write check cache %s: %s", up.checkCachePath, err)
+	}
+	if err := os.Rename(tmp.Name(), up.checkCachePath); err != nil {
+		return fmt.Errorf("Failed to write check cache %s: %s", up.checkCachePath, err)
+	}
+	return nil
+}
+
+// ShouldCheck returns whether the interval has passed since the last check of the repository 'slug' recorded in the
+// check cache file at Config.CheckCachePath. It is useful for short-lived commands which notify updates to avoid
+// calling GitHub API on every invocation. It returns true when Config.CheckCachePath is not set, the file does not
+// exist or is broken, or the repository has never been checked. The check is recorded by DetectLatest.
+func (up *Updater) ShouldCheck(slug string, interval time.Duration) bool {
+	if up.checkCachePath == "" {
+		return true
+	}
+	c, err := up.readCheckCache()
+	if err != nil {
+		return true
+	}
+	r, ok := c.Repos[slug]
+	return !ok || time.Since(r.CheckedAt) >= interval
+}
+
+// detectLatestCached returns the latest release of the slug recorded in the check cache file when it was checked
+// within Config.CheckInterval.
+func (up *Updater) detectLatestCached(slug string) (*Release, bool, bool) {
+	if up.checkCachePath == "" || up.checkInterval <= 0 {
+		return nil, false, false
+	}
+	c, err := up.readCheckCache()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			up.infof("Check cache is ignored: %s", err)
+		}
+		return nil, false, false
+	}
+	r, ok := c.Repos[slug]
+	if !ok || time.Since(r.CheckedAt) >= up.checkInterval {
+		return nil, false, false
+	}
+	if r.Release == nil {
+		up.debugf("No release of %s was found on the last check at %s", slug, r.CheckedAt)
+		return nil, false, true
+	}
+	rel, err := up.cachedRelease(r.Release)
+	if err != nil {
+		up.infof("Check cache is ignored: %s", err)
+		return nil, false, false
+	}
+	up.debugf("Use release %s of %s recorded in check cache at %s", rel.Version, slug, r.CheckedAt)
+	return rel, true, true
+}
@@ -0,0 +1,159 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckCache(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			calls++
+			fmt.Fprint(w, `[{"tag_name": "v1.2.3", "body": "Fix bugs", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.zip", "size": 100, "browser_download_url": "https://example.com/bar_linux_amd64.zip"},
+				{"id": 2, "name": "bar_linux_amd64.zip.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/empty/releases":
+			calls++
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "myapp", "update-check.json")
+
+	config := Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{},
+		CheckCachePath:    path,
+		CheckInterval:     time.Hour,
+		// Repositories are checked one by one so that the server counts calls without data race
+		Concurrency: 1,
+	}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !up.ShouldCheck("foo/bar", time.Hour) {
+		t.Fatal("Update should be checked when check cache does not exist")
+	}
+
+	want, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || calls != 1 {
+		t.Fatal("Release should be detected via API:", want, calls)
+	}
+	if up.ShouldCheck("foo/bar", time.Hour) {
+		t.Fatal("Update should not be checked again within the interval")
+	}
+	if !up.ShouldCheck("foo/bar", 0) {
+		t.Fatal("Update should be checked when the interval passed")
+	}
+
+	// Another updater such as the next invocation of the command reads the recorded release
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || calls != 1 {
+		t.Fatal("Recorded release should be returned without calling API:", rel, calls)
+	}
+	if rel.Version.String() != "1.2.3" || rel.AssetID != 1 || rel.ValidationAssetID != 2 || rel.ReleaseNotes != "Fix bugs" ||
+		rel.RepoOwner != "foo" || rel.RepoName != "bar" || rel.AssetByteSize != 100 || rel.Format != FormatZip {
+		t.Fatalf("Recorded release is unexpected: %+v", rel)
+	}
+	if rel.validator == nil || rel.APIDownloadURL() != want.APIDownloadURL() {
+		t.Fatalf("Validator and API URL of recorded release should be restored: %+v", rel)
+	}
+
+	// Other repository is not served from the cache
+	if _, ok, err := up.DetectLatest(ctx, "foo/empty"); err != nil || ok || calls != 2 {
+		t.Fatal("Other repository should be checked via API:", ok, err, calls)
+	}
+	if _, ok, err := up.DetectLatest(ctx, "foo/empty"); err != nil || ok || calls != 2 {
+		t.Fatal("Repository without release should also be recorded:", ok, err, calls)
+	}
+	if _, ok, err := up.DetectLatest(ctx, "foo/bar"); err != nil || !ok || calls != 2 {
+		t.Fatal("Record of repository should be kept after checking other repository:", ok, err, calls)
+	}
+	if up.ShouldCheck("foo/empty", time.Hour) {
+		t.Fatal("Other repository should be recorded")
+	}
+	if !up.ShouldCheck("foo/unknown", time.Hour) {
+		t.Fatal("Update should be checked for repository which has never been checked")
+	}
+
+	// Several repositories checked at once are recorded
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	rels, errs := up.DetectLatestMulti(ctx, []string{"foo/bar", "foo/empty"})
+	if len(errs) != 0 || rels["foo/bar"] == nil || calls != 4 {
+		t.Fatal("Repositories should be checked via API:", rels, errs, calls)
+	}
+	rels, errs = up.DetectLatestMulti(ctx, []string{"foo/bar", "foo/empty"})
+	if len(errs) != 0 || rels["foo/bar"] == nil || calls != 4 {
+		t.Fatal("Repositories should be served from check cache:", rels, errs, calls)
+	}
+
+	// Expired record is not used
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c checkCache
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	c.Repos["foo/bar"].CheckedAt = time.Now().Add(-2 * time.Hour)
+	if b, err = json.Marshal(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !up.ShouldCheck("foo/bar", time.Hour) {
+		t.Fatal("Update should be checked after the interval")
+	}
+	if _, ok, err := up.DetectLatest(ctx, "foo/bar"); err != nil || !ok || calls != 5 {
+		t.Fatal("Expired record should not be used:", ok, err, calls)
+	}
+
+	// Broken file is ignored
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !up.ShouldCheck("foo/bar", time.Hour) {
+		t.Fatal("Update should be checked when check cache is broken")
+	}
+	if _, ok, err := up.DetectLatest(ctx, "foo/bar"); err != nil || !ok || calls != 6 {
+		t.Fatal("Broken check cache should be ignored:", ok, err, calls)
+	}
+}
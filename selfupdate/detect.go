@@ -2,10 +2,17 @@ package selfupdate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/blang/semver"
 	"github.com/google/go-github/v30/github"
@@ -13,31 +20,548 @@ import (
 
 var reVersion = regexp.MustCompile(`\d+\.\d+\.\d+`)
 
-func findAssetFromRelease(rel *github.RepositoryRelease,
-	suffixes []string, targetVersion string, filters []*regexp.Regexp) (*github.ReleaseAsset, semver.Version, bool) {
+// assetExtensions is the list of file extensions of supported asset formats in the default order of preference.
+// An empty string means an uncompressed binary.
+var assetExtensions = []string{".zip", ".tar.gz", ".tgz", ".gzip", ".gz", ".tar.xz", ".xz", ".tar.zst", ".tzst", ".zst", ".tar.bz2", ".bz2", ".tar", ".7z", ""}
 
-	if targetVersion != "" && targetVersion != rel.GetTagName() {
-		log.Println("Skip", rel.GetTagName(), "not matching to specified version", targetVersion)
-		return nil, semver.Version{}, false
+// packageExtensions is the list of file extensions of system packages. They are detected only when
+// Config.AllowPackageAssets is set and cannot be installed by this library.
+var packageExtensions = []string{".deb", ".rpm"}
+
+// defaultMaxReleasePages is the maximum number of pages fetched from GitHub Releases API when
+// Config.MaxReleasePages is not set.
+const defaultMaxReleasePages = 10
+
+// errReleaseSkipped is an internal error returned when a release is not matching to the target version.
+var errReleaseSkipped = errors.New("release was skipped")
+
+// skipError is an internal error returned when a release is not a candidate of detection such as a draft. The
+// reason is reported by Updater.SkippedReleases.
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return "release was skipped: " + e.reason
+}
+
+func (up *Updater) findAssetFromRelease(rel *github.RepositoryRelease,
+	suffixes [][]string, targetVersion string) (*github.ReleaseAsset, semver.Version, error) {
+
+	if up.tagPrefix != "" && !strings.HasPrefix(rel.GetTagName(), up.tagPrefix) {
+		up.debugf("Skip %s not prefixed with %q", rel.GetTagName(), up.tagPrefix)
+		return nil, semver.Version{}, errReleaseSkipped
+	}
+
+	if targetVersion != "" && !up.matchTargetVersion(rel.GetTagName(), targetVersion) {
+		up.debugf("Skip %s not matching to specified version %s", rel.GetTagName(), targetVersion)
+		return nil, semver.Version{}, errReleaseSkipped
+	}
+
+	if rel.GetDraft() && (targetVersion == "" || !up.allowDrafts) {
+		up.debugf("Skip draft version %s", rel.GetTagName())
+		return nil, semver.Version{}, &skipError{"draft"}
+	}
+	if targetVersion == "" && rel.GetPrerelease() && !up.prerelease && !up.isPrereleaseChannel() {
+		up.debugf("Skip pre-release version %s", rel.GetTagName())
+		return nil, semver.Version{}, &skipError{"pre-release"}
+	}
+
+	var ver semver.Version
+	if up.versionExtractor != nil {
+		v, err := up.versionExtractor.ExtractVersion(rel.GetTagName())
+		if err != nil {
+			up.debugf("Skip version %s rejected by version extractor: %s", rel.GetTagName(), err)
+			return nil, semver.Version{}, &skipError{fmt.Sprintf("rejected by version extractor: %s", err)}
+		}
+		ver = v
+	} else {
+		v, ok := up.extractVersion(up.tagVersionPart(rel.GetTagName()))
+		if !ok {
+			return nil, semver.Version{}, &skipError{"unparseable"}
+		}
+		ver = v
+	}
+
+	if targetVersion == "" && !up.matchChannel(ver) {
+		up.debugf("Skip version %s not in channel %q", rel.GetTagName(), up.channel)
+		return nil, semver.Version{}, &skipError{fmt.Sprintf("not in channel %q", up.channel)}
+	}
+
+	if up.assetTemplate != nil {
+		if asset, ok := up.findAssetByTemplate(rel, ver); ok {
+			return asset, ver, nil
+		}
+	} else if asset, ok := up.findAssetBySuffixes(rel, suffixes); ok {
+		return asset, ver, nil
+	}
+	if up.contentTypeFallback {
+		if asset, ok := up.findAssetByContentType(rel); ok {
+			return asset, ver, nil
+		}
+	}
+
+	up.debugf("No suitable asset was found in release %s", rel.GetTagName())
+	return nil, ver, up.noMatchingAsset(rel, ver)
+}
+
+// noMatchingAsset returns the error reporting that the release of the version has no asset for the platform.
+func (up *Updater) noMatchingAsset(rel *github.RepositoryRelease, ver semver.Version) *NoMatchingAssetError {
+	names := make([]string, 0, len(rel.Assets))
+	for _, asset := range rel.Assets {
+		names = append(names, asset.GetName())
+	}
+	return &NoMatchingAssetError{
+		Tag:     rel.GetTagName(),
+		Version: ver,
+		OS:      up.targetOS(),
+		Arch:    up.targetArch(),
+		Assets:  names,
+	}
+}
+
+// hasValidSize returns false when the size of the asset reported by the API is zero or less than
+// Config.MinAssetSize. Such an asset is usually a leftover of a failed upload. When the size is not reported,
+// the asset is not skipped.
+func (up *Updater) hasValidSize(asset *github.ReleaseAsset) bool {
+	if asset.Size == nil {
+		return true
+	}
+	size := *asset.Size
+	if size == 0 || size < up.minAssetSize {
+		up.infof("Skip asset %q since its size %d bytes is too small", asset.GetName(), size)
+		return false
+	}
+	return true
+}
+
+// formatAllowed returns whether the asset format is allowed by Config.AllowedFormats.
+func (up *Updater) formatAllowed(format AssetFormat) bool {
+	if len(up.allowedFormats) == 0 {
+		return true
+	}
+	for _, f := range up.allowedFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAllowedFormat returns whether the format of the asset name is allowed. A skipped asset is logged.
+func (up *Updater) hasAllowedFormat(name string) bool {
+	f := up.nameFormat(name)
+	if up.formatAllowed(f) {
+		return true
+	}
+	up.debugf("Skip asset %q since its format %q is not allowed", name, f)
+	return false
+}
+
+// isPrereleaseChannel returns whether Config.Channel selects pre-releases such as "beta" or "nightly".
+func (up *Updater) isPrereleaseChannel() bool {
+	return up.channel != "" && up.channel != "stable"
+}
+
+// matchChannel returns whether the version belongs to Config.Channel. A version without pre-release identifiers
+// belongs to the "stable" channel. Otherwise the first pre-release identifier is the channel ('1.2.0-beta.1' belongs
+// to "beta"). When the channel is empty, all versions match.
+func (up *Updater) matchChannel(ver semver.Version) bool {
+	if up.channel == "" {
+		return true
+	}
+	if len(ver.Pre) == 0 {
+		return up.channel == "stable"
+	}
+	return ver.Pre[0].String() == up.channel
+}
+
+// findAssetBySuffixes finds the asset whose name ends with one of the suffixes.
+// Suffixes are grouped by arch name in order of preference. All assets are checked against
+// the first group before trying the next one so that an exact arch match is always preferred
+// over its aliases (e.g. 'armv7' over 'armv6' on an ARMv7 machine).
+func (up *Updater) findAssetBySuffixes(rel *github.RepositoryRelease, suffixes [][]string) (*github.ReleaseAsset, bool) {
+	for _, group := range suffixes {
+		candidates := []assetCandidate{}
+		for _, asset := range rel.Assets {
+			name := asset.GetName()
+			if up.allowSplitAssets && strings.HasSuffix(name, firstPartExt) {
+				whole, ok := up.joinSplitAsset(rel, strings.TrimSuffix(name, firstPartExt))
+				if !ok {
+					continue
+				}
+				asset, name = whole, whole.GetName()
+			}
+			suffix, ok := matchSuffix(name, group) // require version, arch etc
+			if !ok {
+				continue
+			}
+			// Filters narrow the assets matching to the platform
+			if !up.matchFilters(name) || !up.hasValidSize(asset) || !up.hasAllowedFormat(name) {
+				continue
+			}
+			up.debugf("Asset %q matched suffix %q", name, suffix)
+			candidates = append(candidates, up.newAssetCandidate(asset, suffix))
+		}
+		if len(candidates) > 0 {
+			return up.selectAsset(candidates).asset, true
+		}
+	}
+	return nil, false
+}
+
+// firstPartExt is the extension of the first part of a split asset such as 'foo.tar.gz.001'.
+const firstPartExt = ".001"
+
+// reSplitPart matches the extension of a part of a split asset.
+var reSplitPart = regexp.MustCompile(`\.\d{3}$`)
+
+// splitAssetParts returns the parts of the split asset named 'name' such as 'name.001', 'name.002', ... in order.
+// It returns nil when the series is not found or one of its parts is missing.
+func splitAssetParts(rel *github.RepositoryRelease, name string) []*github.ReleaseAsset {
+	byName := map[string]*github.ReleaseAsset{}
+	numbered := 0
+	for _, a := range rel.Assets {
+		n := a.GetName()
+		byName[n] = a
+		if strings.HasPrefix(n, name) && len(n) == len(name)+len(firstPartExt) && reSplitPart.MatchString(n) {
+			numbered++
+		}
+	}
+	var parts []*github.ReleaseAsset
+	for i := 1; ; i++ {
+		p, ok := byName[fmt.Sprintf("%s.%03d", name, i)]
+		if !ok {
+			break
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 || len(parts) != numbered {
+		return nil
+	}
+	return parts
+}
+
+// joinSplitAsset returns the asset representing the whole of the split asset named 'name'. Its ID and URL are the ones
+// of the first part and its size is the sum of the parts. It returns false when the series is incomplete.
+func (up *Updater) joinSplitAsset(rel *github.RepositoryRelease, name string) (*github.ReleaseAsset, bool) {
+	parts := splitAssetParts(rel, name)
+	if parts == nil {
+		up.debugf("Skip split asset %q in release %s since some of its parts are missing", name, rel.GetTagName())
+		return nil, false
+	}
+	first := parts[0]
+	whole := &github.ReleaseAsset{
+		ID:                 first.ID,
+		Name:               github.String(name),
+		BrowserDownloadURL: first.BrowserDownloadURL,
+	}
+	size := 0
+	for _, p := range parts {
+		if p.Size == nil {
+			return whole, true
+		}
+		size += *p.Size
+	}
+	whole.Size = github.Int(size)
+	return whole, true
+}
+
+// containsWord returns whether the name contains the word separated by non-alphanumeric characters. The comparison
+// is case-insensitive.
+func containsWord(name, word string) bool {
+	if word == "" {
+		return false
+	}
+	name, word = strings.ToLower(name), strings.ToLower(word)
+	for i := 0; ; {
+		j := strings.Index(name[i:], word)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		if (start == 0 || !isAlnum(name[start-1])) && (end == len(name) || !isAlnum(name[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// isAlnum returns whether the byte is a lower case ASCII letter or a digit.
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9'
+}
+
+// findAssetByContentType finds the asset by its content type as the last resort when no asset name matches the
+// naming rules. The asset must be an archive or compressed file by its content type and its name must contain the
+// OS name and one of the arch names. It fails when several assets match since the selection would be a guess.
+func (up *Updater) findAssetByContentType(rel *github.RepositoryRelease) (*github.ReleaseAsset, bool) {
+	var found *github.ReleaseAsset
+	for _, asset := range rel.Assets {
+		name := asset.GetName()
+		f, ok := contentTypeFormat(asset.GetContentType())
+		if !ok || !up.formatAllowed(f) || !containsWord(name, up.targetOS()) {
+			continue
+		}
+		arch := false
+		for _, a := range up.targetArchAliases() {
+			if containsWord(name, a) {
+				arch = true
+				break
+			}
+		}
+		if !arch || !up.matchFilters(name) || !up.hasValidSize(asset) {
+			continue
+		}
+		if found != nil {
+			up.infof("Asset was not detected by content type since both %q and %q match in release %s", found.GetName(), name, rel.GetTagName())
+			return nil, false
+		}
+		found = asset
+	}
+	if found == nil {
+		return nil, false
+	}
+	up.debugf("Asset %q was detected by its content type %q", found.GetName(), found.GetContentType())
+	return found, true
+}
+
+// nameFormat returns the format of the asset name. An extension of Config.Decompressors which is not a built-in
+// one is the format without the leading dot.
+func (up *Updater) nameFormat(name string) AssetFormat {
+	lower := strings.ToLower(name)
+	if ext, _, ok := up.decompressorFor(lower); ok {
+		if _, builtin := formatsByExtension[ext]; !builtin {
+			return AssetFormat(strings.TrimPrefix(ext, "."))
+		}
+	}
+	return assetFormat(lower)
+}
+
+// assetFormatOf returns the format of the asset from its name. When the name has no known extension and
+// Config.AllowContentTypeFallback is set, the format of its content type is used.
+func (up *Updater) assetFormatOf(asset *github.ReleaseAsset) AssetFormat {
+	f := up.nameFormat(asset.GetName())
+	if f != FormatRaw || !up.contentTypeFallback {
+		return f
+	}
+	if cf, ok := contentTypeFormat(asset.GetContentType()); ok {
+		return cf
+	}
+	return f
+}
+
+// AssetNameTemplateData is the data to render Config.AssetNameTemplate for each release.
+type AssetNameTemplateData struct {
+	// OS is the OS name such as "linux"
+	OS string
+	// Arch is the arch name such as "amd64". Aliases such as "x86_64" are also tried in order of preference.
+	Arch string
+	// Version is the version of the release without prefix such as "1.2.3"
+	Version string
+	// Tag is the tag name of the release such as "v1.2.3"
+	Tag string
+}
+
+// findAssetByTemplate finds the asset whose name is exactly the name rendered from Config.AssetNameTemplate.
+func (up *Updater) findAssetByTemplate(rel *github.RepositoryRelease, ver semver.Version) (*github.ReleaseAsset, bool) {
+	for _, arch := range up.targetArchAliases() {
+		data := AssetNameTemplateData{
+			OS:      up.targetOS(),
+			Arch:    arch,
+			Version: ver.String(),
+			Tag:     rel.GetTagName(),
+		}
+		var b strings.Builder
+		if err := up.assetTemplate.Execute(&b, data); err != nil {
+			up.debugf("Failed to render asset name template for %s: %s", rel.GetTagName(), err)
+			return nil, false
+		}
+		want := b.String()
+		assets := rel.Assets
+		if up.allowSplitAssets {
+			if whole, ok := up.joinSplitAsset(rel, want); ok {
+				assets = append([]*github.ReleaseAsset{whole}, assets...)
+			}
+		}
+		for _, asset := range assets {
+			name := asset.GetName()
+			if name != want || !up.matchFilters(name) || !up.hasValidSize(asset) || !up.hasAllowedFormat(name) {
+				continue
+			}
+			up.debugf("Asset %q matched asset name template", name)
+			return asset, true
+		}
+	}
+	return nil, false
+}
+
+// matchSuffix returns the longest suffix of the name in the suffixes. The comparison is case-insensitive since
+// some projects capitalize OS names in their assets such as 'MyApp_Darwin_arm64.tar.gz'. The suffix is still
+// anchored at the end of the name so 'linux' never matches 'linuxfoo'.
+func matchSuffix(name string, suffixes []string) (string, bool) {
+	name = strings.ToLower(name)
+	matched := ""
+	found := false
+	for _, s := range suffixes {
+		if strings.HasSuffix(name, strings.ToLower(s)) && (!found || len(s) > len(matched)) {
+			matched = s
+			found = true
+		}
 	}
+	return matched, found
+}
+
+// assetCandidate is an asset matching to the platform. 'ext' is its compression format extension (empty for
+// uncompressed binary) and 'base' is the rest of the matched suffix such as 'windows_amd64.exe'.
+type assetCandidate struct {
+	asset *github.ReleaseAsset
+	base  string
+	ext   string
+}
+
+func (up *Updater) newAssetCandidate(asset *github.ReleaseAsset, suffix string) assetCandidate {
+	ext := ""
+	exts := append(append(append([]string{}, assetExtensions...), packageExtensions...), up.customExtensions()...)
+	for _, e := range exts {
+		if strings.HasSuffix(suffix, e) && len(e) > len(ext) {
+			ext = e
+		}
+	}
+	return assetCandidate{asset, strings.TrimSuffix(suffix, ext), ext}
+}
 
-	if targetVersion == "" && rel.GetDraft() {
-		log.Println("Skip draft version", rel.GetTagName())
-		return nil, semver.Version{}, false
+// compressionRank returns the rank of the compression format extension. Smaller is preferred. An uncompressed
+// binary comes first when Config.PreferRawAsset is set. Extensions in Config.CompressionPreference come next and
+// the others follow in the default order.
+func (up *Updater) compressionRank(ext string) int {
+	if up.preferRawAsset && ext == "" {
+		return -1
+	}
+	for i, e := range up.compressionPreference {
+		if e == ext {
+			return i
+		}
+	}
+	for i, e := range assetExtensions {
+		if e == ext {
+			return len(up.compressionPreference) + i
+		}
 	}
-	if targetVersion == "" && rel.GetPrerelease() {
-		log.Println("Skip pre-release version", rel.GetTagName())
-		return nil, semver.Version{}, false
+	return len(up.compressionPreference) + len(assetExtensions)
+}
+
+// selectAsset selects the best asset from the candidates deterministically. The most specific suffix wins at
+// first (e.g. 'windows_amd64.exe' over 'windows_amd64'), then the preferred compression format, and finally
+// the asset name in lexical order.
+func (up *Updater) selectAsset(candidates []assetCandidate) assetCandidate {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		l, r := candidates[i], candidates[j]
+		if len(l.base) != len(r.base) {
+			return len(l.base) > len(r.base)
+		}
+		if lr, rr := up.compressionRank(l.ext), up.compressionRank(r.ext); lr != rr {
+			return lr < rr
+		}
+		return l.asset.GetName() < r.asset.GetName()
+	})
+	selected := candidates[0]
+	for _, c := range candidates[1:] {
+		up.debugf("Asset %q was not selected in favor of %q", c.asset.GetName(), selected.asset.GetName())
 	}
+	return selected
+}
+
+// matchFilters returns whether the asset name matches the filters. When FilterAny is set, matching any one of
+// the filters is sufficient. When FilterAll is set, the name must match all of them.
+func (up *Updater) matchFilters(name string) bool {
+	if len(up.filters) == 0 {
+		return true
+	}
+	for _, filter := range up.filters {
+		matched := filter.MatchString(name)
+		if !matched {
+			up.debugf("Asset %q does not match filter %v", name, filter)
+		}
+		if up.filterMode == FilterAll && !matched {
+			up.debugf("Skipping asset %q not matching all filters", name)
+			return false
+		}
+		if up.filterMode == FilterAny && matched {
+			up.debugf("Selected filtered asset %s", name)
+			return true
+		}
+	}
+	if up.filterMode == FilterAll {
+		up.debugf("Selected filtered asset %s", name)
+		return true
+	}
+	up.debugf("Skipping asset %q not matching any filter", name)
+	return false
+}
+
+// tagVersionPart returns the part of the tag name containing the version. Config.TagPrefix is stripped and a path
+// prefix such as 'myservice/' of 'myservice/v1.4.2' in monorepos is also stripped.
+func (up *Updater) tagVersionPart(tag string) string {
+	tag = strings.TrimPrefix(tag, up.tagPrefix)
+	if i := strings.LastIndexByte(tag, '/'); i >= 0 {
+		tag = tag[i+1:]
+	}
+	return tag
+}
+
+// matchTargetVersion returns whether the tag matches to the version specified by DetectVersion. The target can be
+// the whole tag or the version part of the tag with or without 'v' such as 'v1.4.2' or '1.4.2' for the tag
+// 'myservice/v1.4.2'. A target containing '/' must be equal to the whole tag.
+func (up *Updater) matchTargetVersion(tag, target string) bool {
+	if tag == target {
+		return true
+	}
+	if strings.Contains(target, "/") {
+		return false
+	}
+	return strings.TrimPrefix(up.tagVersionPart(tag), "v") == strings.TrimPrefix(target, "v")
+}
 
-	verText := rel.GetTagName()
+// targetTags returns the candidates of the tag name for the version specified by DetectVersion in order of
+// preference. Both the version with and without 'v' are tried, and Config.TagPrefix is prepended to them.
+func (up *Updater) targetTags(target string) []string {
+	if strings.Contains(target, "/") {
+		return []string{target}
+	}
+	v := strings.TrimPrefix(target, "v")
+	tags := []string{up.tagPrefix + target}
+	if v == target {
+		return append(tags, up.tagPrefix+"v"+v)
+	}
+	return append(tags, up.tagPrefix+v)
+}
+
+// extractVersion extracts a semantic version from the tag name. A prefix before the version number
+// such as 'v' or 'release-' is stripped. When Config.AllowNonSemverTags is set, a tag which is not
+// adopting semver is coerced into semver. When Config.StrictTagParsing is set, the tag must be a clean
+// semver with an optional leading 'v'.
+func (up *Updater) extractVersion(tag string) (semver.Version, bool) {
+	if up.strictTagParsing {
+		ver, err := semver.Make(strings.TrimPrefix(tag, "v"))
+		if err != nil {
+			up.debugf("Skip tag %s which is not a clean semantic version: %s", tag, err)
+			return semver.Version{}, false
+		}
+		return ver, true
+	}
+
+	verText := tag
 	indices := reVersion.FindStringIndex(verText)
 	if indices == nil {
-		log.Println("Skip version not adopting semver", verText)
-		return nil, semver.Version{}, false
+		if up.allowNonSemverTags {
+			return up.coerceVersion(tag)
+		}
+		up.debugf("Skip version not adopting semver %s", verText)
+		return semver.Version{}, false
 	}
 	if indices[0] > 0 {
-		log.Println("Strip prefix of version", verText[:indices[0]], "from", verText)
+		up.debugf("Strip prefix of version %s from %s", verText[:indices[0]], verText)
 		verText = verText[indices[0]:]
 	}
 
@@ -45,38 +569,246 @@ func findAssetFromRelease(rel *github.RepositoryRelease,
 	// the semantic versioning. So it should be skipped.
 	ver, err := semver.Make(verText)
 	if err != nil {
-		log.Println("Failed to parse a semantic version", verText)
-		return nil, semver.Version{}, false
+		if up.allowNonSemverTags {
+			return up.coerceVersion(tag)
+		}
+		up.debugf("Failed to parse a semantic version %s", verText)
+		return semver.Version{}, false
 	}
+	return ver, true
+}
 
-	for _, asset := range rel.Assets {
-		name := asset.GetName()
-		if len(filters) > 0 {
-			// if some filters are defined, match them: if any one matches, the asset is selected
-			matched := false
-			for _, filter := range filters {
-				if filter.MatchString(name) {
-					log.Println("Selected filtered asset", name)
-					matched = true
-					break
-				}
-				log.Printf("Skipping asset %q not matching filter %v\n", name, filter)
-			}
-			if !matched {
-				continue
-			}
+// ParseVersion parses a version string such as the current version of a command embedded via build flags in the
+// same way as the version of a release tag is parsed on detection. A prefix before the version number such as 'v' or
+// 'release-' is stripped, so 'v1.2.3+build.7' is parsed as 1.2.3 with build metadata 'build.7'. Build metadata is
+// kept but it is ignored on comparing versions, so the parsed version can be passed to UpdateCommand as is.
+func ParseVersion(s string) (semver.Version, error) {
+	s = strings.TrimSpace(s)
+	indices := reVersion.FindStringIndex(s)
+	if indices == nil {
+		return semver.Version{}, fmt.Errorf("Version %q is not adopting semantic versioning", s)
+	}
+	v, err := semver.Make(s[indices[0]:])
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("Failed to parse a semantic version %q: %w", s, err)
+	}
+	return v, nil
+}
+
+var reLooseVersion = regexp.MustCompile(`\d+(?:\.\d+){0,2}`)
+
+// coerceVersion coerces the tag name into semver by padding missing version components with zero and removing
+// leading zeros such as '2024.06' -> '2024.6.0' and 'v3' -> '3.0.0'. Pre-release and build metadata following
+// the version number are kept when they are valid.
+func (up *Updater) coerceVersion(tag string) (semver.Version, bool) {
+	indices := reLooseVersion.FindStringIndex(tag)
+	if indices == nil {
+		up.debugf("Skip version which cannot be coerced into semver %s", tag)
+		return semver.Version{}, false
+	}
+
+	parts := strings.Split(tag[indices[0]:indices[1]], ".")
+	nums := make([]string, 0, 3)
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			up.debugf("Skip version which cannot be coerced into semver %s: %s", tag, err)
+			return semver.Version{}, false
+		}
+		nums = append(nums, strconv.FormatUint(n, 10))
+	}
+	for len(nums) < 3 {
+		nums = append(nums, "0")
+	}
+	verText := strings.Join(nums, ".")
+
+	if rest := tag[indices[1]:]; rest != "" {
+		if v, err := semver.Make(verText + rest); err == nil {
+			up.debugf("Coerced version %s into %s", tag, v)
+			return v, true
 		}
+	}
+	v, err := semver.Make(verText)
+	if err != nil {
+		up.debugf("Skip version which cannot be coerced into semver %s: %s", tag, err)
+		return semver.Version{}, false
+	}
+	up.debugf("Coerced version %s into %s", tag, v)
+	return v, true
+}
+
+// archAliases returns the arch names which may appear in asset names for the given GOARCH, in order
+// of preference. The first element is always the GOARCH itself. For 'arm', 'goarm' is the GOARM value
+// the running binary was built with and it decides which ARM variants are acceptable.
+func archAliases(goarch, goarm string) []string {
+	switch goarch {
+	case "amd64":
+		return []string{"amd64", "x86_64"}
+	case "arm64":
+		return []string{"arm64", "aarch64"}
+	case "arm":
+		switch goarm {
+		case "5":
+			return []string{"arm", "armv5"}
+		case "6":
+			return []string{"arm", "armv6", "armhf", "armv5"}
+		default:
+			return []string{"arm", "armv7", "armhf", "armv6", "armv5"}
+		}
+	}
+	return []string{goarch}
+}
+
+// goarm returns the GOARM value which the running binary was built with. The floating point mode such as ',softfloat'
+// (Go 1.22 or later) is dropped. When it is not available, it returns an empty string.
+func goarm() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "GOARM" {
+			return goarmVersion(s.Value)
+		}
+	}
+	return ""
+}
+
+// goarmVersion returns the ARM version of the GOARM value such as "7" for "7,softfloat".
+func goarmVersion(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		return v[:i]
+	}
+	return v
+}
 
-		for _, s := range suffixes {
-			if strings.HasSuffix(name, s) { // require version, arch etc
-				// default: assume single artifact
-				return asset, ver, true
+// targetOS returns the OS name of assets to detect. It is Config.OS or runtime.GOOS when it is not set.
+func (up *Updater) targetOS() string {
+	if up.os != "" {
+		return up.os
+	}
+	return runtime.GOOS
+}
+
+// targetArch returns the arch name of assets to detect. It is Config.Arch or runtime.GOARCH when it is not set.
+func (up *Updater) targetArch() string {
+	if up.arch != "" {
+		return up.arch
+	}
+	return runtime.GOARCH
+}
+
+// darwinUniversalArchs is the list of arch names of macOS universal binaries which run on both amd64 and arm64.
+var darwinUniversalArchs = []string{"universal", "all"}
+
+// withUniversalArchs appends the arch names of universal binaries to the arch names when the OS is macOS. They come
+// last so that an asset for the exact arch is preferred.
+func withUniversalArchs(goos string, archs []string) []string {
+	if goos != "darwin" {
+		return archs
+	}
+	return append(archs, darwinUniversalArchs...)
+}
+
+// targetArchAliases returns the arch names of assets to detect in order of preference. GOARM of the running
+// binary is only taken into account when the arch is not overridden. On macOS, universal binaries such as
+// 'darwin_universal' are also detected as a fallback.
+func (up *Updater) targetArchAliases() []string {
+	if up.arch != "" && up.arch != runtime.GOARCH {
+		return withUniversalArchs(up.targetOS(), archAliases(up.arch, ""))
+	}
+	return withUniversalArchs(up.targetOS(), archAliases(runtime.GOARCH, goarm()))
+}
+
+// libcProbes are the files whose existence indicates that the system uses musl libc.
+var libcProbes = []string{"/etc/alpine-release", "/lib/ld-musl-*"}
+
+// detectLibc returns "musl" when one of libcProbes exists and "gnu" otherwise.
+func detectLibc() string {
+	for _, p := range libcProbes {
+		if m, _ := filepath.Glob(p); len(m) > 0 {
+			return "musl"
+		}
+	}
+	return "gnu"
+}
+
+// targetLibc returns the libc variant qualifying asset names on linux or an empty string when assets are not
+// qualified. "auto" of Config.LibcVariant is only resolved when the running system is the target OS.
+func (up *Updater) targetLibc() string {
+	if up.libcVariant == "" || up.targetOS() != "linux" {
+		return ""
+	}
+	if up.libcVariant != "auto" {
+		return up.libcVariant
+	}
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	return detectLibc()
+}
+
+// assetSuffixes generates the asset name suffixes for the given OS and arch names such as 'linux_amd64.zip'.
+func assetSuffixes(goos, arch string) []string {
+	return qualifiedAssetSuffixes(goos, arch, "")
+}
+
+// qualifiedAssetSuffixes generates the asset name suffixes in the same way as assetSuffixes, but the arch name is
+// followed by the qualifier such as 'linux_amd64_musl.zip' when it is not empty.
+func qualifiedAssetSuffixes(goos, arch, qualifier string) []string {
+	return extensionSuffixes(goos, arch, qualifier, assetExtensions)
+}
+
+// extensionSuffixes generates the asset name suffixes in the same way as qualifiedAssetSuffixes for the given file
+// extensions.
+func extensionSuffixes(goos, arch, qualifier string, exts []string) []string {
+	suffixes := make([]string, 0, 2*len(exts)*2)
+	for _, sep := range []rune{'_', '-'} {
+		a := arch
+		if qualifier != "" {
+			a = fmt.Sprintf("%s%c%s", arch, sep, qualifier)
+		}
+		for _, ext := range exts {
+			suffix := fmt.Sprintf("%s%c%s%s", goos, sep, a, ext)
+			suffixes = append(suffixes, suffix)
+			if goos == "windows" {
+				suffix = fmt.Sprintf("%s%c%s.exe%s", goos, sep, a, ext)
+				suffixes = append(suffixes, suffix)
 			}
 		}
 	}
+	return suffixes
+}
+
+// packageAssetSuffixes generates the asset name suffixes of system packages such as 'linux_amd64.deb'.
+func packageAssetSuffixes(goos, arch string) []string {
+	suffixes := make([]string, 0, 2*len(packageExtensions))
+	for _, sep := range []rune{'_', '-'} {
+		for _, ext := range packageExtensions {
+			suffixes = append(suffixes, fmt.Sprintf("%s%c%s%s", goos, sep, arch, ext))
+		}
+	}
+	return suffixes
+}
 
-	log.Println("No suitable asset was found in release", rel.GetTagName())
-	return nil, semver.Version{}, false
+// releaseAssetName returns the file name of the asset of the release. The asset URL is used when the name is
+// not known such as a release given to UpdateTo.
+func releaseAssetName(rel *Release) string {
+	if rel.AssetName != "" {
+		return rel.AssetName
+	}
+	return rel.AssetURL
+}
+
+// isPackageAsset returns whether the asset of the release is a system package such as '.deb' or '.rpm'.
+func isPackageAsset(rel *Release) bool {
+	name := releaseAssetName(rel)
+	for _, ext := range packageExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
 }
 
 func findValidationAsset(rel *github.RepositoryRelease, validationName string) (*github.ReleaseAsset, bool) {
@@ -85,109 +817,865 @@ func findValidationAsset(rel *github.RepositoryRelease, validationName string) (
 			return asset, true
 		}
 	}
+	// Names such as 'foo.tar.gz.SHA256' are also accepted
+	for _, asset := range rel.Assets {
+		if strings.EqualFold(asset.GetName(), validationName) {
+			return asset, true
+		}
+	}
 	return nil, false
 }
 
+// hasSuffixFold returns whether the name ends with any of the suffixes ignoring case.
+func hasSuffixFold(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if len(name) >= len(s) && strings.EqualFold(name[len(name)-len(s):], s) {
+			return true
+		}
+	}
+	return false
+}
+
+// findFilteredValidationAsset finds the validation file for the release asset. The file named exactly after the asset
+// such as 'foo_linux_amd64.zip.sha256' is preferred. When it is not found and Config.Filters is set, the only asset
+// which has the suffix of the validator and matches the filters is used instead so that a validation file named
+// differently such as 'foo_1.0_linux_amd64.sha256' can be located. The returned error wraps
+// ErrValidationAssetNotFound when neither is found.
+func (up *Updater) findFilteredValidationAsset(rel *github.RepositoryRelease, asset *github.ReleaseAsset, v Validator) (*github.ReleaseAsset, error) {
+	names := validationAssetNames(v, asset.GetName())
+	for _, n := range names {
+		if a, ok := findValidationAsset(rel, n); ok {
+			return a, nil
+		}
+	}
+	validationName := names[0]
+	if _, ok := v.(AssetNameValidator); ok || len(up.filters) == 0 {
+		return nil, fmt.Errorf("%w: %q for asset %q in release %s", ErrValidationAssetNotFound, validationName, asset.GetName(), rel.GetTagName())
+	}
+
+	suffixes := validatorSuffixes(v)
+	quoted := make([]string, 0, len(suffixes))
+	for _, s := range suffixes {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	suffix := strings.Join(quoted, " or ")
+	var candidates []*github.ReleaseAsset
+	for _, a := range rel.Assets {
+		name := a.GetName()
+		if name != asset.GetName() && hasSuffixFold(name, suffixes) && up.matchFilters(name) {
+			candidates = append(candidates, a)
+		}
+	}
+	switch len(candidates) {
+	case 1:
+		up.debugf("Validation file %q matched filters instead of %q", candidates[0].GetName(), validationName)
+		return candidates[0], nil
+	case 0:
+		return nil, fmt.Errorf("%w: neither %q nor a file with suffix %s matching filters for asset %q in release %s", ErrValidationAssetNotFound, validationName, suffix, asset.GetName(), rel.GetTagName())
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, a := range candidates {
+			names = append(names, a.GetName())
+		}
+		return nil, fmt.Errorf("%w: %q for asset %q in release %s, and multiple files matching filters are found: %s", ErrValidationAssetNotFound, validationName, asset.GetName(), rel.GetTagName(), strings.Join(names, ", "))
+	}
+}
+
+// configuredValidators returns Config.Validator and Config.Validators in order of trial.
+func (up *Updater) configuredValidators() []Validator {
+	if up.validator == nil {
+		return up.validators
+	}
+	return append([]Validator{up.validator}, up.validators...)
+}
+
+// findValidator returns the first configured validator whose validation asset for the asset exists in the release
+// with the validation asset. When none of them is found, the returned error wraps ErrValidationAssetNotFound.
+func (up *Updater) findValidator(rel *github.RepositoryRelease, asset *github.ReleaseAsset) (Validator, *github.ReleaseAsset, error) {
+	vs := up.configuredValidators()
+	var firstErr error
+	for _, v := range vs {
+		a, err := up.findFilteredValidationAsset(rel, asset, v)
+		if err == nil {
+			return v, a, nil
+		}
+		up.debugf("Validation file for suffix %q was not found: %s", v.Suffix(), err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(vs) == 1 {
+		return nil, nil, firstErr
+	}
+	suffixes := make([]string, 0, len(vs))
+	for _, v := range vs {
+		suffixes = append(suffixes, fmt.Sprintf("%q", v.Suffix()))
+	}
+	return nil, nil, fmt.Errorf("%w: none of validators (suffixes: %s) found its file for asset %q in release %s", ErrValidationAssetNotFound, strings.Join(suffixes, ", "), asset.GetName(), rel.GetTagName())
+}
+
 type releaseWithAssets struct {
 	*github.RepositoryRelease
 	*github.ReleaseAsset
 	semver.Version
+	// manifest is the manifest asset listing the checksum of the asset when the asset was selected by the manifest
+	manifest *github.ReleaseAsset
 }
 
-func findReleasesAndAssets(rels []*github.RepositoryRelease, targetVersion string, filters []*regexp.Regexp) (out []releaseWithAssets) {
-	// Generate candidates
-	suffixes := make([]string, 0, 2*7*2)
-	for _, sep := range []rune{'_', '-'} {
-		for _, ext := range []string{".zip", ".tar.gz", ".tgz", ".gzip", ".gz", ".tar.xz", ".xz", ""} {
-			suffix := fmt.Sprintf("%s%c%s%s", runtime.GOOS, sep, runtime.GOARCH, ext)
-			suffixes = append(suffixes, suffix)
-			if runtime.GOOS == "windows" {
-				suffix = fmt.Sprintf("%s%c%s.exe%s", runtime.GOOS, sep, runtime.GOARCH, ext)
-				suffixes = append(suffixes, suffix)
-			}
+// assetSuffixGroups generates the candidates of asset name suffixes for the target OS and arch grouped by arch
+// names in order of preference. On linux, the groups qualified with Config.LibcVariant come first. Extensions of
+// Config.Decompressors are added to each group.
+func (up *Updater) assetSuffixGroups() [][]string {
+	archs := up.targetArchAliases()
+	suffixes := make([][]string, 0, 2*len(archs))
+	var custom []string
+	for _, ext := range up.customExtensions() {
+		if _, builtin := formatsByExtension[ext]; !builtin {
+			custom = append(custom, ext)
 		}
 	}
+	// Assets for the libc variant precede all unqualified assets since an asset for an alias arch still runs
+	if libc := up.targetLibc(); libc != "" {
+		for _, arch := range archs {
+			group := qualifiedAssetSuffixes(up.targetOS(), arch, libc)
+			suffixes = append(suffixes, append(group, extensionSuffixes(up.targetOS(), arch, libc, custom)...))
+		}
+	}
+	for _, arch := range archs {
+		group := assetSuffixes(up.targetOS(), arch)
+		group = append(group, extensionSuffixes(up.targetOS(), arch, "", custom)...)
+		if up.allowPackageAssets {
+			group = append(group, packageAssetSuffixes(up.targetOS(), arch)...)
+		}
+		suffixes = append(suffixes, group)
+	}
+	return suffixes
+}
+
+// findReleasesAndAssets returns releases which have an asset for the current OS and arch. When a release is
+// a candidate but has no suitable asset, it is returned as 'misses'. When 'manifests' is not nil, the asset listed
+// in the manifest of a candidate release is selected instead of the asset found by its name.
+func (up *Updater) findReleasesAndAssets(rels []*github.RepositoryRelease, targetVersion string, manifests *manifestResolver) (out []releaseWithAssets, misses []*NoMatchingAssetError, skipped []SkippedRelease) {
+	suffixes := up.assetSuffixGroups()
 
 	// Find the latest version from the list of releases.
 	// Returned list from GitHub API is in the order of the date when created.
 	//   ref: https://github.com/rhysd/go-github-selfupdate/issues/11
 	for _, rel := range rels {
-		if a, v, ok := findAssetFromRelease(rel, suffixes, targetVersion, filters); ok {
-			out = append(out, releaseWithAssets{RepositoryRelease: rel, ReleaseAsset: a, Version: v})
+		src := rel
+		if up.commandName != "" {
+			src = up.commandRelease(rel, up.commandName)
+		}
+		a, v, err := up.findAssetFromRelease(src, suffixes, targetVersion)
+		var m *github.ReleaseAsset
+		if _, miss := err.(*NoMatchingAssetError); manifests != nil && (err == nil || miss) {
+			ma, validation, found, merr := manifests.resolve(rel)
+			if merr != nil {
+				up.infof("Skip %s since its manifest is invalid: %s", rel.GetTagName(), merr)
+				skipped = append(skipped, SkippedRelease{Tag: rel.GetTagName(), Reason: "invalid manifest"})
+				continue
+			}
+			if found {
+				// The manifest is authoritative. An asset found by its name is ignored when it is not listed
+				a, m, err = ma, validation, nil
+				if ma == nil {
+					err = up.noMatchingAsset(rel, v)
+				}
+			}
+		}
+		if err == nil {
+			out = append(out, releaseWithAssets{RepositoryRelease: rel, ReleaseAsset: a, Version: v, manifest: m})
+			continue
+		}
+		switch err := err.(type) {
+		case *NoMatchingAssetError:
+			misses = append(misses, err)
+			skipped = append(skipped, SkippedRelease{Tag: rel.GetTagName(), Reason: "no asset"})
+		case *skipError:
+			skipped = append(skipped, SkippedRelease{Tag: rel.GetTagName(), Reason: err.reason})
 		}
 	}
 
-	return out
+	return out, misses, skipped
 }
 
 // DetectLatest tries to get the latest version of the repository on GitHub. 'slug' means 'owner/name' formatted string.
 // It fetches releases information from GitHub API and find out the latest release with matching the tag names and asset names.
-// Drafts and pre-releases are ignored. Assets would be suffixed by the OS name and the arch name such as 'foo_linux_amd64'
+// Drafts and pre-releases are ignored unless Config.Prerelease is set. Assets would be suffixed by the OS name and the arch name such as 'foo_linux_amd64'
 // where 'foo' is a command name. '-' can also be used as a separator. File can be compressed with zip, gzip, zxip, tar&zip or tar&zxip.
 // So the asset can have a file extension for the corresponding compression format such as '.zip'.
 // On Windows, '.exe' also can be contained such as 'foo_windows_amd64.exe.zip'.
-func (up *Updater) DetectLatest(ctx context.Context, slug string) (release *Release, found bool, err error) {
-	return up.DetectVersion(ctx, slug, "")
+// The release with the highest version is detected regardless of the order returned from the API. Among releases
+// with the same version, the most recently published one is detected.
+// When releases exist but none of them has an asset for the current OS and arch, found=false is returned unless
+// Config.ReportNoMatchingAsset is set, which returns *NoMatchingAssetError instead. When Config.UseLatestEndpoint is
+// set, only the latest release is fetched from GitHub. When Config.CheckCachePath is set, the result is recorded in the file, and the recorded
+// release is returned without calling GitHub API while the repository was checked within Config.CheckInterval.
+func (up *Updater) DetectLatest(ctx context.Context, slug string) (*Release, bool, error) {
+	if rel, found, ok := up.detectLatestCached(slug); ok {
+		return rel, found, nil
+	}
+	rs, err := up.detectVersions(ctx, slug, "", up.useLatestEndpoint())
+	if err != nil {
+		return nil, false, err
+	}
+	rel, found := pickLatest(rs)
+	if up.checkCachePath != "" {
+		if err := up.writeCheckCache(slug, rel); err != nil {
+			up.infof("Could not record the check: %s", err)
+		}
+	}
+	return rel, found, nil
+}
+
+// defaultConcurrency is the number of detections run in parallel by DetectLatestMulti when Config.Concurrency is not set.
+const defaultConcurrency = 4
+
+// DetectLatestMulti detects the latest releases of multiple repositories in parallel. The number of detections
+// running at the same time is bounded by Config.Concurrency. Results and errors are keyed by slug. When no release
+// is found for a slug, it is mapped to nil in the returned releases. When the context is done, slugs which are not
+// detected yet are mapped to the context's error.
+func (up *Updater) DetectLatestMulti(ctx context.Context, slugs []string) (map[string]*Release, map[string]error) {
+	releases := make(map[string]*Release, len(slugs))
+	errs := map[string]error{}
+
+	n := up.concurrency
+	if n <= 0 {
+		n = defaultConcurrency
+	}
+	if n > len(slugs) {
+		n = len(slugs)
+	}
+
+	queue := make(chan string, len(slugs))
+	seen := make(map[string]struct{}, len(slugs))
+	for _, slug := range slugs {
+		if _, ok := seen[slug]; ok {
+			continue
+		}
+		seen[slug] = struct{}{}
+		queue <- slug
+	}
+	close(queue)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for slug := range queue {
+				var rel *Release
+				err := ctx.Err()
+				if err == nil {
+					var found bool
+					rel, found, err = up.DetectLatest(ctx, slug)
+					if !found {
+						rel = nil
+					}
+				}
+				mu.Lock()
+				if err != nil {
+					errs[slug] = err
+				} else {
+					releases[slug] = rel
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return releases, errs
+}
+
+// DetectLatestAcross detects the latest releases of several repositories such as a primary repository and its fork,
+// and returns the one with the highest version. The repository it came from is known by RepoOwner and RepoName of the
+// returned release. When the highest version is found in several repositories, the release of the first slug wins.
+// Detections run in parallel as DetectLatestMulti. A repository failing to be detected is skipped as long as a
+// release is found in other repositories. When no release is found, the error of the first failed slug is returned.
+func (up *Updater) DetectLatestAcross(ctx context.Context, slugs []string) (*Release, bool, error) {
+	releases, errs := up.DetectLatestMulti(ctx, slugs)
+	var latest *Release
+	var firstErr error
+	for _, slug := range slugs {
+		if err, ok := errs[slug]; ok {
+			up.infof("Failed to detect the latest release of %s: %s", slug, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if r := releases[slug]; r != nil && (latest == nil || r.Version.GT(latest.Version)) {
+			latest = r
+		}
+	}
+	if latest == nil && firstErr != nil {
+		return nil, false, firstErr
+	}
+	return latest, latest != nil, nil
 }
 
-func (up *Updater) DetectVersions(ctx context.Context, slug string, version string) (releases []*Release, err error) {
+// listReleases fetches all releases of the repository via GitHub API following pages until the last page or
+// the maximum number of pages is reached.
+func (up *Updater) listReleases(ctx context.Context, owner, name string, meta *ResponseMetadata) ([]*github.RepositoryRelease, *github.Response, error) {
+	maxPages := up.maxReleasePages
+	if maxPages <= 0 {
+		maxPages = defaultMaxReleasePages
+	}
+
+	var all []*github.RepositoryRelease
+	opts := &github.ListOptions{PerPage: 100}
+	for i := 0; i < maxPages; i++ {
+		rels, res, next, err := up.listReleasesPage(ctx, owner, name, opts)
+		if err != nil {
+			return nil, res, err
+		}
+		meta.update(res, i == 0)
+		all = append(all, rels...)
+		if next == 0 {
+			return all, res, nil
+		}
+		opts.Page = next
+	}
+	up.infof("Stopped fetching releases of %s/%s since the number of pages reached %d", owner, name, maxPages)
+	return all, nil, nil
+}
+
+// listReleasesPage fetches one page of releases and returns the releases with the next page number. When the cache is
+// enabled, it sends a conditional request with the ETag of the previous response and returns the cached releases on 304.
+func (up *Updater) listReleasesPage(ctx context.Context, owner, name string, opts *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, int, error) {
+	if up.cache == nil {
+		rels, res, err := up.api.Repositories.ListReleases(ctx, owner, name, opts)
+		if err != nil {
+			return nil, res, 0, err
+		}
+		return rels, res, res.NextPage, nil
+	}
+
+	u := fmt.Sprintf("repos/%s/%s/releases?page=%d&per_page=%d", owner, name, opts.Page, opts.PerPage)
+	req, err := up.api.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	cached, ok := up.cache.get(u)
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	var rels []*github.RepositoryRelease
+	res, err := up.api.Do(ctx, req, &rels)
+	if ok && res != nil && res.StatusCode == http.StatusNotModified {
+		up.debugf("Releases of %s/%s are not modified. Cached releases are used", owner, name)
+		return cached.releases, res, cached.nextPage, nil
+	}
+	if err != nil {
+		return nil, res, 0, err
+	}
+	if etag := res.Header.Get("ETag"); etag != "" {
+		up.cache.set(u, etag, rels, res.NextPage)
+	}
+	return rels, res, res.NextPage, nil
+}
+
+// parseVersionBound parses a bound of the version range. A plain version is an inclusive bound in the direction
+// specified by 'lower'. Otherwise the bound is parsed as a range such as '<3.0.0' or '2.x'.
+func parseVersionBound(bound string, lower bool) (semver.Range, error) {
+	if v, err := semver.ParseTolerant(bound); err == nil {
+		if lower {
+			return func(o semver.Version) bool { return o.GTE(v) }, nil
+		}
+		return func(o semver.Version) bool { return o.LTE(v) }, nil
+	}
+	return semver.ParseRange(bound)
+}
+
+// parseVersionRange builds the range of candidate versions from Config.MinVersion and Config.MaxVersion. It returns
+// nil when both are empty.
+func parseVersionRange(min, max string) (semver.Range, error) {
+	var r semver.Range
+	if min = strings.TrimSpace(min); min != "" {
+		b, err := parseVersionBound(min, true)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid minimum version %q: %s", min, err)
+		}
+		r = b
+	}
+	if max = strings.TrimSpace(max); max != "" {
+		b, err := parseVersionBound(max, false)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid maximum version %q: %s", max, err)
+		}
+		if r == nil {
+			r = b
+		} else {
+			r = r.AND(b)
+		}
+	}
+	return r, nil
+}
+
+// filterVersionRange drops found releases whose versions are out of Config.MinVersion and Config.MaxVersion. When
+// all of them are dropped, *NoReleaseInRangeError is returned. Dropped releases are also returned as skipped.
+func (up *Updater) filterVersionRange(found []releaseWithAssets) ([]releaseWithAssets, []SkippedRelease, error) {
+	if up.versionRange == nil || len(found) == 0 {
+		return found, nil, nil
+	}
+	var skipped []SkippedRelease
+	in := make([]releaseWithAssets, 0, len(found))
+	latest := found[0].Version
+	for _, f := range found {
+		if f.Version.GT(latest) {
+			latest = f.Version
+		}
+		if !up.versionRange(f.Version) {
+			up.debugf("Skip %s not in the version range", f.GetTagName())
+			skipped = append(skipped, SkippedRelease{Tag: f.GetTagName(), Reason: "out of version range"})
+			continue
+		}
+		in = append(in, f)
+	}
+	if len(in) == 0 {
+		return nil, skipped, &NoReleaseInRangeError{MinVersion: up.minVersion, MaxVersion: up.maxVersion, Latest: latest}
+	}
+	return in, skipped, nil
+}
+
+// DetectStats is the numbers of releases examined and skipped by a detection for observability such as exporting
+// them as metrics. Releases skipped for other reasons such as not matching the version given to DetectVersion or
+// Config.TagPrefix are only counted in Releases.
+type DetectStats struct {
+	// Releases is the number of releases fetched from the release source
+	Releases int
+	// Drafts is the number of skipped drafts
+	Drafts int
+	// Prereleases is the number of skipped pre-releases
+	Prereleases int
+	// NonSemver is the number of releases whose versions could not be extracted from their tags
+	NonSemver int
+	// NoAsset is the number of releases without an asset for the target OS and arch
+	NoAsset int
+	// NoValidationAsset is the number of releases without the validation file of the asset
+	NoValidationAsset int
+	// Filtered is the number of releases dropped by Config.Channel, MinVersion, MaxVersion or ReleaseFilter
+	Filtered int
+	// Candidates is the number of detected releases
+	Candidates int
+}
+
+// newDetectStats counts the skipped releases by their reasons.
+func newDetectStats(examined int, skipped []SkippedRelease, candidates int) DetectStats {
+	s := DetectStats{Releases: examined, Candidates: candidates}
+	for _, r := range skipped {
+		switch {
+		case r.Reason == "draft":
+			s.Drafts++
+		case r.Reason == "pre-release":
+			s.Prereleases++
+		case r.Reason == "unparseable" || strings.HasPrefix(r.Reason, "rejected by version extractor"):
+			s.NonSemver++
+		case r.Reason == "no asset":
+			s.NoAsset++
+		case r.Reason == "no validation file":
+			s.NoValidationAsset++
+		default:
+			s.Filtered++
+		}
+	}
+	return s
+}
+
+// skippedReleases holds the releases skipped by the last detection and its stats. It is safe for concurrent use.
+type skippedReleases struct {
+	mu       sync.Mutex
+	releases []SkippedRelease
+	stats    DetectStats
+}
+
+func (s *skippedReleases) set(rs []SkippedRelease, stats DetectStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releases = rs
+	s.stats = stats
+}
+
+// SkippedReleases returns the releases which were skipped by the last detection with their reasons such as
+// "draft", "pre-release", "unparseable" (the version could not be extracted from the tag) or "no asset" (no asset
+// for the current OS and arch). It is cleared on each detection. When detections run concurrently such as
+// DetectLatestMulti, it is the result of the detection finished last.
+func (up *Updater) SkippedReleases() []SkippedRelease {
+	s := &up.skipped
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := make([]SkippedRelease, len(s.releases))
+	copy(rs, s.releases)
+	return rs
+}
+
+// LastDetectStats returns the stats of the last detection such as how many releases were examined and skipped for
+// each reason. Like SkippedReleases, it is overwritten on each detection.
+func (up *Updater) LastDetectStats() DetectStats {
+	s := &up.skipped
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// DetectVersions detects all releases of the repository which have an asset for the current OS and arch.
+// 'slug' means 'owner/name' formatted string. When version is not empty, only the release whose tag is the version
+// is detected. When releases exist but none of them has a suitable asset, *NoMatchingAssetError is returned if
+// Config.ReportNoMatchingAsset is set. Otherwise no release is returned without error. When
+// releases exist but none of them is in the range of Config.MinVersion and Config.MaxVersion, *NoReleaseInRangeError
+// is returned. When Config.Validator is set and none of the releases has the validation file, an error wrapping
+// ErrValidationAssetNotFound is returned.
+// When the rate limit of GitHub API was exceeded, *RateLimitError is returned.
+func (up *Updater) DetectVersions(ctx context.Context, slug string, version string) ([]*Release, error) {
+	return up.detectVersions(ctx, slug, version, false)
+}
+
+// useLatestEndpoint returns whether the latest release should be fetched with the "latest release" endpoint of
+// GitHub Releases API. It is not available when pre-releases are candidates since the endpoint excludes them.
+func (up *Updater) useLatestEndpoint() bool {
+	return up.latestEndpoint && up.source == nil && up.tagPrefix == "" && up.releaseFilter == nil && !up.prerelease && !up.isPrereleaseChannel()
+}
+
+// detectVersions detects releases of the repository. When 'latestOnly' is true, only the latest release is fetched
+// via the "latest release" endpoint instead of listing all releases.
+func (up *Updater) detectVersions(ctx context.Context, slug string, version string, latestOnly bool) (releases []*Release, err error) {
 	repo := strings.Split(slug, "/")
 	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
 		return nil, fmt.Errorf("Invalid slug format. It should be 'owner/name': %s", slug)
 	}
 
-	rels, res, err := up.api.Repositories.ListReleases(ctx, repo[0], repo[1], nil)
+	var skipped []SkippedRelease
+	examined := 0
+	defer func() { up.skipped.set(skipped, newDetectStats(examined, skipped, len(releases))) }()
+
+	rels, meta, err := up.fetchReleases(ctx, repo[0], repo[1], version, latestOnly)
 	if err != nil {
-		log.Println("API returned an error response:", err)
-		if res != nil && res.StatusCode == 404 {
-			// 404 means repository not found or release not found. It's not an error here.
-			err = nil
-			log.Println("API returned 404. Repository or release not found")
-		}
 		return nil, err
 	}
+	examined = len(rels)
 
-	for _, v := range findReleasesAndAssets(rels, version, up.filters) {
-		url := v.ReleaseAsset.GetBrowserDownloadURL()
-		log.Println("Successfully fetched the latest release. tag:", v.GetTagName(), ", name:", v.RepositoryRelease.GetName(), ", URL:", v.RepositoryRelease.GetURL(), ", Asset:", url)
-
-		publishedAt := v.RepositoryRelease.GetPublishedAt().Time
-		release := &Release{
-			v.Version,
-			url,
-			v.ReleaseAsset.GetSize(),
-			v.ReleaseAsset.GetID(),
-			-1,
-			v.RepositoryRelease.GetHTMLURL(),
-			v.RepositoryRelease.GetBody(),
-			v.RepositoryRelease.GetName(),
-			&publishedAt,
-			repo[0],
-			repo[1],
-		}
-		if up.validator != nil {
-			validationName := v.ReleaseAsset.GetName() + up.validator.Suffix()
-			validationAsset, ok := findValidationAsset(v.RepositoryRelease, validationName)
-			if !ok {
-				log.Printf("Failed finding validation file %q", validationName)
-				continue
+	found, misses, skipped := up.findReleasesAndAssets(rels, version, up.newManifestResolver(ctx, repo[0], repo[1]))
+	found, outOfRange, err := up.filterVersionRange(found)
+	skipped = append(skipped, outOfRange...)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 && len(misses) > 0 && up.reportNoMatchingAsset {
+		// Releases exist but none of them has an asset for this platform. Report the latest one
+		// so that callers can distinguish it from a repository without any release.
+		latest := misses[0]
+		for _, m := range misses[1:] {
+			if m.Version.GT(latest.Version) {
+				latest = m
 			}
-			release.ValidationAssetID = validationAsset.GetID()
+		}
+		return nil, latest
+	}
+
+	var validationErr error
+	for _, v := range found {
+		release, err := up.newRelease(v, repo[0], repo[1], meta)
+		if err != nil {
+			skipped = append(skipped, SkippedRelease{Tag: v.GetTagName(), Reason: "no validation file"})
+			validationErr = err
+			continue
+		}
+		if up.releaseFilter != nil && !up.releaseFilter(release) {
+			up.infof("Skip %s rejected by release filter", v.GetTagName())
+			skipped = append(skipped, SkippedRelease{Tag: v.GetTagName(), Reason: "rejected by release filter"})
+			continue
 		}
 		releases = append(releases, release)
 	}
+	if len(releases) == 0 && validationErr != nil {
+		// Releases have assets for this platform but they cannot be validated. Report it instead of no release
+		return nil, validationErr
+	}
 	return releases, nil
 }
 
+// fetchReleases fetches the releases of the repository from the release source. When latestOnly is true and the
+// source is GitHub, only the latest release is fetched. When version is not empty and the source is GitHub, the
+// release is looked up by its tag at first and all releases are listed only when the tag is not found. The metadata
+// of the responses is returned when the source is GitHub. When the rate limit was exceeded, *RateLimitError is
+// returned.
+func (up *Updater) fetchReleases(ctx context.Context, owner, name, version string, latestOnly bool) ([]*github.RepositoryRelease, *ResponseMetadata, error) {
+	src := up.releaseSource()
+	gs, isGitHub := src.(*gitHubSource)
+	var rels []*github.RepositoryRelease
+	var err error
+	switch {
+	case isGitHub && latestOnly:
+		rels, err = gs.latestRelease(ctx, owner, name)
+	case isGitHub && version != "":
+		rels, err = gs.releaseByTag(ctx, owner, name, up.targetTags(version))
+		if err == nil && len(rels) == 0 {
+			up.debugf("Release of version %s was not found by its tag. Falling back to listing all releases", version)
+			rels, err = src.ListReleases(ctx, owner, name)
+		}
+	default:
+		rels, err = src.ListReleases(ctx, owner, name)
+	}
+	if err != nil {
+		up.infof("API returned an error response: %s", err)
+		if rerr := newRateLimitError(err); rerr != nil {
+			up.poll.recordRate(rerr.Remaining, rerr.Reset)
+			return nil, nil, rerr
+		}
+		return nil, nil, err
+	}
+
+	var meta *ResponseMetadata
+	if isGitHub {
+		meta = gs.meta
+		up.poll.recordRate(meta.Rate.Remaining, meta.Rate.Reset.Time)
+	}
+	return rels, meta, nil
+}
+
+// newRelease creates a release from the found release and asset of the repository 'owner/name'. It returns an error
+// when a validator is set but the validation file for the asset is not found.
+func (up *Updater) newRelease(v releaseWithAssets, owner, name string, meta *ResponseMetadata) (*Release, error) {
+	url := v.ReleaseAsset.GetBrowserDownloadURL()
+	up.infof("Successfully fetched the latest release. tag: %s, name: %s, URL: %s, Asset: %s", v.GetTagName(), v.RepositoryRelease.GetName(), v.RepositoryRelease.GetURL(), url)
+
+	publishedAt := v.RepositoryRelease.GetPublishedAt().Time
+	release := &Release{
+		Version:           v.Version,
+		AssetURL:          url,
+		AssetByteSize:     v.ReleaseAsset.GetSize(),
+		AssetID:           v.ReleaseAsset.GetID(),
+		AssetName:         v.ReleaseAsset.GetName(),
+		ValidationAssetID: -1,
+		URL:               v.RepositoryRelease.GetHTMLURL(),
+		ReleaseNotes:      v.RepositoryRelease.GetBody(),
+		Name:              v.RepositoryRelease.GetName(),
+		PublishedAt:       &publishedAt,
+		Prerelease:        v.RepositoryRelease.GetPrerelease(),
+		Draft:             v.RepositoryRelease.GetDraft(),
+		RepoOwner:         owner,
+		RepoName:          name,
+		Format:            up.assetFormatOf(v.ReleaseAsset),
+		Response:          meta,
+	}
+	if up.api != nil && up.api.BaseURL != nil {
+		release.apiBaseURL = up.api.BaseURL.String()
+	}
+	if len(up.configuredValidators()) == 0 && v.manifest != nil {
+		release.ValidationAssetID = v.manifest.GetID()
+		release.validator = manifestValidator{up.manifestAssetName}
+	} else if len(up.configuredValidators()) > 0 {
+		validator, validationAsset, err := up.findValidator(v.RepositoryRelease, v.ReleaseAsset)
+		if err != nil {
+			up.infof("Failed finding validation file: %s", err)
+			return nil, err
+		}
+		release.ValidationAssetID = validationAsset.GetID()
+		release.validator = validator
+	}
+	if up.allowSplitAssets {
+		parts := splitAssetParts(v.RepositoryRelease, v.ReleaseAsset.GetName())
+		if len(parts) > 0 && parts[0].GetID() == v.ReleaseAsset.GetID() {
+			for _, p := range parts {
+				release.AssetPartIDs = append(release.AssetPartIDs, p.GetID())
+			}
+		}
+	}
+	return release, nil
+}
+
+// isCommandAsset returns whether the asset name is for the command. The name must be the command name followed by
+// a separator and a version or the OS name such as 'foo_linux_amd64.zip' or 'foo-1.2.3-linux-amd64.zip' so that
+// assets of 'foo-helper' are never regarded as assets of 'foo'.
+func isCommandAsset(name, cmd, goos string) bool {
+	name, cmd = strings.ToLower(name), strings.ToLower(cmd)
+	if !strings.HasPrefix(name, cmd) {
+		return false
+	}
+	rest := name[len(cmd):]
+	if rest == "" || (rest[0] != '_' && rest[0] != '-') {
+		return false
+	}
+	rest = rest[1:]
+	return strings.HasPrefix(rest, goos) || reAssetVersionHead.MatchString(rest)
+}
+
+var reAssetVersionHead = regexp.MustCompile(`^v?\d`)
+
+// commandRelease returns a copy of the release which only has the assets for the command.
+func (up *Updater) commandRelease(rel *github.RepositoryRelease, cmd string) *github.RepositoryRelease {
+	c := *rel
+	c.Assets = nil
+	for _, asset := range rel.Assets {
+		if isCommandAsset(asset.GetName(), cmd, up.targetOS()) {
+			c.Assets = append(c.Assets, asset)
+		}
+	}
+	return &c
+}
+
+// detectCommands detects the latest release of the repository which has assets for all the commands. Releases
+// are returned keyed by command names. When no release has assets for all of them, nil is returned.
+func (up *Updater) detectCommands(ctx context.Context, slug string, cmds []string) (map[string]*Release, error) {
+	repo := strings.Split(slug, "/")
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		return nil, fmt.Errorf("Invalid slug format. It should be 'owner/name': %s", slug)
+	}
+
+	rels, meta, err := up.fetchReleases(ctx, repo[0], repo[1], "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := up.assetSuffixGroups()
+	var latest map[string]*Release
+	var latestVer semver.Version
+	for _, rel := range rels {
+		found, ver, ok := up.findCommandAssets(rel, cmds, suffixes, repo[0], repo[1], meta)
+		if ok && (latest == nil || ver.GT(latestVer)) {
+			latest, latestVer = found, ver
+		}
+	}
+	return latest, nil
+}
+
+// findCommandAssets finds the assets for all the commands in the release of the repository 'owner/name'. It returns
+// false when the release is not a candidate or an asset for some command is missing.
+func (up *Updater) findCommandAssets(rel *github.RepositoryRelease, cmds []string, suffixes [][]string, owner, name string, meta *ResponseMetadata) (map[string]*Release, semver.Version, bool) {
+	found := make(map[string]*Release, len(cmds))
+	var ver semver.Version
+	for _, cmd := range cmds {
+		asset, v, err := up.findAssetFromRelease(up.commandRelease(rel, cmd), suffixes, "")
+		if err != nil {
+			up.debugf("Skip %s since no asset for command %q is found: %s", rel.GetTagName(), cmd, err)
+			return nil, ver, false
+		}
+		if up.versionRange != nil && !up.versionRange(v) {
+			up.debugf("Skip %s not in the version range", rel.GetTagName())
+			return nil, ver, false
+		}
+		r, err := up.newRelease(releaseWithAssets{RepositoryRelease: rel, ReleaseAsset: asset, Version: v}, owner, name, meta)
+		if err != nil {
+			return nil, ver, false
+		}
+		if up.releaseFilter != nil && !up.releaseFilter(r) {
+			up.infof("Skip %s rejected by release filter", rel.GetTagName())
+			return nil, ver, false
+		}
+		found[cmd] = r
+		ver = v
+	}
+	return found, ver, true
+}
+
+// DetectVersionsSorted detects all releases of the repository in the same way as DetectVersions, but the returned
+// releases are sorted by their versions in descending order. Releases which have the same version keep the order
+// returned from the API.
+func (up *Updater) DetectVersionsSorted(ctx context.Context, slug string) ([]*Release, error) {
+	rs, err := up.DetectVersions(ctx, slug, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Version.GT(rs[j].Version)
+	})
+	return rs, nil
+}
+
+// ReleaseNotesBetween detects the releases whose versions are greater than 'from' and less than or equal to 'to'
+// in ascending order of their versions. It is useful for showing the cumulative release notes of the versions
+// skipped by an update. Each release has its release notes in ReleaseNotes.
+func (up *Updater) ReleaseNotesBetween(ctx context.Context, slug string, from, to semver.Version) ([]*Release, error) {
+	rs, err := up.DetectVersions(ctx, slug, "")
+	if err != nil {
+		return nil, err
+	}
+	between := make([]*Release, 0, len(rs))
+	for _, r := range rs {
+		if r.Version.GT(from) && r.Version.LTE(to) {
+			between = append(between, r)
+		}
+	}
+	sort.SliceStable(between, func(i, j int) bool {
+		return between[i].Version.LT(between[j].Version)
+	})
+	return between, nil
+}
+
 // DetectVersion tries to get the given version of the repository on Github. `slug` means `owner/name` formatted string.
-// And version indicates the required version.
-func (up *Updater) DetectVersion(ctx context.Context, slug string, version string) (release *Release, found bool, err error) {
+// And version indicates the required version. The release is looked up by its tag with and without 'v' prefix at
+// first, and all releases are listed only when the tag is not found.
+func (up *Updater) DetectVersion(ctx context.Context, slug string, version string) (*Release, bool, error) {
 	rs, err := up.DetectVersions(ctx, slug, version)
 	if err != nil {
 		return nil, false, err
 	}
+	rel, found := pickLatest(rs)
+	return rel, found, nil
+}
+
+// DetectVersionConstraint detects the latest release of the repository whose version satisfies the constraint such
+// as '>=1.2.0 <2.0.0'. The constraint is parsed with semver.ParseRange. When no release satisfies the constraint,
+// found is false.
+func (up *Updater) DetectVersionConstraint(ctx context.Context, slug string, constraint string) (*Release, bool, error) {
+	r, err := semver.ParseRange(constraint)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid version constraint %q: %s", constraint, err)
+	}
+	rs, err := up.DetectVersions(ctx, slug, "")
+	if err != nil {
+		return nil, false, err
+	}
+	satisfied := make([]*Release, 0, len(rs))
+	for _, rel := range rs {
+		if r(rel.Version) {
+			satisfied = append(satisfied, rel)
+		}
+	}
+	rel, found := pickLatest(satisfied)
+	return rel, found, nil
+}
+
+// DetectLatestInMajor detects the latest release of the repository in the major version line such as the latest 1.x
+// release for major 1. It is useful for projects maintaining several release lines in parallel. When no release is in
+// the line, found is false.
+func (up *Updater) DetectLatestInMajor(ctx context.Context, slug string, major uint64) (*Release, bool, error) {
+	rs, err := up.DetectVersions(ctx, slug, "")
+	if err != nil {
+		return nil, false, err
+	}
+	inMajor := make([]*Release, 0, len(rs))
+	for _, rel := range rs {
+		if rel.Version.Major == major {
+			inMajor = append(inMajor, rel)
+		}
+	}
+	rel, found := pickLatest(inMajor)
+	return rel, found, nil
+}
+
+// UpdateAvailable detects the latest release of the repository and reports whether it is newer than the current
+// version. The release is returned only when its version is strictly greater than current. Nothing is downloaded.
+func (up *Updater) UpdateAvailable(ctx context.Context, current semver.Version, slug string) (*Release, bool, error) {
+	rel, found, err := up.DetectLatest(ctx, slug)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found || !rel.Version.GT(current) {
+		return nil, false, nil
+	}
+	return rel, true, nil
+}
+
+// pickLatest returns the release which has the highest version regardless of the order of the releases. When several
+// releases have the same version (e.g. re-tagged releases), the most recently published one is picked. When their
+// publish times are also the same, the first one is picked.
+func pickLatest(rs []*Release) (release *Release, found bool) {
 	for _, v := range rs {
-		if release == nil || v.Version.GTE(release.Version) {
+		if release == nil || v.Version.GT(release.Version) || (v.Version.EQ(release.Version) && publishedAfter(v, release)) {
 			release = v
 			found = true
 		}
@@ -195,6 +1683,18 @@ func (up *Updater) DetectVersion(ctx context.Context, slug string, version strin
 	return
 }
 
+// publishedAfter returns whether the release l was published after the release r. A release without the publish
+// time is regarded as older.
+func publishedAfter(l, r *Release) bool {
+	if l.PublishedAt == nil {
+		return false
+	}
+	if r.PublishedAt == nil {
+		return true
+	}
+	return l.PublishedAt.After(*r.PublishedAt)
+}
+
 // DetectLatest detects the latest release of the slug (owner/repo).
 // This function is a shortcut version of updater.DetectLatest() method.
 func DetectLatest(ctx context.Context, slug string) (*Release, bool, error) {
@@ -205,3 +1705,45 @@ func DetectLatest(ctx context.Context, slug string) (*Release, bool, error) {
 func DetectVersion(ctx context.Context, slug string, version string) (*Release, bool, error) {
 	return DefaultUpdater(ctx).DetectVersion(ctx, slug, version)
 }
+
+// DetectVersionConstraint detects the latest release of the slug (owner/repo) satisfying the version constraint.
+// This function is a shortcut version of updater.DetectVersionConstraint() method.
+func DetectVersionConstraint(ctx context.Context, slug string, constraint string) (*Release, bool, error) {
+	return DefaultUpdater(ctx).DetectVersionConstraint(ctx, slug, constraint)
+}
+
+// DetectLatestInMajor detects the latest release of the slug (owner/repo) in the major version line.
+// This function is a shortcut version of updater.DetectLatestInMajor() method.
+func DetectLatestInMajor(ctx context.Context, slug string, major uint64) (*Release, bool, error) {
+	return DefaultUpdater(ctx).DetectLatestInMajor(ctx, slug, major)
+}
+
+// UpdateAvailable reports whether the latest release of the slug (owner/repo) is newer than the current version.
+// This function is a shortcut version of updater.UpdateAvailable() method.
+func UpdateAvailable(ctx context.Context, current semver.Version, slug string) (*Release, bool, error) {
+	return DefaultUpdater(ctx).UpdateAvailable(ctx, current, slug)
+}
+
+// DetectVersionsSorted detects all releases of the slug (owner/repo) sorted by their versions in descending order.
+// This function is a shortcut version of updater.DetectVersionsSorted() method.
+func DetectVersionsSorted(ctx context.Context, slug string) ([]*Release, error) {
+	return DefaultUpdater(ctx).DetectVersionsSorted(ctx, slug)
+}
+
+// ReleaseNotesBetween detects the releases of the slug (owner/repo) in the range of versions (from, to].
+// This function is a shortcut version of updater.ReleaseNotesBetween() method.
+func ReleaseNotesBetween(ctx context.Context, slug string, from, to semver.Version) ([]*Release, error) {
+	return DefaultUpdater(ctx).ReleaseNotesBetween(ctx, slug, from, to)
+}
+
+// DetectLatestMulti detects the latest releases of multiple repositories in parallel.
+// This function is a shortcut version of updater.DetectLatestMulti() method.
+func DetectLatestMulti(ctx context.Context, slugs []string) (map[string]*Release, map[string]error) {
+	return DefaultUpdater(ctx).DetectLatestMulti(ctx, slugs)
+}
+
+// DetectLatestAcross detects the release with the highest version across several repositories.
+// This function is a shortcut version of updater.DetectLatestAcross() method.
+func DetectLatestAcross(ctx context.Context, slugs []string) (*Release, bool, error) {
+	return DefaultUpdater(ctx).DetectLatestAcross(ctx, slugs)
+}
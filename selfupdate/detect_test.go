@@ -2,11 +2,23 @@ package selfupdate
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/google/go-github/v30/github"
@@ -162,13 +174,21 @@ func TestDetectReleaseButNoAsset(t *testing.T) {
 	if ok {
 		t.Fatal("When no asset found, result should be marked as 'not found'")
 	}
+
+	up, err := NewUpdater(ctx, Config{ReportNoMatchingAsset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "rhysd/clever-f.vim"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("ErrNoMatchingAsset should be returned:", err)
+	}
 }
 
 func TestDetectNoRelease(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	_, ok, err := DetectLatest(ctx, "rhysd/clever-f.vim")
+	_, ok, err := DetectLatest(ctx, "rhysd/misc")
 	if err != nil {
 		t.Fatal("Fetch failed:", err)
 	}
@@ -468,7 +488,9 @@ func TestFindReleaseAndAsset(t *testing.T) {
 			expectedFound: false,
 		},
 	} {
-		asset, ver, found := findAssetFromRelease(fixture.rels, []string{".gz"}, fixture.targetVersion, fixture.filters)
+		up := &Updater{filters: fixture.filters}
+		asset, ver, err := up.findAssetFromRelease(fixture.rels, [][]string{{".gz"}}, fixture.targetVersion)
+		found := err == nil
 		if fixture.expectedFound {
 			if !found {
 				t.Errorf("expected to find an asset for this fixture: %q", fixture.name)
@@ -489,3 +511,2374 @@ func TestFindReleaseAndAsset(t *testing.T) {
 	}
 
 }
+
+func TestArchAliases(t *testing.T) {
+	for _, tc := range []struct {
+		goarch string
+		goarm  string
+		want   []string
+	}{
+		{"amd64", "", []string{"amd64", "x86_64"}},
+		{"arm64", "", []string{"arm64", "aarch64"}},
+		{"386", "", []string{"386"}},
+		{"arm", "5", []string{"arm", "armv5"}},
+		{"arm", "6", []string{"arm", "armv6", "armhf", "armv5"}},
+		{"arm", "7", []string{"arm", "armv7", "armhf", "armv6", "armv5"}},
+		{"arm", "", []string{"arm", "armv7", "armhf", "armv6", "armv5"}},
+	} {
+		got := archAliases(tc.goarch, tc.goarm)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Wanted %v for GOARCH=%s GOARM=%s but got %v", tc.want, tc.goarch, tc.goarm, got)
+		}
+	}
+}
+
+func TestGoarmVersion(t *testing.T) {
+	for v, want := range map[string]string{"7": "7", "6,softfloat": "6", "7,hardfloat": "7", "": ""} {
+		if got := goarmVersion(v); got != want {
+			t.Errorf("Wanted %q for GOARM=%q but got %q", want, v, got)
+		}
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		word string
+		want bool
+	}{
+		{"myapp-Linux-x86_64-release", "linux", true},
+		{"myapp-linux", "linux", true},
+		{"linux_myapp", "linux", true},
+		{"myapp-linuxish-x86_64", "linux", false},
+		{"mylinux-app-linux2-linux", "linux", true},
+		{"myapp-darwin", "win", false},
+		{"myapp-x86_64", "x86_64", true},
+		{"myapp", "", false},
+	} {
+		if got := containsWord(tc.name, tc.word); got != tc.want {
+			t.Errorf("Wanted %v for word %q in %q but got %v", tc.want, tc.word, tc.name, got)
+		}
+	}
+}
+
+func TestFindAssetPreferringExactArch(t *testing.T) {
+	tag := "v1.0.0"
+	armv6 := "foo_linux_armv6.tar.gz"
+	armv7 := "foo_linux_armv7.tar.gz"
+	rel := &github.RepositoryRelease{
+		TagName: &tag,
+		Assets: []*github.ReleaseAsset{
+			{Name: &armv6},
+			{Name: &armv7},
+		},
+	}
+
+	up := &Updater{}
+	suffixes := [][]string{}
+	for _, arch := range archAliases("arm", "7") {
+		suffixes = append(suffixes, assetSuffixes("linux", arch))
+	}
+	asset, _, err := up.findAssetFromRelease(rel, suffixes, "")
+	if err != nil {
+		t.Fatal("No asset was found")
+	}
+	if asset.GetName() != armv7 {
+		t.Error("armv7 asset should be preferred on ARMv7 but got", asset.GetName())
+	}
+
+	suffixes = [][]string{}
+	for _, arch := range archAliases("arm", "6") {
+		suffixes = append(suffixes, assetSuffixes("linux", arch))
+	}
+	asset, _, err = up.findAssetFromRelease(rel, suffixes, "")
+	if err != nil {
+		t.Fatal("No asset was found")
+	}
+	if asset.GetName() != armv6 {
+		t.Error("armv6 asset should be selected on ARMv6 but got", asset.GetName())
+	}
+}
+
+func TestFindAssetWithVersionExtractor(t *testing.T) {
+	tag := "release-2024.01.5"
+	name := "foo_linux_amd64.tar.gz"
+	rel := &github.RepositoryRelease{
+		TagName: &tag,
+		Assets:  []*github.ReleaseAsset{{Name: &name}},
+	}
+	suffixes := [][]string{{"linux_amd64.tar.gz"}}
+
+	up := &Updater{}
+	if _, _, err := up.findAssetFromRelease(rel, suffixes, ""); err == nil {
+		t.Fatal("Calendar version should not be parsed by default extraction")
+	}
+
+	up = &Updater{
+		versionExtractor: VersionExtractorFunc(func(tag string) (semver.Version, error) {
+			return semver.ParseTolerant(strings.Replace(strings.TrimPrefix(tag, "release-"), ".0", ".", -1))
+		}),
+	}
+	_, v, err := up.findAssetFromRelease(rel, suffixes, "")
+	if err != nil {
+		t.Fatal("Release should be found with custom version extractor")
+	}
+	if !v.Equals(semver.MustParse("2024.1.5")) {
+		t.Error("Unexpected version:", v)
+	}
+
+	up = &Updater{
+		versionExtractor: VersionExtractorFunc(func(tag string) (semver.Version, error) {
+			return semver.Version{}, fmt.Errorf("not a version: %s", tag)
+		}),
+	}
+	if _, _, err := up.findAssetFromRelease(rel, suffixes, ""); err == nil {
+		t.Fatal("Release should be skipped when version extractor returns an error")
+	}
+}
+
+func TestFindReleasesWithNoMatchingAsset(t *testing.T) {
+	v1 := "v1.0.0"
+	v2 := "v1.4.0"
+	draft := "v2.0.0"
+	isDraft := true
+	name := "foo_plan9_mips.tar.gz"
+	rels := []*github.RepositoryRelease{
+		{TagName: &v1, Assets: []*github.ReleaseAsset{{Name: &name}}},
+		{TagName: &v2, Assets: []*github.ReleaseAsset{{Name: &name}}},
+		{TagName: &draft, Draft: &isDraft, Assets: []*github.ReleaseAsset{{Name: &name}}},
+	}
+
+	up := &Updater{}
+	found, misses, _ := up.findReleasesAndAssets(rels, "", nil)
+	if len(found) != 0 {
+		t.Fatal("No release should be found but got", len(found))
+	}
+	if len(misses) != 2 {
+		t.Fatal("Draft should not be reported as a near-miss but got", len(misses), "misses")
+	}
+	err := error(misses[1])
+	if !errors.Is(err, ErrNoMatchingAsset) {
+		t.Error("Near-miss should be ErrNoMatchingAsset:", err)
+	}
+	if misses[1].Tag != v2 || !misses[1].Version.Equals(semver.MustParse("1.4.0")) {
+		t.Error("Unexpected release for near-miss:", misses[1].Tag, misses[1].Version)
+	}
+	if len(misses[1].Assets) != 1 || misses[1].Assets[0] != name {
+		t.Error("Unexpected assets for near-miss:", misses[1].Assets)
+	}
+	if !strings.Contains(err.Error(), "Release v1.4.0 exists but has no asset for") {
+		t.Error("Unexpected error message:", err)
+	}
+}
+
+func TestFindReleasesIncludingPrereleases(t *testing.T) {
+	stable := "v1.1.0"
+	rc := "v1.2.0-rc.1"
+	draft := "v1.3.0"
+	yes := true
+	name := "foo_linux_amd64.tar.gz"
+	rels := []*github.RepositoryRelease{
+		{TagName: &draft, Draft: &yes, Assets: []*github.ReleaseAsset{{Name: &name}}},
+		{TagName: &rc, Prerelease: &yes, Assets: []*github.ReleaseAsset{{Name: &name}}},
+		{TagName: &stable, Assets: []*github.ReleaseAsset{{Name: &name}}},
+	}
+	suffixes := [][]string{{"linux_amd64.tar.gz"}}
+
+	for _, tc := range []struct {
+		prerelease bool
+		want       []string
+	}{
+		{false, []string{stable}},
+		{true, []string{rc, stable}},
+	} {
+		up := &Updater{prerelease: tc.prerelease}
+		got := []string{}
+		for _, rel := range rels {
+			if _, _, err := up.findAssetFromRelease(rel, suffixes, ""); err == nil {
+				got = append(got, rel.GetTagName())
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Wanted %v with prerelease=%v but got %v", tc.want, tc.prerelease, got)
+		}
+	}
+
+	if !semver.MustParse("1.2.0-rc.1").LT(semver.MustParse("1.2.0")) {
+		t.Error("Pre-release version should be older than its release")
+	}
+}
+
+func TestDetectVersionsFollowingPages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset := fmt.Sprintf("foo_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		if page != "3" {
+			next := map[string]string{"1": "2", "2": "3"}[page]
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/foo/bar/releases?page=%s&per_page=100>; rel="next"`, ts.URL, next))
+		}
+		fmt.Fprintf(w, `[{"tag_name": "v1.%s.0", "assets": [{"id": 1, "name": %q}]}]`, page, asset)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		maxPages int
+		want     int
+	}{
+		{0, 3},
+		{2, 2},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, MaxReleasePages: tc.maxPages})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rels, err := up.DetectVersions(ctx, "foo/bar", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rels) != tc.want {
+			t.Errorf("Wanted %d releases with MaxReleasePages=%d but got %d", tc.want, tc.maxPages, len(rels))
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := up.DetectVersion(ctx, "foo/bar", "v1.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !r.Version.Equals(semver.MustParse("1.3.0")) {
+		t.Error("Release on the last page should be detected:", r)
+	}
+}
+
+func TestFindAssetWithFilterMode(t *testing.T) {
+	tag := "v1.0.0"
+	names := []string{
+		"myapp-client_linux_amd64.tar.gz",
+		"myapp-server_darwin_amd64.tar.gz",
+		"myapp-server_linux_amd64.tar.gz",
+		"myapp-server-debug_linux_amd64.tar.gz",
+	}
+	assets := []*github.ReleaseAsset{}
+	for i := range names {
+		assets = append(assets, &github.ReleaseAsset{Name: &names[i]})
+	}
+	rel := &github.RepositoryRelease{TagName: &tag, Assets: assets}
+	suffixes := [][]string{{"linux_amd64.tar.gz"}}
+
+	for _, tc := range []struct {
+		mode    FilterMode
+		filters []string
+		want    string
+	}{
+		{FilterAny, []string{"server_"}, "myapp-server_linux_amd64.tar.gz"},
+		{FilterAny, []string{"nothing", "client"}, "myapp-client_linux_amd64.tar.gz"},
+		{FilterAll, []string{"server", "debug"}, "myapp-server-debug_linux_amd64.tar.gz"},
+		{FilterAll, []string{"server", "client"}, ""},
+	} {
+		up := &Updater{filterMode: tc.mode}
+		for _, f := range tc.filters {
+			up.filters = append(up.filters, regexp.MustCompile(f))
+		}
+		asset, _, err := up.findAssetFromRelease(rel, suffixes, "")
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("No asset should be found for %v (mode=%d) but got %s", tc.filters, tc.mode, asset.GetName())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Asset should be found for %v (mode=%d): %s", tc.filters, tc.mode, err)
+			continue
+		}
+		if asset.GetName() != tc.want {
+			t.Errorf("Wanted %s for %v (mode=%d) but got %s", tc.want, tc.filters, tc.mode, asset.GetName())
+		}
+	}
+}
+
+func TestFindAssetSelectingBestCandidate(t *testing.T) {
+	tag := "v1.0.0"
+	suffixes := [][]string{assetSuffixes("windows", "amd64")}
+
+	for _, tc := range []struct {
+		names      []string
+		preference []string
+		preferRaw  bool
+		want       string
+	}{
+		{
+			names: []string{"foo_windows_amd64.tar.gz", "foo_windows_amd64.zip"},
+			want:  "foo_windows_amd64.zip",
+		},
+		{
+			names:      []string{"foo_windows_amd64.zip", "foo_windows_amd64.tar.gz"},
+			preference: []string{".tar.gz"},
+			want:       "foo_windows_amd64.tar.gz",
+		},
+		{
+			names: []string{"foo_windows_amd64", "foo_windows_amd64.exe"},
+			want:  "foo_windows_amd64.exe",
+		},
+		{
+			names:      []string{"foo_windows_amd64.zip", "foo_windows_amd64.exe.zip"},
+			preference: []string{".zip"},
+			want:       "foo_windows_amd64.exe.zip",
+		},
+		{
+			names: []string{"foo-cli_windows_amd64.zip", "bar-cli_windows_amd64.zip"},
+			want:  "bar-cli_windows_amd64.zip",
+		},
+		{
+			names: []string{"foo_windows_amd64", "foo_windows_amd64.tar.gz"},
+			want:  "foo_windows_amd64.tar.gz",
+		},
+		{
+			names:     []string{"foo_windows_amd64.tar.gz", "foo_windows_amd64"},
+			preferRaw: true,
+			want:      "foo_windows_amd64",
+		},
+		{
+			names:      []string{"foo_windows_amd64.zip", "foo_windows_amd64"},
+			preference: []string{".zip"},
+			preferRaw:  true,
+			want:       "foo_windows_amd64",
+		},
+	} {
+		assets := []*github.ReleaseAsset{}
+		for i := range tc.names {
+			assets = append(assets, &github.ReleaseAsset{Name: &tc.names[i]})
+		}
+		rel := &github.RepositoryRelease{TagName: &tag, Assets: assets}
+		up := &Updater{compressionPreference: tc.preference, preferRawAsset: tc.preferRaw}
+		asset, _, err := up.findAssetFromRelease(rel, suffixes, "")
+		if err != nil {
+			t.Errorf("Asset should be found in %v: %s", tc.names, err)
+			continue
+		}
+		if asset.GetName() != tc.want {
+			t.Errorf("Wanted %s in %v (preference=%v, raw=%v) but got %s", tc.want, tc.names, tc.preference, tc.preferRaw, asset.GetName())
+		}
+	}
+}
+
+func TestDetectLatestRegardlessOfOrder(t *testing.T) {
+	ctx := context.Background()
+
+	rels := []string{
+		`{"tag_name": "v1.2.0", "published_at": "2022-01-01T00:00:00Z", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}`,
+		`{"tag_name": "v1.10.0", "published_at": "2020-01-01T00:00:00Z", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}`,
+		`{"tag_name": "1.10.0", "published_at": "2021-01-01T00:00:00Z", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]}`,
+		`{"tag_name": "v1.9.0", "published_at": "2023-01-01T00:00:00Z", "assets": [{"id": 4, "name": "foo_linux_amd64.tar.gz"}]}`,
+		`{"tag_name": "v1.10.0-rc.1", "prerelease": true, "published_at": "2019-01-01T00:00:00Z", "assets": [{"id": 5, "name": "foo_linux_amd64.tar.gz"}]}`,
+		`{"tag_name": "nightly", "published_at": "2024-01-01T00:00:00Z", "assets": [{"id": 6, "name": "foo_linux_amd64.tar.gz"}]}`,
+	}
+	var order []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[")
+		for i, idx := range order {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprint(w, rels[idx])
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Prerelease: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		order = rand.New(rand.NewSource(seed)).Perm(len(rels))
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 1.10.0 is the highest and the one published more recently wins between the two 1.10.0 releases
+		if !ok || r.AssetID != 3 {
+			t.Errorf("Wanted asset 3 for order %v but got %v", order, r)
+		}
+	}
+}
+
+func TestDetectVersionsSorted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset := fmt.Sprintf("foo_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "[")
+		for i, tag := range []string{"v1.2.0", "v2.0.0", "v1.10.0", "1.2.0", "v0.9.0"} {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"tag_name": %q, "assets": [{"id": %d, "name": %q}]}`, tag, i+1, asset)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := up.DetectVersionsSorted(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version string
+		id      int64
+	}{
+		{"2.0.0", 2},
+		{"1.10.0", 3},
+		{"1.2.0", 1},
+		{"1.2.0", 4},
+		{"0.9.0", 5},
+	}
+	if len(rels) != len(want) {
+		t.Fatalf("Wanted %d releases but got %d", len(want), len(rels))
+	}
+	for i, w := range want {
+		if rels[i].Version.String() != w.version || rels[i].AssetID != w.id {
+			t.Errorf("Wanted version %s (asset %d) at %d but got %s (asset %d)", w.version, w.id, i, rels[i].Version, rels[i].AssetID)
+		}
+	}
+}
+
+func TestDetectLatestDarwinUniversalBinary(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [
+				{"id": 1, "name": "foo_darwin_universal.tar.gz"},
+				{"id": 2, "name": "foo_darwin_arm64.tar.gz"},
+				{"id": 3, "name": "foo_linux_amd64.tar.gz"}
+			]}]`)
+		case "/api/v3/repos/foo/all/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 4, "name": "foo-darwin-all.zip"}]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		slug string
+		os   string
+		arch string
+		want int64
+	}{
+		{"foo/bar", "darwin", "arm64", 2},
+		{"foo/bar", "darwin", "amd64", 1},
+		{"foo/bar", "linux", "amd64", 3},
+		{"foo/all", "darwin", "arm64", 4},
+		{"foo/all", "linux", "amd64", 0},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: tc.os, Arch: tc.arch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, tc.slug)
+		if tc.want == 0 {
+			if ok || err != nil {
+				t.Errorf("Universal binary should not be detected for %s/%s: %v", tc.os, tc.arch, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d of %s for %s/%s but got %v", tc.want, tc.slug, tc.os, tc.arch, r)
+		}
+	}
+}
+
+func TestDetectLatestForOtherPlatform(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [
+			{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+			{"id": 2, "name": "foo_darwin_arm64.tar.gz"},
+			{"id": 3, "name": "foo_windows_aarch64.zip"}
+		]}]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		os   string
+		arch string
+		want int64
+	}{
+		{"darwin", "arm64", 2},
+		{"windows", "arm64", 3},
+		{"linux", "amd64", 1},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: tc.os, Arch: tc.arch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d for %s/%s but got %v", tc.want, tc.os, tc.arch, r)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "freebsd", Arch: "386"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := up.DetectLatest(ctx, "foo/bar"); ok || err != nil {
+		t.Fatal("No release should be found without error by default:", ok, err)
+	}
+	if s := up.SkippedReleases(); len(s) == 0 || s[0].Reason != "no asset" {
+		t.Fatal("Release without asset should be reported as skipped:", s)
+	}
+
+	up, err = NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "freebsd", Arch: "386", ReportNoMatchingAsset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = up.DetectLatest(ctx, "foo/bar")
+	var nerr *NoMatchingAssetError
+	if !errors.As(err, &nerr) {
+		t.Fatal("NoMatchingAssetError should be returned:", err)
+	}
+	if nerr.OS != "freebsd" || nerr.Arch != "386" {
+		t.Error("Overridden platform should be reported:", nerr)
+	}
+}
+
+func TestFindAssetWithNameTemplate(t *testing.T) {
+	ctx := context.Background()
+	tag := "v1.2.3"
+	names := []string{
+		"myapp-linux-amd64.tar.gz",
+		"myapp-linux-x86_64-1.2.3",
+		"myapp-darwin-amd64-1.2.3",
+	}
+	assets := []*github.ReleaseAsset{}
+	for i := range names {
+		assets = append(assets, &github.ReleaseAsset{Name: &names[i]})
+	}
+	rel := &github.RepositoryRelease{TagName: &tag, Assets: assets}
+
+	for _, tc := range []struct {
+		tmpl string
+		want string
+	}{
+		{"myapp-{{.OS}}-{{.Arch}}-{{.Version}}", "myapp-linux-x86_64-1.2.3"},
+		{"myapp-{{.OS}}-{{.Arch}}.zip", ""},
+		{"myapp-{{.OS}}-{{.Arch}}-{{.Tag}}", ""},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", AssetNameTemplate: tc.tmpl, OS: "linux", Arch: "amd64"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		asset, _, err := up.findAssetFromRelease(rel, [][]string{{"linux-amd64.tar.gz"}}, "")
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("No asset should match template %q but got %s", tc.tmpl, asset.GetName())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Asset should match template %q: %s", tc.tmpl, err)
+			continue
+		}
+		if asset.GetName() != tc.want {
+			t.Errorf("Wanted %s for template %q but got %s", tc.want, tc.tmpl, asset.GetName())
+		}
+	}
+
+	if _, err := NewUpdater(ctx, Config{APIToken: "hogehoge", AssetNameTemplate: "{{.OS"}); err == nil {
+		t.Error("Broken template should cause an error")
+	}
+}
+
+func TestDetectVersionsRateLimitExceeded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	abuse := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if abuse {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "You have triggered an abuse detection mechanism", "documentation_url": "https://developer.github.com/v3/#abuse-rate-limits"}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = up.DetectVersions(ctx, "foo/bar", "")
+	rerr, ok := err.(*RateLimitError)
+	if !ok {
+		t.Fatal("RateLimitError should be returned:", err)
+	}
+	if rerr.Limit != 60 || rerr.Remaining != 0 || !rerr.Reset.Equal(reset) {
+		t.Errorf("Unexpected rate limit: %#v", rerr)
+	}
+
+	abuse = true
+	up, err = NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = up.DetectVersions(ctx, "foo/bar", "")
+	rerr, ok = err.(*RateLimitError)
+	if !ok {
+		t.Fatal("RateLimitError should be returned for abuse rate limit:", err)
+	}
+	if rerr.Reset.Before(time.Now().Add(20 * time.Second)) {
+		t.Error("Reset should be calculated from Retry-After:", rerr.Reset)
+	}
+}
+
+func TestExtractVersionAllowingNonSemverTags(t *testing.T) {
+	for _, tc := range []struct {
+		tag    string
+		strict string
+		loose  string
+	}{
+		{"v1.2.3", "1.2.3", "1.2.3"},
+		{"2024.06", "", "2024.6.0"},
+		{"v3", "", "3.0.0"},
+		{"release-2024.06.01", "", "2024.6.1"},
+		{"v2-beta.1", "", "2.0.0-beta.1"},
+		{"v4_final", "", "4.0.0"},
+		{"nightly", "", ""},
+	} {
+		for _, allow := range []bool{false, true} {
+			want := tc.strict
+			if allow {
+				want = tc.loose
+			}
+			up := &Updater{allowNonSemverTags: allow}
+			v, ok := up.extractVersion(tc.tag)
+			if want == "" {
+				if ok {
+					t.Errorf("Tag %q should be skipped (allow=%v) but got %s", tc.tag, allow, v)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("Version should be extracted from %q (allow=%v)", tc.tag, allow)
+				continue
+			}
+			if v.String() != want {
+				t.Errorf("Wanted %s for %q (allow=%v) but got %s", want, tc.tag, allow, v)
+			}
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{" v1.2.3\n", "1.2.3"},
+		{"v1.2.3+build.7", "1.2.3+build.7"},
+		{"release-1.2.3-rc.1", "1.2.3-rc.1"},
+		{"v1.2", ""},
+		{"dev", ""},
+		{"v1.2.3-", ""},
+	} {
+		v, err := ParseVersion(tc.input)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("Parsing %q should fail but got %s", tc.input, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parsing %q failed: %s", tc.input, err)
+			continue
+		}
+		if v.String() != tc.want {
+			t.Errorf("Wanted %s for %q but got %s", tc.want, tc.input, v)
+		}
+	}
+
+	cur, err := ParseVersion("v1.2.3+build.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := &Updater{}
+	tag, ok := up.extractVersion("v1.2.3")
+	if !ok || !cur.Equals(tag) {
+		t.Errorf("Build metadata should be ignored on comparing %s with %s", cur, tag)
+	}
+}
+
+func TestExtractVersionWithStrictTagParsing(t *testing.T) {
+	for _, tc := range []struct {
+		tag  string
+		want string
+	}{
+		{"v1.2.3", "1.2.3"},
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3-rc.1", "1.2.3-rc.1"},
+		{"build20.1.3-final", ""},
+		{"release-1.2.3", ""},
+		{"v1.2", ""},
+		{"nightly", ""},
+	} {
+		up := &Updater{strictTagParsing: true}
+		v, ok := up.extractVersion(tc.tag)
+		if tc.want == "" {
+			if ok {
+				t.Errorf("Tag %q should be skipped with strict parsing but got %s", tc.tag, v)
+			}
+			continue
+		}
+		if !ok || v.String() != tc.want {
+			t.Errorf("Wanted %s for %q with strict parsing but got %s (ok=%v)", tc.want, tc.tag, v, ok)
+		}
+	}
+
+	up := &Updater{}
+	if v, ok := up.extractVersion("build20.1.3-final"); !ok || v.String() != "20.1.3-final" {
+		t.Errorf("Prefix should be stripped without strict parsing but got %s (ok=%v)", v, ok)
+	}
+}
+
+func TestDetectLatestMulti(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset := fmt.Sprintf("foo_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/a/releases", "/api/v3/repos/foo/b/releases", "/api/v3/repos/foo/c/releases":
+			fmt.Fprintf(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": %q}]}]`, asset)
+		case "/api/v3/repos/foo/empty/releases":
+			fmt.Fprint(w, `[]`)
+		case "/api/v3/repos/foo/broken/releases":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slugs := []string{"foo/a", "foo/b", "foo/c", "foo/empty", "foo/broken", "foo/a"}
+	rels, errs := up.DetectLatestMulti(ctx, slugs)
+
+	for _, slug := range []string{"foo/a", "foo/b", "foo/c"} {
+		r, ok := rels[slug]
+		if !ok || r == nil || r.Version.String() != "1.2.3" {
+			t.Errorf("Release of %s should be detected: %v", slug, r)
+		}
+	}
+	if r, ok := rels["foo/empty"]; !ok || r != nil {
+		t.Error("Repository without release should be mapped to nil:", r, ok)
+	}
+	if len(errs) != 1 || errs["foo/broken"] == nil {
+		t.Error("Only foo/broken should fail:", errs)
+	}
+	if maxRunning > 2 {
+		t.Error("Detections should be bounded by concurrency 2 but", maxRunning, "ran at the same time")
+	}
+
+	cancel()
+	rels, errs = up.DetectLatestMulti(ctx, []string{"foo/a", "foo/b"})
+	if len(rels) != 0 || len(errs) != 2 || !errors.Is(errs["foo/a"], context.Canceled) {
+		t.Error("Cancelled context should be reported for each slug:", rels, errs)
+	}
+}
+
+func TestDetectLatestAcross(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/primary/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.2.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}]`)
+		case "/api/v3/repos/bar/fork/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.3.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}]`)
+		case "/api/v3/repos/baz/mirror/releases":
+			fmt.Fprint(w, `[{"tag_name": "1.2.0", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]}]`)
+		case "/api/v3/repos/foo/empty/releases":
+			fmt.Fprint(w, `[]`)
+		case "/api/v3/repos/foo/broken/releases":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		slugs []string
+		want  int64
+		owner string
+	}{
+		{[]string{"foo/primary", "bar/fork"}, 2, "bar"},
+		{[]string{"bar/fork", "foo/primary"}, 2, "bar"},
+		{[]string{"foo/primary", "baz/mirror"}, 1, "foo"},
+		{[]string{"baz/mirror", "foo/primary"}, 3, "baz"},
+		{[]string{"foo/broken", "foo/empty", "foo/primary"}, 1, "foo"},
+	} {
+		r, ok, err := up.DetectLatestAcross(ctx, tc.slugs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want || r.RepoOwner != tc.owner {
+			t.Errorf("Wanted asset %d of %s across %v but got %v", tc.want, tc.owner, tc.slugs, r)
+		}
+	}
+
+	if _, ok, err := up.DetectLatestAcross(ctx, []string{"foo/empty"}); err != nil || ok {
+		t.Error("No release should be found without error:", ok, err)
+	}
+	if _, _, err := up.DetectLatestAcross(ctx, []string{"foo/broken", "foo/empty"}); err == nil {
+		t.Error("Error should be returned when all detections failed or found nothing")
+	}
+}
+
+func TestDetectLatestInVersionRange(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v3.0.0", "assets": [{"id": 30, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v2.4.0", "assets": [{"id": 24, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v2.1.0", "assets": [{"id": 21, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.9.0", "assets": [{"id": 19, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		min  string
+		max  string
+		want string
+	}{
+		{"", "", "3.0.0"},
+		{"", "<3.0.0", "2.4.0"},
+		{"", "2.x", "2.4.0"},
+		{"", "2.1.0", "2.1.0"},
+		{"1.0.0", "v2.3.0", "2.1.0"},
+		{">=2.0.0", "", "3.0.0"},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", MinVersion: tc.min, MaxVersion: tc.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.Version.String() != tc.want {
+			t.Errorf("Wanted %s for range (%q, %q) but got %v", tc.want, tc.min, tc.max, r)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", MinVersion: "4.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if ok {
+		t.Fatal("Release should not be found")
+	}
+	var rerr *NoReleaseInRangeError
+	if !errors.As(err, &rerr) || !errors.Is(err, ErrNoReleaseInRange) {
+		t.Fatal("NoReleaseInRangeError should be returned:", err)
+	}
+	if rerr.MinVersion != "4.0.0" || rerr.Latest.String() != "3.0.0" {
+		t.Error("Unexpected error:", rerr)
+	}
+
+	for _, c := range []Config{{MinVersion: "foo"}, {MaxVersion: "<=x.y"}} {
+		if _, err := NewUpdater(ctx, c); err == nil {
+			t.Errorf("Invalid version range (%q, %q) should cause an error", c.MinVersion, c.MaxVersion)
+		}
+	}
+}
+
+func TestDetectLatestSkippingTooSmallAssets(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.2.0", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz", "size": 0}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz", "size": 512}]},
+			{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz", "size": 4096}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		min  int
+		want int64
+	}{
+		{0, 2},
+		{1024, 1},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", MinAssetSize: tc.min})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d with MinAssetSize %d but got %v", tc.want, tc.min, r)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", MinAssetSize: 8192, ReportNoMatchingAsset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("ErrNoMatchingAsset should be returned:", err)
+	}
+}
+
+func TestDetectLatestInChannel(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.3.0-nightly.20240601", "prerelease": true, "assets": [{"id": 5, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.3.0-beta.1", "prerelease": true, "assets": [{"id": 4, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.2.0", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.2.0-beta.2", "prerelease": true, "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		channel string
+		want    int64
+	}{
+		{"", 3},
+		{"stable", 3},
+		{"beta", 4},
+		{"nightly", 5},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Channel: tc.channel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d in channel %q but got %v", tc.want, tc.channel, r)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Channel: "rc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("No release should be found in channel which has no release")
+	}
+}
+
+func TestDetectLatestWithResponseMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"abcdef"`)
+		w.Header().Set("Last-Modified", "Mon, 03 Jun 2024 10:00:00 GMT")
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", "1717408800")
+		fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release should be found")
+	}
+	m := r.Response
+	if m == nil {
+		t.Fatal("Response metadata should be set")
+	}
+	if m.ETag != `"abcdef"` || m.LastModified != "Mon, 03 Jun 2024 10:00:00 GMT" {
+		t.Error("Unexpected headers:", m.ETag, m.LastModified)
+	}
+	if m.Rate.Limit != 5000 || m.Rate.Remaining != 4321 || m.Rate.Reset.Unix() != 1717408800 {
+		t.Error("Unexpected rate limit:", m.Rate)
+	}
+}
+
+func TestDetectLatestPackageAsset(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.2.0", "assets": [
+				{"id": 3, "name": "foo_linux_amd64.deb", "browser_download_url": "https://example.com/foo_linux_amd64.deb"},
+				{"id": 4, "name": "foo_linux_amd64.rpm", "browser_download_url": "https://example.com/foo_linux_amd64.rpm"}
+			]},
+			{"tag_name": "v1.1.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.deb"},
+				{"id": 2, "name": "foo_linux_amd64.tar.gz"}
+			]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.AssetID != 2 {
+		t.Fatal("Package assets should be ignored by default:", r)
+	}
+
+	up, err = NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", AllowPackageAssets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 2 {
+		t.Fatal("Two releases should be detected:", rels)
+	}
+	if rels[0].AssetID != 3 || rels[0].AssetURL != "https://example.com/foo_linux_amd64.deb" {
+		t.Error("Package asset should be detected:", rels[0])
+	}
+	if rels[1].AssetID != 2 {
+		t.Error("Archive should be preferred over package:", rels[1])
+	}
+	if rels[0].Format != FormatDeb || rels[1].Format != FormatTarGz {
+		t.Error("Unexpected formats:", rels[0].Format, rels[1].Format)
+	}
+
+	err = up.UpdateTo(ctx, rels[0], "/path/to/foo")
+	if !errors.Is(err, ErrPackageAsset) {
+		t.Fatal("ErrPackageAsset should be returned:", err)
+	}
+	if !strings.Contains(err.Error(), "package manager") {
+		t.Error("Unexpected error message:", err)
+	}
+}
+
+func TestDetectLatestWithLatestEndpoint(t *testing.T) {
+	ctx := context.Background()
+
+	calls := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.2.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}`)
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.3.0-beta.1", "prerelease": true, "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]},
+				{"tag_name": "v1.2.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}
+			]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", UseLatestEndpoint: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.AssetID != 2 {
+		t.Fatal("Latest release should be detected:", r)
+	}
+	if calls["/api/v3/repos/foo/bar/releases/latest"] != 1 || calls["/api/v3/repos/foo/bar/releases"] != 0 {
+		t.Fatal("Only the latest release endpoint should be called:", calls)
+	}
+
+	// Other detections list all releases
+	if _, _, err := up.DetectVersion(ctx, "foo/bar", "v1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if calls["/api/v3/repos/foo/bar/releases"] != 1 {
+		t.Fatal("Releases should be listed for requesting specific version:", calls)
+	}
+
+	up, err = NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", UseLatestEndpoint: true, Prerelease: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err = up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.AssetID != 3 {
+		t.Fatal("Pre-release should be detected by listing releases:", r)
+	}
+
+	_, ok, err = up.DetectLatest(ctx, "foo/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Release should not be found for unknown repository")
+	}
+}
+
+func TestReleaseNotesBetween(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.7.0", "body": "notes 1.7.0", "assets": [{"id": 7, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.6.0", "body": "notes 1.6.0", "assets": [{"id": 6, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.3.0", "body": "notes 1.3.0", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.5.0", "body": "notes 1.5.0", "assets": [{"id": 5, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.2.0", "body": "notes 1.2.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := up.ReleaseNotesBetween(ctx, "foo/bar", semver.MustParse("1.2.0"), semver.MustParse("1.6.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.3.0", "1.5.0", "1.6.0"}
+	if len(rs) != len(want) {
+		t.Fatal("Unexpected releases:", rs)
+	}
+	for i, r := range rs {
+		if r.Version.String() != want[i] {
+			t.Errorf("Wanted %s at %d but got %s", want[i], i, r.Version)
+		}
+		if r.ReleaseNotes != "notes "+want[i] {
+			t.Errorf("Unexpected release notes for %s: %q", r.Version, r.ReleaseNotes)
+		}
+	}
+}
+
+func TestAssetFormat(t *testing.T) {
+	for name, want := range map[string]AssetFormat{
+		"foo_linux_amd64.zip":       FormatZip,
+		"foo_windows_amd64.exe.zip": FormatZip,
+		"foo_linux_amd64.tar.gz":    FormatTarGz,
+		"foo_linux_amd64.tgz":       FormatTarGz,
+		"foo_linux_amd64.gz":        FormatGzip,
+		"foo_linux_amd64.gzip":      FormatGzip,
+		"foo_linux_amd64.tar.xz":    FormatTarXz,
+		"foo_linux_amd64.xz":        FormatXz,
+		"foo_linux_amd64.tar.zst":   FormatTarZst,
+		"foo_linux_amd64.tzst":      FormatTarZst,
+		"foo_linux_amd64.zst":       FormatZst,
+		"foo_linux_amd64.tar.bz2":   FormatTarBz2,
+		"foo_linux_amd64.bz2":       FormatBz2,
+		"foo_linux_amd64.tar":       FormatTar,
+		"foo_linux_amd64.7z":        Format7z,
+		"foo_linux_amd64.deb":       FormatDeb,
+		"foo_linux_amd64.rpm":       FormatRpm,
+		"foo_linux_amd64":           FormatRaw,
+		"foo_windows_amd64.exe":     FormatRaw,
+	} {
+		if f := assetFormat(name); f != want {
+			t.Errorf("Wanted format %q for %q but got %q", want, name, f)
+		}
+	}
+}
+
+func TestDetectVersionConstraint(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.1.0", "assets": [{"id": 21, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.9.0", "assets": [{"id": 19, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.4.2", "assets": [{"id": 14, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 11, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		constraint string
+		want       string
+	}{
+		{">=1.2.0 <2.0.0", "1.9.0"},
+		{"<1.5.0", "1.4.2"},
+		{">=2.0.0", "2.1.0"},
+		{"1.1.0", "1.1.0"},
+		{">=3.0.0", ""},
+	} {
+		r, ok, err := up.DetectVersionConstraint(ctx, "foo/bar", tc.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want == "" {
+			if ok {
+				t.Errorf("No release should be found for %q but got %v", tc.constraint, r.Version)
+			}
+			continue
+		}
+		if !ok || r.Version.String() != tc.want {
+			t.Errorf("Wanted %s for %q but got %v", tc.want, tc.constraint, r)
+		}
+	}
+
+	if _, _, err := up.DetectVersionConstraint(ctx, "foo/bar", ">=foo"); err == nil {
+		t.Error("Invalid constraint should cause an error")
+	}
+}
+
+func TestDetectLatestInMajor(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.0.1", "assets": [{"id": 21, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.8.3", "assets": [{"id": 18, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v2.0.0", "assets": [{"id": 20, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.9.0-beta", "prerelease": true, "assets": [{"id": 19, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.8.0", "assets": [{"id": 10, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v0.9.0", "assets": [{"id": 9, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		major uint64
+		want  string
+	}{
+		{0, "0.9.0"},
+		{1, "1.8.3"},
+		{2, "2.0.1"},
+		{3, ""},
+	} {
+		r, ok, err := up.DetectLatestInMajor(ctx, "foo/bar", tc.major)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want == "" {
+			if ok {
+				t.Errorf("No release should be found for major %d but got %v", tc.major, r.Version)
+			}
+			continue
+		}
+		if !ok || r.Version.String() != tc.want {
+			t.Errorf("Wanted %s for major %d but got %v", tc.want, tc.major, r)
+		}
+	}
+}
+
+func TestUpdateAvailable(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.2.0", "assets": [{"id": 12, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 11, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		current   string
+		available bool
+	}{
+		{"1.1.0", true},
+		{"1.2.0", false},
+		{"1.3.0", false},
+	} {
+		r, ok, err := up.UpdateAvailable(ctx, semver.MustParse(tc.current), "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.available {
+			t.Errorf("Update availability for %s should be %v but got %v", tc.current, tc.available, ok)
+			continue
+		}
+		if !ok {
+			if r != nil {
+				t.Errorf("Release should be nil when no update is available for %s but got %v", tc.current, r.Version)
+			}
+			continue
+		}
+		if r.Version.String() != "1.2.0" {
+			t.Errorf("Newer release should be 1.2.0 but got %v", r.Version)
+		}
+	}
+}
+
+func TestDetectLatestCapitalizedAssetNames(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "MyApp_Darwin_arm64.tar.gz"},
+				{"id": 2, "name": "MyApp_Windows_x86_64.zip"},
+				{"id": 3, "name": "MyApp_Linuxfoo_amd64.tar.gz"}
+			]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		os    string
+		arch  string
+		want  int64
+		found bool
+	}{
+		{"darwin", "arm64", 1, true},
+		{"windows", "amd64", 2, true},
+		{"linux", "amd64", 0, false},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: tc.os, Arch: tc.arch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if !tc.found {
+			if ok {
+				t.Errorf("No asset should be found for %s/%s but got %q", tc.os, tc.arch, r.AssetName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Asset #%d should be found for %s/%s but got %v", tc.want, tc.os, tc.arch, r)
+		}
+	}
+}
+
+func TestDetectLatestAllowedFormats(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.zip", "browser_download_url": "https://example.com/foo_linux_amd64.zip"},
+				{"id": 2, "name": "foo_linux_amd64.tar.gz", "browser_download_url": "https://example.com/foo_linux_amd64.tar.gz"},
+				{"id": 3, "name": "foo_linux_amd64.xz", "browser_download_url": "https://example.com/foo_linux_amd64.xz"}
+			]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		allowed []AssetFormat
+		want    int64
+	}{
+		{nil, 1},
+		{[]AssetFormat{FormatTarGz}, 2},
+		{[]AssetFormat{FormatXz, FormatTarGz}, 2},
+		{[]AssetFormat{FormatXz}, 3},
+		{[]AssetFormat{Format7z}, 0},
+	} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", AllowedFormats: tc.allowed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if tc.want == 0 {
+			if ok {
+				t.Errorf("No asset should be found with %v but got %q", tc.allowed, r.AssetName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Asset #%d should be found with %v but got %v", tc.want, tc.allowed, r)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, AllowedFormats: []AssetFormat{FormatTarGz}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := &Release{AssetURL: "https://example.com/foo_linux_amd64.zip", AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+	err = up.UpdateTo(ctx, rel, "not-exist-command")
+	if err == nil {
+		t.Fatal("Error should occur for asset in disallowed format")
+	}
+	uerr, ok := err.(*UpdateError)
+	if !ok || uerr.Stage != StageDownload || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestDetectLatestSkippedReleases(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "nightly", "draft": true, "assets": [{"id": 5, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v-broken", "assets": [{"id": 4, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v2.0.0", "assets": [{"id": 3, "name": "foo_darwin_amd64.tar.gz"}]},
+			{"tag_name": "v1.1.0-beta", "prerelease": true, "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := up.SkippedReleases(); len(s) != 0 {
+		t.Fatal("No release should be skipped before detection:", s)
+	}
+
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.AssetID != 1 {
+		t.Fatal("v1.0.0 should be detected but got", r)
+	}
+
+	want := []SkippedRelease{
+		{"nightly", "draft"},
+		{"v-broken", "unparseable"},
+		{"v2.0.0", "no asset"},
+		{"v1.1.0-beta", "pre-release"},
+	}
+	skipped := up.SkippedReleases()
+	if !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("Skipped releases are unexpected: %v, want %v", skipped, want)
+	}
+	if s := skipped[1].String(); s != "v-broken (unparseable)" {
+		t.Error("Unexpected string representation:", s)
+	}
+	wantStats := DetectStats{Releases: 5, Drafts: 1, Prereleases: 1, NonSemver: 1, NoAsset: 1, Candidates: 1}
+	if stats := up.LastDetectStats(); stats != wantStats {
+		t.Fatalf("Stats of detection are unexpected: %+v, want %+v", stats, wantStats)
+	}
+
+	// Skipped releases are cleared on each detection
+	if _, err := up.DetectVersions(ctx, "foo/bar", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if s := up.SkippedReleases(); len(s) != 0 {
+		t.Fatal("No release should be skipped when detecting the specific version:", s)
+	}
+	if stats := up.LastDetectStats(); stats != (DetectStats{Releases: 5, Candidates: 1}) {
+		t.Fatalf("Stats should be overwritten on each detection: %+v", stats)
+	}
+}
+
+func TestDetectStatsFiltered(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v3.0.0", "assets": [{"id": 4, "name": "foo_linux_amd64.tar.gz"}, {"id": 40, "name": "foo_linux_amd64.tar.gz.sha256"}]},
+			{"tag_name": "v2.0.0", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}, {"id": 30, "name": "foo_linux_amd64.tar.gz.sha256"}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}, {"id": 10, "name": "foo_linux_amd64.tar.gz.sha256"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{},
+		MaxVersion:        "2.0.0",
+		ReleaseFilter:     func(r *Release) bool { return r.Version.String() != "2.0.0" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0].AssetID != 1 {
+		t.Fatal("Only v1.0.0 should be detected:", rs)
+	}
+	want := DetectStats{Releases: 4, NoValidationAsset: 1, Filtered: 2, Candidates: 1}
+	if stats := up.LastDetectStats(); stats != want {
+		t.Fatalf("Stats of detection are unexpected: %+v, want %+v", stats, want)
+	}
+}
+
+func TestDetectLatestWithReleaseFilter(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.2.0", "name": "broken release", "assets": [{"id": 3, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.1.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	var called []string
+	yanked := semver.MustParse("1.1.0")
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		UseLatestEndpoint: true,
+		ReleaseFilter: func(r *Release) bool {
+			called = append(called, r.Version.String())
+			return r.Name != "broken release" && !r.Version.Equals(yanked)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.AssetID != 1 {
+		t.Fatal("v1.0.0 should be detected but got", r)
+	}
+	if want := []string{"1.2.0", "1.1.0", "1.0.0"}; !reflect.DeepEqual(called, want) {
+		t.Errorf("Release filter should be called with %v but got %v", want, called)
+	}
+	want := []SkippedRelease{
+		{"v1.2.0", "rejected by release filter"},
+		{"v1.1.0", "rejected by release filter"},
+	}
+	if skipped := up.SkippedReleases(); !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("Skipped releases are unexpected: %v, want %v", skipped, want)
+	}
+}
+
+func TestDetectVersionAllowingDrafts(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"tag_name": "v2.0.0", "draft": true, "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	for _, allow := range []bool{false, true} {
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", AllowDrafts: allow})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, ok, err := up.DetectVersion(ctx, "foo/bar", "v2.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != allow {
+			t.Errorf("Draft detection with AllowDrafts=%v is unexpected: %v", allow, r)
+		}
+		if ok && r.AssetID != 2 {
+			t.Error("Asset of the draft should be detected but got", r.AssetID)
+		}
+		if ok && !r.Draft {
+			t.Error("Detected release should be marked as draft")
+		}
+
+		r, ok, err = up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.Version.String() != "1.0.0" {
+			t.Errorf("Draft should not be detected as the latest with AllowDrafts=%v but got %v", allow, r)
+		}
+		if r.Draft || r.Prerelease {
+			t.Errorf("Latest release should not be marked as draft or pre-release: %+v", r)
+		}
+	}
+}
+
+func TestDetectLatestPrereleaseFlag(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"tag_name": "v1.5.0-rc1", "prerelease": true, "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]},
+			{"tag_name": "v1.4.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}
+		]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Prerelease: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 2 {
+		t.Fatal("Both releases should be detected but got", rels)
+	}
+	for _, r := range rels {
+		want := r.Version.String() == "1.5.0-rc1"
+		if r.Prerelease != want {
+			t.Errorf("Prerelease flag of %s should be %v", r.Version, want)
+		}
+		if r.Draft {
+			t.Errorf("%s should not be marked as draft", r.Version)
+		}
+	}
+}
+
+func TestDetectPathPrefixedTags(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/mono/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "other/v2.0.0", "assets": [{"id": 20, "name": "other_linux_amd64.tar.gz"}]},
+				{"tag_name": "service/v1.4.2", "assets": [{"id": 142, "name": "service_linux_amd64.tar.gz"}]},
+				{"tag_name": "service/v1.3.0", "assets": [{"id": 130, "name": "service_linux_amd64.tar.gz"}]}
+			]`)
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.4.2", "assets": [{"id": 1, "name": "bar_linux_amd64.tar.gz"}]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.TagPrefix = "service/"
+	scoped, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		what    string
+		up      *Updater
+		slug    string
+		version string
+		want    int64
+	}{
+		{"full path-prefixed tag", up, "foo/mono", "service/v1.4.2", 142},
+		{"other component", up, "foo/mono", "other/v2.0.0", 20},
+		{"version part with prefix", scoped, "foo/mono", "v1.4.2", 142},
+		{"version part without 'v'", scoped, "foo/mono", "1.3.0", 130},
+		{"tag out of prefix", scoped, "foo/mono", "other/v2.0.0", 0},
+		{"plain tag", up, "foo/bar", "v1.4.2", 1},
+		{"plain tag without 'v'", up, "foo/bar", "1.4.2", 1},
+		{"different path prefix", up, "foo/mono", "another/v1.4.2", 0},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			r, ok, err := tc.up.DetectVersion(ctx, tc.slug, tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == 0 {
+				if ok {
+					t.Fatalf("No release should be detected for %q but got %q", tc.version, r.AssetName)
+				}
+				return
+			}
+			if !ok || r.AssetID != tc.want {
+				t.Fatalf("Asset #%d should be detected for %q but got %v", tc.want, tc.version, r)
+			}
+		})
+	}
+
+	r, ok, err := scoped.DetectLatest(ctx, "foo/mono")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.Version.String() != "1.4.2" {
+		t.Fatal("Latest release of the component should be 1.4.2 but got", r)
+	}
+	r, ok, err = up.DetectLatest(ctx, "foo/mono")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || r.Version.String() != "2.0.0" {
+		t.Fatal("Version should be extracted from path-prefixed tag but got", r)
+	}
+}
+
+func TestDetectLatestValidationAssetSuffixSpellings(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/upper/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 2, "name": "foo_linux_amd64.tar.gz.SHA256"}
+			]}]`)
+		case "/api/v3/repos/foo/sum/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 3, "name": "foo_linux_amd64.tar.gz.sha256sum"}
+			]}]`)
+		case "/api/v3/repos/foo/dash/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 4, "name": "foo_linux_amd64.tar.gz.SHA-256"}
+			]}]`)
+		case "/api/v3/repos/foo/preferred/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 3, "name": "foo_linux_amd64.tar.gz.sha256sum"},
+				{"id": 5, "name": "foo_linux_amd64.tar.gz.sha256"}
+			]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		slug string
+		want int64
+	}{
+		{"foo/upper", 2},
+		{"foo/sum", 3},
+		{"foo/dash", 4},
+		{"foo/preferred", 5},
+	} {
+		r, ok, err := up.DetectLatest(ctx, tc.slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.ValidationAssetID != tc.want {
+			t.Errorf("Validation asset #%d should be found for %s but got %v", tc.want, tc.slug, r)
+		}
+	}
+
+	up, err = NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{FileSuffixes: []string{".sha256"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/sum"); !errors.Is(err, ErrValidationAssetNotFound) {
+		t.Error("Suffix not in FileSuffixes should not be accepted:", err)
+	}
+}
+
+func TestDetectLatestValidationAssetMatchingFilters(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/exact/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 2, "name": "foo_linux_amd64.tar.gz.sha256"},
+				{"id": 3, "name": "foo_1.0.0_linux_amd64.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/filtered/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 3, "name": "foo_1.0.0_linux_amd64.sha256"},
+				{"id": 4, "name": "foo_1.0.0_darwin_amd64.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/ambiguous/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 3, "name": "foo_1.0.0_linux_amd64.sha256"},
+				{"id": 5, "name": "foo_1.0.0_linux_amd64_static.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/missing/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{},
+		Filters:           []string{"linux_amd64"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		slug string
+		want int64
+	}{
+		{"foo/exact", 2},
+		{"foo/filtered", 3},
+		{"foo/ambiguous", 0},
+		{"foo/missing", 0},
+	} {
+		r, ok, err := up.DetectLatest(ctx, tc.slug)
+		if tc.want == 0 {
+			if !errors.Is(err, ErrValidationAssetNotFound) {
+				t.Errorf("ErrValidationAssetNotFound should be returned for %s but got %v (found=%v)", tc.slug, err, ok)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.ValidationAssetID != tc.want {
+			t.Errorf("Validation asset #%d should be found for %s but got %v", tc.want, tc.slug, r)
+		}
+	}
+}
+
+func TestDetectLatestLibcVariant(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [
+				{"id": 1, "name": "foo_linux_amd64.tar.gz"},
+				{"id": 2, "name": "foo_linux_amd64_musl.tar.gz"},
+				{"id": 3, "name": "foo-linux-x86_64-gnu"},
+				{"id": 4, "name": "foo_darwin_amd64.tar.gz"}
+			]}]`)
+		case "/api/v3/repos/foo/unqualified/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 5, "name": "foo_linux_amd64.tar.gz"}]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		slug string
+		os   string
+		libc string
+		want int64
+	}{
+		{"foo/bar", "linux", "", 1},
+		{"foo/bar", "linux", "musl", 2},
+		{"foo/bar", "linux", "gnu", 3},
+		{"foo/bar", "darwin", "musl", 4},
+		{"foo/unqualified", "linux", "musl", 5},
+	} {
+		up, err := NewUpdater(ctx, Config{
+			APIToken:          "hogehoge",
+			EnterpriseBaseURL: ts.URL,
+			OS:                tc.os,
+			Arch:              "amd64",
+			LibcVariant:       tc.libc,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, ok, err := up.DetectLatest(ctx, tc.slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d of %s for %s with libc %q but got %v", tc.want, tc.slug, tc.os, tc.libc, r)
+		}
+	}
+}
+
+func TestDetectLibc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-libc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orig := libcProbes
+	defer func() { libcProbes = orig }()
+	libcProbes = []string{filepath.Join(dir, "alpine-release"), filepath.Join(dir, "ld-musl-*")}
+
+	if libc := detectLibc(); libc != "gnu" {
+		t.Fatal("gnu should be detected without musl files but got", libc)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ld-musl-x86_64.so.1"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if libc := detectLibc(); libc != "musl" {
+		t.Fatal("musl should be detected with musl dynamic loader but got", libc)
+	}
+}
+
+func TestReleaseJSON(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{
+			"tag_name": "v1.2.3",
+			"name": "Release 1.2.3",
+			"html_url": "https://github.com/foo/bar/releases/tag/v1.2.3",
+			"body": "Fix bugs",
+			"published_at": "2020-01-02T03:04:05Z",
+			"assets": [{"id": 1, "name": "bar_linux_amd64.tar.gz", "size": 42, "browser_download_url": "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64.tar.gz"}]
+		}]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release should be detected")
+	}
+
+	b, err := json.Marshal(r.Info())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":"1.2.3","name":"Release 1.2.3","url":"https://github.com/foo/bar/releases/tag/v1.2.3",` +
+		`"asset_url":"https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64.tar.gz",` +
+		`"asset_name":"bar_linux_amd64.tar.gz","asset_size":42,"format":"tar.gz",` +
+		`"published_at":"2020-01-02T03:04:05Z","prerelease":false,"draft":false,"release_notes":"Fix bugs",` +
+		`"repository":"foo/bar"}`
+	if string(b) != want {
+		t.Fatalf("Unexpected JSON:\n  want: %s\n  got:  %s", want, b)
+	}
+
+	// Release itself is encoded with all its fields so that it can be decoded and passed to UpdateTo later
+	rb, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Release
+	if err := json.Unmarshal(rb, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.AssetID != 1 || decoded.AssetURL != r.AssetURL || !decoded.Version.Equals(r.Version) {
+		t.Fatalf("Release should be round-tripped through JSON: %#v", decoded)
+	}
+
+	var info ReleaseInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, r.Info()) {
+		t.Fatalf("JSON should be decoded into ReleaseInfo: %#v", info)
+	}
+
+	b, err = json.Marshal((&Release{Version: semver.MustParse("0.1.0"), AssetURL: "https://example.com/foo"}).Info())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"version":"0.1.0","asset_url":"https://example.com/foo","asset_size":0,"prerelease":false,"draft":false}`
+	if string(b) != want {
+		t.Fatal("Empty optional fields should be omitted:", string(b))
+	}
+}
+
+func TestDetectVersionByTag(t *testing.T) {
+	ctx := context.Background()
+
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases/tags/v1.2.3":
+			fmt.Fprint(w, `{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]}`)
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": "foo_linux_amd64.tar.gz"}]},
+				{"tag_name": "myservice/v1.0.0", "assets": [{"id": 2, "name": "foo_linux_amd64.tar.gz"}]}
+			]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		version  string
+		want     int64
+		requests []string
+	}{
+		{"v1.2.3", 1, []string{"/api/v3/repos/foo/bar/releases/tags/v1.2.3"}},
+		{"1.2.3", 1, []string{"/api/v3/repos/foo/bar/releases/tags/1.2.3", "/api/v3/repos/foo/bar/releases/tags/v1.2.3"}},
+		{"1.0.0", 2, []string{
+			"/api/v3/repos/foo/bar/releases/tags/1.0.0",
+			"/api/v3/repos/foo/bar/releases/tags/v1.0.0",
+			"/api/v3/repos/foo/bar/releases",
+		}},
+		{"myservice/v1.0.0", 2, []string{"/api/v3/repos/foo/bar/releases/tags/myservice/v1.0.0", "/api/v3/repos/foo/bar/releases"}},
+	} {
+		requests = nil
+		r, ok, err := up.DetectVersion(ctx, "foo/bar", tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || r.AssetID != tc.want {
+			t.Errorf("Wanted asset %d for version %s but got %v", tc.want, tc.version, r)
+		}
+		if !reflect.DeepEqual(requests, tc.requests) {
+			t.Errorf("Unexpected requests for version %s: %v", tc.version, requests)
+		}
+	}
+}
+
+func TestReleaseAPIDownloadURL(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 42, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"}
+			]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/42":
+			if r.Header.Get("Accept") != "application/octet-stream" || r.Header.Get("Authorization") != "token hogehoge" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "asset")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release should be detected")
+	}
+	u := rel.APIDownloadURL()
+	if want := ts.URL + "/api/v3/repos/foo/bar/releases/assets/42"; u != want {
+		t.Fatalf("Wanted %q but got %q", want, u)
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	req.Header.Set("Authorization", "token hogehoge")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || string(b) != "asset" {
+		t.Fatalf("Asset should be downloaded from API URL but got %d %q", res.StatusCode, b)
+	}
+
+	rel = &Release{AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+	if u := rel.APIDownloadURL(); u != "https://api.github.com/repos/foo/bar/releases/assets/1" {
+		t.Fatal("API URL of github.com should be used by default:", u)
+	}
+	rel = &Release{AssetURL: "https://example.com/bar.zip"}
+	if u := rel.APIDownloadURL(); u != "" {
+		t.Fatal("API URL should be empty when asset ID is not known:", u)
+	}
+}
+
+func TestDetectWithManifest(t *testing.T) {
+	ctx := context.Background()
+	asset := zipScript(t, "bar", "new binary")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(asset))
+	manifest := fmt.Sprintf(`{"assets": [
+		{"os": "darwin", "arch": "amd64", "name": "myapp-macos-x64.zip", "sha256": "deadbeef"},
+		{"os": "linux", "arch": "amd64", "name": "myapp-linux-x64.zip", "sha256": %q}
+	]}`, checksum)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.1.0", "assets": [
+					{"id": 1, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.1.0/bar_linux_amd64.zip"},
+					{"id": 2, "name": "myapp-linux-x64.zip", "browser_download_url": "https://example.com/v1.1.0/myapp-linux-x64.zip"},
+					{"id": 3, "name": "manifest.json", "browser_download_url": "https://example.com/v1.1.0/manifest.json"}
+				]},
+				{"tag_name": "v1.0.0", "assets": [
+					{"id": 4, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"}
+				]}
+			]`)
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			w.Write(asset)
+		case "/api/v3/repos/foo/bar/releases/assets/3":
+			fmt.Fprint(w, manifest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", ManifestAssetName: "manifest.json"}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.AssetID != 2 || rel.ValidationAssetID != 3 {
+		t.Fatal("Asset listed in manifest should be detected with manifest as validation asset:", rel)
+	}
+	if err := up.DownloadReleaseAsset(ctx, rel, ioutil.Discard); err != nil {
+		t.Fatal("Asset should be validated with checksum in manifest:", err)
+	}
+
+	rel, ok, err = up.DetectVersion(ctx, "foo/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.AssetID != 4 {
+		t.Fatal("Asset should be detected by name when release has no manifest:", rel)
+	}
+
+	config.OS = "darwin"
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := up.DetectVersion(ctx, "foo/bar", "v1.1.0"); err != nil || ok {
+		t.Fatal("Release whose manifest lists missing asset should not be detected:", ok, err)
+	}
+	if s := up.SkippedReleases(); len(s) != 1 || s[0].Reason != "invalid manifest" {
+		t.Fatal("Release with invalid manifest should be reported as skipped:", s)
+	}
+
+	config.OS = "windows"
+	config.ReportNoMatchingAsset = true
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectVersion(ctx, "foo/bar", "v1.1.0"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("Release whose manifest has no entry for the platform should have no asset:", err)
+	}
+
+	manifest = strings.Replace(manifest, checksum, strings.Repeat("0", len(checksum)), 1)
+	config.OS = "linux"
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, _, err = up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verr *ValidationError
+	if err := up.DownloadReleaseAsset(ctx, rel, ioutil.Discard); !errors.As(err, &verr) {
+		t.Fatal("Checksum mismatch with manifest should be reported:", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package selfupdate
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// openNoFollow is zero since opening a file without following a symbolic link is not supported on this platform.
+const openNoFollow = 0
+
+// sameFilesystem returns whether the two directories are on the same volume so that a file can be moved between
+// them by atomic rename. It returns true when it cannot be determined.
+func sameFilesystem(a, b string) bool {
+	aa, err := filepath.Abs(a)
+	if err != nil {
+		return true
+	}
+	ab, err := filepath.Abs(b)
+	if err != nil {
+		return true
+	}
+	return strings.EqualFold(filepath.VolumeName(aa), filepath.VolumeName(ab))
+}
+
+// syncDir does nothing since a directory cannot be synced on this platform.
+func syncDir(dir string) {}
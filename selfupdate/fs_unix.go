@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package selfupdate
+
+import (
+	"os"
+	"syscall"
+)
+
+// openNoFollow is the flag to open a file without following a symbolic link.
+const openNoFollow = syscall.O_NOFOLLOW
+
+// sameFilesystem returns whether the two directories are on the same filesystem so that a file can be moved between
+// them by atomic rename. It returns true when it cannot be determined.
+func sameFilesystem(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return true
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return true
+	}
+	da, oka := sa.Sys().(*syscall.Stat_t)
+	db, okb := sb.Sys().(*syscall.Stat_t)
+	if !oka || !okb {
+		return true
+	}
+	return da.Dev == db.Dev
+}
+
+// syncDir flushes the changes of the entries in the directory such as renames to disk. Errors are ignored since some
+// filesystems do not support syncing a directory.
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Sync()
+}
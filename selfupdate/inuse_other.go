@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package selfupdate
+
+// isBinaryInUse returns false since a running binary can be replaced on this platform.
+func isBinaryInUse(err error) bool {
+	return false
+}
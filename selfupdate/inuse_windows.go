@@ -0,0 +1,21 @@
+package selfupdate
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows error codes reported when a file is opened by another process without sharing
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isBinaryInUse returns whether the error was caused by the binary locked by another running process.
+func isBinaryInUse(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorSharingViolation || errno == errorLockViolation
+}
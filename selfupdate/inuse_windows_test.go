@@ -0,0 +1,24 @@
+package selfupdate
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsBinaryInUse(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&os.PathError{Op: "rename", Path: "foo.exe", Err: errorSharingViolation}, true},
+		{&os.LinkError{Op: "rename", Old: "foo.exe", New: ".foo.exe.old", Err: errorLockViolation}, true},
+		{&os.PathError{Op: "rename", Path: "foo.exe", Err: syscall.ERROR_ACCESS_DENIED}, false},
+		{errors.New("other error"), false},
+	} {
+		if have := isBinaryInUse(tc.err); have != tc.want {
+			t.Errorf("isBinaryInUse(%v) should be %v but got %v", tc.err, tc.want, have)
+		}
+	}
+}
@@ -28,3 +28,39 @@ func DisableLog() {
 	log.SetOutput(ioutil.Discard)
 	log.SetFlags(0)
 }
+
+// Logger is an interface to receive logging messages of Updater. It enables to integrate logging messages with
+// the logging library of an application. Debugf receives detailed messages of detection such as skipped releases
+// and assets. Infof receives notable messages such as fetched releases and updated binaries.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+}
+
+// stdLogger is the default Logger which outputs messages to the package logger. The messages are discarded unless
+// EnableLog is called.
+type stdLogger struct{}
+
+func (l stdLogger) Debugf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+func (l stdLogger) Infof(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+func (up *Updater) debugf(format string, args ...interface{}) {
+	if up.logger == nil {
+		stdLogger{}.Debugf(format, args...)
+		return
+	}
+	up.logger.Debugf(format, args...)
+}
+
+func (up *Updater) infof(format string, args ...interface{}) {
+	if up.logger == nil {
+		stdLogger{}.Infof(format, args...)
+		return
+	}
+	up.logger.Infof(format, args...)
+}
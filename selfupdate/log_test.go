@@ -1,6 +1,12 @@
 package selfupdate
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +34,52 @@ func TestEnableDisableLog(t *testing.T) {
 		t.Fatal("Log should be enabled")
 	}
 }
+
+type recordingLogger struct {
+	debug []string
+	info  []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.info = append(l.info, fmt.Sprintf(format, args...))
+}
+
+func TestCustomLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset := fmt.Sprintf("foo_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": %q}]}, {"tag_name": "v1.2.4", "draft": true}]`, asset)
+	}))
+	defer ts.Close()
+
+	l := &recordingLogger{}
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, Logger: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !containsMessage(l.debug, "Skip draft version v1.2.4") {
+		t.Error("Skipped draft should be logged as debug message:", l.debug)
+	}
+	if !containsMessage(l.info, "Successfully fetched the latest release. tag: v1.2.3") {
+		t.Error("Fetched release should be logged as info message:", l.info)
+	}
+}
+
+func containsMessage(msgs []string, want string) bool {
+	for _, m := range msgs {
+		if strings.Contains(m, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v30/github"
+)
+
+// manifest is the content of the manifest asset named Config.ManifestAssetName such as
+//
+//	{"assets": [{"os": "linux", "arch": "amd64", "name": "myapp-linux-x64.tgz", "sha256": "3a5f..."}]}
+type manifest struct {
+	Assets []manifestEntry `json:"assets"`
+}
+
+// manifestEntry is the asset for a platform listed in the manifest.
+type manifestEntry struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+func parseManifest(b []byte) (*manifest, error) {
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("Failed to parse manifest: %s", err)
+	}
+	for _, e := range m.Assets {
+		if e.Name == "" {
+			return nil, fmt.Errorf("Asset for %s/%s in manifest has no name", e.OS, e.Arch)
+		}
+	}
+	return &m, nil
+}
+
+// entryFor returns the entry for the OS and one of the archs. archs are in order of preference.
+func (m *manifest) entryFor(goos string, archs []string) (*manifestEntry, bool) {
+	for _, arch := range archs {
+		for i, e := range m.Assets {
+			if strings.EqualFold(e.OS, goos) && strings.EqualFold(e.Arch, arch) {
+				return &m.Assets[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// entryNamed returns the entry of the asset name.
+func (m *manifest) entryNamed(name string) (*manifestEntry, bool) {
+	for i, e := range m.Assets {
+		if e.Name == name {
+			return &m.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// manifestValidator validates an asset with its SHA256 checksum listed in the manifest. It is the validator of
+// releases whose asset was selected by the manifest when no validator is configured.
+type manifestValidator struct {
+	name string
+}
+
+// Validate always fails since the asset name is necessary to look up the checksum in the manifest.
+func (v manifestValidator) Validate(release, asset []byte) error {
+	return &ValidationError{Validator: "manifest", Actual: sha256Hex(release), Err: fmt.Errorf("asset name is necessary to validate a release asset with manifest %q", v.name)}
+}
+
+// ValidateAsset validates the asset named 'name' with the checksum listed in the manifest.
+func (v manifestValidator) ValidateAsset(name string, release, asset []byte) error {
+	actual := sha256Hex(release)
+	m, err := parseManifest(asset)
+	if err != nil {
+		return &ValidationError{Validator: "manifest", AssetName: name, Actual: actual, Err: err}
+	}
+	e, ok := m.entryNamed(name)
+	if !ok || e.SHA256 == "" {
+		return &ValidationError{Validator: "manifest", AssetName: name, Actual: actual, Err: fmt.Errorf("checksum is not listed in manifest %q", v.name)}
+	}
+	if !strings.EqualFold(e.SHA256, actual) {
+		return &ValidationError{Validator: "manifest", AssetName: name, Expected: e.SHA256, Actual: actual}
+	}
+	return nil
+}
+
+// Suffix returns the name of the manifest asset.
+func (v manifestValidator) Suffix() string {
+	return v.name
+}
+
+// manifestResolver looks up the assets listed in the manifests of releases of the repository 'owner/name' on
+// detection.
+type manifestResolver struct {
+	ctx   context.Context
+	up    *Updater
+	owner string
+	name  string
+}
+
+// newManifestResolver returns the resolver for the repository. It is nil when Config.ManifestAssetName is not set.
+func (up *Updater) newManifestResolver(ctx context.Context, owner, name string) *manifestResolver {
+	if up.manifestAssetName == "" {
+		return nil
+	}
+	return &manifestResolver{ctx, up, owner, name}
+}
+
+// resolve downloads the manifest of the release and returns the asset listed for the target OS and arch. 'found' is
+// false when the release has no manifest. The asset is nil when the manifest has no entry for the platform.
+// 'validation' is the manifest asset when the entry lists the checksum of the asset.
+func (r *manifestResolver) resolve(rel *github.RepositoryRelease) (asset, validation *github.ReleaseAsset, found bool, err error) {
+	manifestAsset, ok := findValidationAsset(rel, r.up.manifestAssetName)
+	if !ok {
+		r.up.debugf("Manifest %q was not found in release %s", r.up.manifestAssetName, rel.GetTagName())
+		return nil, nil, false, nil
+	}
+
+	mrel := &Release{
+		AssetURL:  manifestAsset.GetBrowserDownloadURL(),
+		AssetName: manifestAsset.GetName(),
+		RepoOwner: r.owner,
+		RepoName:  r.name,
+	}
+	data, err := r.up.downloadAssetWithRetry(r.ctx, mrel, manifestAsset.GetID(), "manifest", false)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	m, err := parseManifest(data)
+	if err != nil {
+		return nil, nil, true, err
+	}
+
+	e, ok := m.entryFor(r.up.targetOS(), r.up.targetArchAliases())
+	if !ok {
+		r.up.debugf("Manifest of release %s has no asset for %s/%s", rel.GetTagName(), r.up.targetOS(), r.up.targetArch())
+		return nil, nil, true, nil
+	}
+	if e.SHA256 != "" {
+		validation = manifestAsset
+	}
+	for _, a := range rel.Assets {
+		if a.GetName() == e.Name {
+			r.up.debugf("Asset %q was selected by manifest of release %s", e.Name, rel.GetTagName())
+			return a, validation, true, nil
+		}
+	}
+	return nil, nil, true, fmt.Errorf("Asset %q listed in manifest is not found in release %s", e.Name, rel.GetTagName())
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package selfupdate
+
+import (
+	"os"
+)
+
+// fileOwner returns false since the ownership of a file cannot be changed with user ID and group ID on this platform.
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
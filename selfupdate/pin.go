@@ -0,0 +1,79 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// parseFingerprints parses SHA-256 fingerprints of certificates in hex. Colons between bytes such as "3A:5F:..."
+// are allowed and letters are case-insensitive.
+func parseFingerprints(fingerprints []string) ([][]byte, error) {
+	pins := make([][]byte, 0, len(fingerprints))
+	for _, f := range fingerprints {
+		b, err := hex.DecodeString(strings.Replace(f, ":", "", -1))
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("Invalid SHA-256 fingerprint of certificate %q: it should be 64 hex digits", f)
+		}
+		pins = append(pins, b)
+	}
+	return pins, nil
+}
+
+// verifyPinnedCert returns a function to verify that one of the certificates of the server is pinned. The certificates
+// in the verified chains are checked so that an intermediate or root certificate can also be pinned. When the chains
+// are not verified (InsecureSkipVerify), only the leaf certificate is checked.
+func verifyPinnedCert(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("%w: server presented no certificate", ErrCertificateNotPinned)
+		}
+		candidates := [][]byte{rawCerts[0]}
+		for _, chain := range chains {
+			for _, c := range chain {
+				candidates = append(candidates, c.Raw)
+			}
+		}
+		for _, raw := range candidates {
+			sum := sha256.Sum256(raw)
+			for _, p := range pins {
+				if bytes.Equal(sum[:], p) {
+					return nil
+				}
+			}
+		}
+		leaf := sha256.Sum256(rawCerts[0])
+		return fmt.Errorf("%w: SHA-256 fingerprint of server certificate is %x", ErrCertificateNotPinned, leaf)
+	}
+}
+
+// newPinnedHTTPClient returns a copy of the HTTP client which rejects a server whose certificate is not pinned. The
+// standard verification of certificates is still applied. The transport of the client must be *http.Transport.
+func newPinnedHTTPClient(c *http.Client, pins [][]byte) (*http.Client, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	var t *http.Transport
+	switch base := c.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = base.Clone()
+	default:
+		return nil, fmt.Errorf("Certificate pinning requires *http.Transport as the transport of HTTP client but it is %T", base)
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.VerifyPeerCertificate = verifyPinnedCert(pins)
+	// VerifyPeerCertificate is not called on resumed sessions
+	t.TLSClientConfig.ClientSessionCache = nil
+	pinned := *c
+	pinned.Transport = t
+	return &pinned, nil
+}
@@ -0,0 +1,111 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseFingerprints(t *testing.T) {
+	sum := sha256.Sum256([]byte("cert"))
+	hexed := fmt.Sprintf("%x", sum)
+	colons := strings.ToUpper(fmt.Sprintf("% x", sum))
+	colons = strings.Replace(colons, " ", ":", -1)
+
+	pins, err := parseFingerprints([]string{hexed, colons})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range pins {
+		if string(p) != string(sum[:]) {
+			t.Errorf("Fingerprint #%d was not parsed correctly: %x", i, p)
+		}
+	}
+
+	for _, invalid := range []string{"", "xyz", hexed[:62]} {
+		if _, err := parseFingerprints([]string{invalid}); err == nil {
+			t.Errorf("Invalid fingerprint %q should cause an error", invalid)
+		}
+	}
+}
+
+func TestPinnedCertFingerprints(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asset"))
+	}))
+	defer ts.Close()
+	pinned := fmt.Sprintf("%x", sha256.Sum256(ts.Certificate().Raw))
+	other := fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+
+	for _, tc := range []struct {
+		what  string
+		pins  []string
+		valid bool
+	}{
+		{"no pin", nil, true},
+		{"pinned", []string{other, pinned}, true},
+		{"not pinned", []string{other}, false},
+	} {
+		up, err := NewUpdater(ctx, Config{HTTPClient: ts.Client(), PinnedCertFingerprints: tc.pins})
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := up.downloadDirectlyFromURL(ctx, ts.URL+"/asset.zip")
+		if !tc.valid {
+			if !errors.Is(err, ErrCertificateNotPinned) {
+				t.Errorf("Download should be rejected for %s: %v", tc.what, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Download should succeed for %s: %s", tc.what, err)
+		}
+		src.Close()
+	}
+
+	if _, err := NewUpdater(ctx, Config{PinnedCertFingerprints: []string{"foo"}}); err == nil {
+		t.Fatal("Invalid fingerprint should cause an error")
+	}
+
+	c := &http.Client{Transport: &headerTransport{"custom"}}
+	if _, err := NewUpdater(ctx, Config{HTTPClient: c, PinnedCertFingerprints: []string{pinned}}); err == nil {
+		t.Fatal("Transport which is not *http.Transport should cause an error")
+	}
+}
+
+func TestPinAPICertificates(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+	other := fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+
+	for _, pinAPI := range []bool{false, true} {
+		up, err := NewUpdater(ctx, Config{
+			APIToken:               "hogehoge",
+			EnterpriseBaseURL:      ts.URL,
+			HTTPClient:             ts.Client(),
+			PinnedCertFingerprints: []string{other},
+			PinAPICertificates:     pinAPI,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = up.DetectLatest(ctx, "foo/bar")
+		if pinAPI && !errors.Is(err, ErrCertificateNotPinned) {
+			t.Error("API call should be rejected with PinAPICertificates:", err)
+		}
+		if !pinAPI && err != nil {
+			t.Error("API call should not be pinned without PinAPICertificates:", err)
+		}
+	}
+}
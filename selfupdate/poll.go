@@ -0,0 +1,90 @@
+package selfupdate
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// pollState is the state for computing the interval of polling releases. It is safe for concurrent use.
+type pollState struct {
+	mu        sync.Mutex
+	last      time.Duration
+	remaining int
+	reset     time.Time
+}
+
+// recordRate records the rate limit status reported by GitHub API. Zero reset time means unknown status.
+func (s *pollState) recordRate(remaining int, reset time.Time) {
+	if reset.IsZero() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remaining = remaining
+	s.reset = reset
+}
+
+// randomBetween returns a random duration in [min, max).
+func randomBetween(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+// defaultMaxPollFactor is the factor of the base interval to cap the interval when Config.MaxPollInterval is not set.
+const defaultMaxPollFactor = 10
+
+// NextPollAfter returns how long to wait before the next detection when polling releases periodically with the base
+// interval. It applies decorrelated jitter so that many instances started at the same time spread their API calls:
+// the result is randomly chosen between base and three times the previous result, and it never exceeds
+// Config.MaxPollInterval (ten times base by default). When the last API response reported that the rate limit was
+// exhausted, the result is extended after the time when the limit is reset. It is safe to call from multiple
+// goroutines.
+func (up *Updater) NextPollAfter(base time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	limit := up.maxPollInterval
+	if limit <= 0 {
+		limit = defaultMaxPollFactor * base
+	}
+	if limit < base {
+		limit = base
+	}
+
+	s := &up.poll
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.last
+	if prev < base {
+		prev = base
+	}
+	d := randomBetween(base, 3*prev)
+	if d > limit {
+		d = limit
+	}
+	s.last = d
+
+	if s.remaining == 0 && !s.reset.IsZero() {
+		if until := time.Until(s.reset); until > 0 {
+			// Spread the calls after the reset as well
+			if w := until + randomBetween(0, base); w > d {
+				d = w
+			}
+		}
+	}
+	return d
+}
+
+// RateLimitReset returns the rate limit status of GitHub API reported by the last response on detecting releases.
+// 'remaining' is the number of remaining requests and 'reset' is the time when the limit is reset. 'ok' is false
+// when no status has been reported yet.
+func (up *Updater) RateLimitReset() (remaining int, reset time.Time, ok bool) {
+	s := &up.poll
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remaining, s.reset, !s.reset.IsZero()
+}
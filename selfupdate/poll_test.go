@@ -0,0 +1,91 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNextPollAfter(t *testing.T) {
+	up := &Updater{}
+	base := 10 * time.Second
+	for i := 0; i < 100; i++ {
+		prev := up.poll.last
+		if prev < base {
+			prev = base
+		}
+		d := up.NextPollAfter(base)
+		if d < base || d > 3*prev || d > 10*base {
+			t.Fatalf("Interval %s is out of range [%s, min(%s, %s))", d, base, 3*prev, 10*base)
+		}
+	}
+	if d := up.NextPollAfter(0); d != 0 {
+		t.Error("Zero base should result in zero interval:", d)
+	}
+
+	// The range of the next interval depends on the previous one
+	up = &Updater{maxPollInterval: time.Hour}
+	longer := false
+	for i := 0; i < 20; i++ {
+		up.poll.last = 50 * base
+		d := up.NextPollAfter(base)
+		if d > time.Hour {
+			t.Fatal("Interval should be capped by MaxPollInterval:", d)
+		}
+		if d > 3*base {
+			longer = true
+		}
+	}
+	if !longer {
+		t.Error("Long previous interval should allow a longer next interval")
+	}
+	for i := 0; i < 20; i++ {
+		up.poll.last = base
+		if d := up.NextPollAfter(base); d >= 3*base {
+			t.Fatal("Short previous interval should keep the next interval short:", d)
+		}
+	}
+
+	up.poll.recordRate(0, time.Now().Add(time.Hour))
+	if d := up.NextPollAfter(base); d < 59*time.Minute {
+		t.Error("Interval should be extended until the rate limit is reset:", d)
+	}
+
+	up.poll.recordRate(10, time.Now().Add(time.Hour))
+	if d := up.NextPollAfter(base); d > time.Hour {
+		t.Error("Interval should not be extended while the rate limit remains:", d)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "12")
+		w.Header().Set("X-RateLimit-Reset", "1717408800")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := up.RateLimitReset(); ok {
+		t.Fatal("Rate limit should be unknown before detection")
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+	remaining, reset, ok := up.RateLimitReset()
+	if !ok {
+		t.Fatal("Rate limit should be recorded after detection")
+	}
+	if remaining != 12 || reset.Unix() != 1717408800 {
+		t.Error("Unexpected rate limit:", remaining, reset)
+	}
+}
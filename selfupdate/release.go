@@ -1,9 +1,14 @@
 package selfupdate
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/blang/semver"
+	"github.com/google/go-github/v30/github"
 )
 
 // Release represents a release asset for current OS and arch.
@@ -16,6 +21,8 @@ type Release struct {
 	AssetByteSize int
 	// AssetID is the ID of the asset on GitHub
 	AssetID int64
+	// AssetName is the file name of the asset on GitHub
+	AssetName string
 	// ValidationAssetID is the ID of additional validaton asset on GitHub
 	ValidationAssetID int64
 	// URL is a URL to release page for browsing
@@ -26,8 +33,379 @@ type Release struct {
 	Name string
 	// PublishedAt is the time when the release was published
 	PublishedAt *time.Time
+	// Prerelease is true when the release is marked as pre-release on GitHub
+	Prerelease bool
+	// Draft is true when the release is a draft on GitHub. It is only detected with Config.AllowDrafts
+	Draft bool
 	// RepoOwner is the owner of the repository of the release
 	RepoOwner string
 	// RepoName is the name of the repository of the release
 	RepoName string
+	// Format is the archive or compression format of the asset detected from its file name
+	Format AssetFormat
+	// AssetPartIDs is the IDs of the parts of a split asset in order, detected with Config.AllowSplitAssets. AssetID
+	// and AssetURL are the ones of the first part, and AssetName and AssetByteSize are the ones of the whole asset.
+	// It is empty when the asset is not split.
+	AssetPartIDs []int64
+	// Response is the metadata of the responses from GitHub Releases API on detecting the release. It is nil when
+	// the release was detected with Config.Source. It is shared by all releases detected at once.
+	Response *ResponseMetadata
+	// validator is the validator whose validation asset was found on detection
+	validator Validator
+	// apiBaseURL is the base URL of GitHub API which the release was detected with
+	apiBaseURL string
+}
+
+// defaultAPIBaseURL is the base URL of GitHub API used when the release was not detected with GitHub Enterprise.
+const defaultAPIBaseURL = "https://api.github.com/"
+
+// APIDownloadURL returns the URL of GitHub Releases API to download the asset such as
+// 'https://api.github.com/repos/owner/repo/releases/assets/123'. Unlike AssetURL, it is available for assets of
+// private repositories. Fetch it with 'Accept: application/octet-stream' header and the API token. The base URL of
+// the updater which detected the release (e.g. GitHub Enterprise) is used. It returns an empty string when the asset
+// ID or the repository is not known.
+func (r *Release) APIDownloadURL() string {
+	if r.AssetID <= 0 || r.RepoOwner == "" || r.RepoName == "" {
+		return ""
+	}
+	base := r.apiBaseURL
+	if base == "" {
+		base = defaultAPIBaseURL
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return fmt.Sprintf("%srepos/%s/%s/releases/assets/%d", base, url.PathEscape(r.RepoOwner), url.PathEscape(r.RepoName), r.AssetID)
+}
+
+// ReleaseInfo is the stable JSON representation of a release for external tools such as shell scripts. The JSON
+// field names are part of the API and are not changed in compatible versions. Optional fields are omitted when
+// they are empty.
+type ReleaseInfo struct {
+	// Version is the version of the release without 'v' prefix such as "1.2.3"
+	Version string `json:"version"`
+	// Name is the name of the release
+	Name string `json:"name,omitempty"`
+	// URL is a URL to the release page
+	URL string `json:"url,omitempty"`
+	// AssetURL is a URL to the asset of the release
+	AssetURL string `json:"asset_url"`
+	// AssetName is the file name of the asset
+	AssetName string `json:"asset_name,omitempty"`
+	// AssetSize is the size of the asset in bytes
+	AssetSize int `json:"asset_size"`
+	// Format is the archive or compression format of the asset such as "tar.gz"
+	Format AssetFormat `json:"format,omitempty"`
+	// PublishedAt is the time when the release was published in RFC 3339 format
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// Prerelease is true when the release is a pre-release
+	Prerelease bool `json:"prerelease"`
+	// Draft is true when the release is a draft
+	Draft bool `json:"draft"`
+	// ReleaseNotes is the release notes of the release
+	ReleaseNotes string `json:"release_notes,omitempty"`
+	// Repository is the repository of the release such as "owner/repo"
+	Repository string `json:"repository,omitempty"`
+}
+
+// Info returns the stable representation of the release which can be encoded as JSON.
+func (r *Release) Info() ReleaseInfo {
+	repo := ""
+	if r.RepoOwner != "" || r.RepoName != "" {
+		repo = r.RepoOwner + "/" + r.RepoName
+	}
+	return ReleaseInfo{
+		Version:      r.Version.String(),
+		Name:         r.Name,
+		URL:          r.URL,
+		AssetURL:     r.AssetURL,
+		AssetName:    r.AssetName,
+		AssetSize:    r.AssetByteSize,
+		Format:       r.Format,
+		PublishedAt:  r.PublishedAt,
+		Prerelease:   r.Prerelease,
+		Draft:        r.Draft,
+		ReleaseNotes: r.ReleaseNotes,
+		Repository:   repo,
+	}
+}
+
+// ResponseMetadata is the metadata of HTTP responses from GitHub Releases API. It is useful for implementing
+// caching and pacing API calls on the caller side.
+type ResponseMetadata struct {
+	// ETag is the ETag header of the response for the first page of releases
+	ETag string
+	// LastModified is the Last-Modified header of the response for the first page of releases
+	LastModified string
+	// Rate is the rate limit status reported by the last response
+	Rate github.Rate
+}
+
+// update updates the metadata with the response. Headers are only taken from the first page.
+func (m *ResponseMetadata) update(res *github.Response, first bool) {
+	if m == nil || res == nil {
+		return
+	}
+	if first {
+		m.ETag = res.Header.Get("ETag")
+		m.LastModified = res.Header.Get("Last-Modified")
+	}
+	m.Rate = res.Rate
+}
+
+// AssetFormat is the archive or compression format of a release asset.
+type AssetFormat string
+
+const (
+	// FormatZip is a zip archive ('.zip')
+	FormatZip AssetFormat = "zip"
+	// FormatTarGz is a tar archive compressed with gzip ('.tar.gz' or '.tgz')
+	FormatTarGz AssetFormat = "tar.gz"
+	// FormatGzip is a single file compressed with gzip ('.gz' or '.gzip')
+	FormatGzip AssetFormat = "gz"
+	// FormatTarXz is a tar archive compressed with xz ('.tar.xz')
+	FormatTarXz AssetFormat = "tar.xz"
+	// FormatXz is a single file compressed with xz ('.xz')
+	FormatXz AssetFormat = "xz"
+	// FormatTarZst is a tar archive compressed with zstd ('.tar.zst' or '.tzst')
+	FormatTarZst AssetFormat = "tar.zst"
+	// FormatZst is a single file compressed with zstd ('.zst')
+	FormatZst AssetFormat = "zst"
+	// FormatTarBz2 is a tar archive compressed with bzip2 ('.tar.bz2')
+	FormatTarBz2 AssetFormat = "tar.bz2"
+	// FormatBz2 is a single file compressed with bzip2 ('.bz2')
+	FormatBz2 AssetFormat = "bz2"
+	// FormatTar is an uncompressed tar archive ('.tar')
+	FormatTar AssetFormat = "tar"
+	// Format7z is a 7-Zip archive ('.7z')
+	Format7z AssetFormat = "7z"
+	// FormatDeb is a Debian package ('.deb'). It cannot be installed by UpdateTo
+	FormatDeb AssetFormat = "deb"
+	// FormatRpm is an RPM package ('.rpm'). It cannot be installed by UpdateTo
+	FormatRpm AssetFormat = "rpm"
+	// FormatRaw is an uncompressed executable
+	FormatRaw AssetFormat = "raw"
+)
+
+var formatsByExtension = map[string]AssetFormat{
+	".zip":     FormatZip,
+	".tar.gz":  FormatTarGz,
+	".tgz":     FormatTarGz,
+	".gzip":    FormatGzip,
+	".gz":      FormatGzip,
+	".tar.xz":  FormatTarXz,
+	".xz":      FormatXz,
+	".tar.zst": FormatTarZst,
+	".tzst":    FormatTarZst,
+	".zst":     FormatZst,
+	".tar.bz2": FormatTarBz2,
+	".bz2":     FormatBz2,
+	".tar":     FormatTar,
+	".7z":      Format7z,
+	".deb":     FormatDeb,
+	".rpm":     FormatRpm,
+}
+
+// formatsByContentType maps the content types of archives and compressed files to their formats. A gzip file may
+// be a tar archive, which is detected on uncompression.
+var formatsByContentType = map[string]AssetFormat{
+	"application/zip":              FormatZip,
+	"application/x-zip-compressed": FormatZip,
+	"application/gzip":             FormatGzip,
+	"application/x-gzip":           FormatGzip,
+	"application/x-gtar":           FormatTarGz,
+	"application/x-xz":             FormatXz,
+	"application/zstd":             FormatZst,
+	"application/x-bzip2":          FormatBz2,
+	"application/x-tar":            FormatTar,
+	"application/x-7z-compressed":  Format7z,
+}
+
+// contentTypeFormat returns the format of the content type such as "application/zip". Parameters of the content type
+// are ignored.
+func contentTypeFormat(contentType string) (AssetFormat, bool) {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	f, ok := formatsByContentType[strings.ToLower(strings.TrimSpace(contentType))]
+	return f, ok
+}
+
+// assetFormat returns the format of the asset from its file name. The longest matching extension is used.
+func assetFormat(name string) AssetFormat {
+	ext := ""
+	for e := range formatsByExtension {
+		if strings.HasSuffix(name, e) && len(e) > len(ext) {
+			ext = e
+		}
+	}
+	if ext == "" {
+		return FormatRaw
+	}
+	return formatsByExtension[ext]
+}
+
+// VersionExtractor extracts a semantic version from a Git tag name of a release. When an error is
+// returned, the release is skipped.
+type VersionExtractor interface {
+	ExtractVersion(tag string) (semver.Version, error)
+}
+
+// VersionExtractorFunc is an adapter to allow the use of an ordinary function as a VersionExtractor.
+type VersionExtractorFunc func(tag string) (semver.Version, error)
+
+// ExtractVersion calls f(tag).
+func (f VersionExtractorFunc) ExtractVersion(tag string) (semver.Version, error) {
+	return f(tag)
+}
+
+// ErrNoMatchingAsset is an error reported when releases exist but none of them has an asset for the current
+// OS and arch. Actual errors are *NoMatchingAssetError values and can be checked with errors.Is.
+var ErrNoMatchingAsset = errors.New("no asset matching to the platform was found")
+
+// NoMatchingAssetError is returned when a release exists but it has no asset for the current OS and arch.
+type NoMatchingAssetError struct {
+	// Tag is the tag name of the release
+	Tag string
+	// Version is the version of the release
+	Version semver.Version
+	// OS is the OS name which was looked for
+	OS string
+	// Arch is the arch name which was looked for
+	Arch string
+	// Assets is the names of assets contained in the release
+	Assets []string
+}
+
+func (e *NoMatchingAssetError) Error() string {
+	return fmt.Sprintf("Release %s exists but has no asset for %s/%s (assets: %s)", e.Tag, e.OS, e.Arch, strings.Join(e.Assets, ", "))
+}
+
+// Is returns true when target is ErrNoMatchingAsset.
+func (e *NoMatchingAssetError) Is(target error) bool {
+	return target == ErrNoMatchingAsset
+}
+
+// SkippedRelease is a release which was not a candidate of the last detection. It is useful for diagnostics such as
+// printing why no suitable release was found.
+type SkippedRelease struct {
+	// Tag is the Git tag name of the release
+	Tag string
+	// Reason is why the release was skipped such as "draft", "pre-release", "unparseable" or "no asset"
+	Reason string
+}
+
+func (s SkippedRelease) String() string {
+	return fmt.Sprintf("%s (%s)", s.Tag, s.Reason)
+}
+
+// ErrPackageAsset is an error reported when updating to a release whose asset is a system package such as '.deb'
+// or '.rpm'. Such packages should be installed with the package manager of the system.
+var ErrPackageAsset = errors.New("asset is a system package")
+
+// ErrBinaryInUse is an error reported when the binary cannot be replaced since it is locked by another running
+// process. It only happens on Windows. The update should be retried after the processes exit, such as on the next
+// start of the application.
+var ErrBinaryInUse = errors.New("binary is in use by another process")
+
+// ErrCertificateNotPinned is an error reported when the certificate of a server is not in
+// Config.PinnedCertFingerprints.
+var ErrCertificateNotPinned = errors.New("certificate of server is not pinned")
+
+// ErrValidationAssetNotFound is an error reported when Config.Validator is set but the validation file for the asset
+// is not found in the release. It is returned from detection when no release can be validated.
+var ErrValidationAssetNotFound = errors.New("validation file was not found")
+
+// ErrNoReleaseInRange is an error reported when releases exist but none of them is in the range of
+// Config.MinVersion and Config.MaxVersion. Actual errors are *NoReleaseInRangeError values and can be checked
+// with errors.Is.
+var ErrNoReleaseInRange = errors.New("no release in the version range was found")
+
+// NoReleaseInRangeError is returned when releases exist but all of them are out of the version range.
+type NoReleaseInRangeError struct {
+	// MinVersion is the floor of the version range
+	MinVersion string
+	// MaxVersion is the ceiling of the version range
+	MaxVersion string
+	// Latest is the latest version of the releases out of the range
+	Latest semver.Version
+}
+
+func (e *NoReleaseInRangeError) Error() string {
+	return fmt.Sprintf("Releases exist but none of them is in the version range (min: %q, max: %q). The latest version is %s", e.MinVersion, e.MaxVersion, e.Latest)
+}
+
+// Is returns true when target is ErrNoReleaseInRange.
+func (e *NoReleaseInRangeError) Is(target error) bool {
+	return target == ErrNoReleaseInRange
+}
+
+// ErrDowngrade is an error reported when the latest release is older than the current version and
+// Config.AllowDowngrade is not set. Actual errors are *DowngradeError values and can be checked with errors.Is.
+var ErrDowngrade = errors.New("release is older than current version")
+
+// DowngradeError is returned when updating would install a release older than the current version.
+type DowngradeError struct {
+	// Current is the current version
+	Current semver.Version
+	// Version is the version of the latest release
+	Version semver.Version
+}
+
+func (e *DowngradeError) Error() string {
+	return fmt.Sprintf("Latest release %s is older than current version %s. Set Config.AllowDowngrade to downgrade", e.Version, e.Current)
+}
+
+// Is returns true when target is ErrDowngrade.
+func (e *DowngradeError) Is(target error) bool {
+	return target == ErrDowngrade
+}
+
+// RateLimitError is returned when detecting releases failed because the rate limit of GitHub API was exceeded.
+// Long-running processes should wait until Reset before calling the API again.
+type RateLimitError struct {
+	// Limit is the number of requests per hour. It is zero when unknown.
+	Limit int
+	// Remaining is the number of remaining requests in the current rate limit window.
+	Remaining int
+	// Reset is the time when the rate limit is reset. It is zero when unknown.
+	Reset time.Time
+	// Err is the error returned from GitHub API client.
+	Err error
+}
+
+func (e *RateLimitError) Error() string {
+	reset := "unknown"
+	if !e.Reset.IsZero() {
+		reset = e.Reset.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("GitHub API rate limit exceeded (remaining: %d/%d, reset: %s): %s", e.Remaining, e.Limit, reset, e.Err)
+}
+
+// Unwrap returns the error returned from GitHub API client.
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// newRateLimitError converts the rate limit error of GitHub API client into *RateLimitError. It returns nil when
+// the error is not caused by rate limit.
+func newRateLimitError(err error) *RateLimitError {
+	var rerr *github.RateLimitError
+	if errors.As(err, &rerr) {
+		return &RateLimitError{
+			Limit:     rerr.Rate.Limit,
+			Remaining: rerr.Rate.Remaining,
+			Reset:     rerr.Rate.Reset.Time,
+			Err:       err,
+		}
+	}
+	var aerr *github.AbuseRateLimitError
+	if errors.As(err, &aerr) {
+		e := &RateLimitError{Err: err}
+		if aerr.RetryAfter != nil {
+			e.Reset = time.Now().Add(*aerr.RetryAfter)
+		}
+		return e
+	}
+	return nil
 }
@@ -0,0 +1,160 @@
+// Package selfupdatetest provides a fake release source for testing code built on selfupdate without network.
+//
+// Create a Source, add canned releases to it and set it to the Source field of selfupdate.Config. Then detections
+// and updates of the updater are driven by the releases deterministically.
+//
+//	src := selfupdatetest.NewSource()
+//	src.AddRelease("owner/repo", selfupdatetest.Release{
+//		Tag:    "v1.2.3",
+//		Assets: []selfupdatetest.Asset{selfupdatetest.PlatformAsset("myapp", []byte("new binary"))},
+//	})
+//	up, err := selfupdate.NewUpdater(ctx, selfupdate.Config{Source: src})
+package selfupdatetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v30/github"
+	"github.com/rhysd/go-github-selfupdate/selfupdate"
+)
+
+// Asset is a canned release asset.
+type Asset struct {
+	// Name is the file name of the asset such as "myapp_linux_amd64.tar.gz"
+	Name string
+	// Content is the content of the asset returned on download
+	Content []byte
+}
+
+// PlatformAsset returns an uncompressed executable asset of the command for the running OS and arch such as
+// 'myapp_linux_amd64' ('myapp_windows_amd64.exe' on Windows).
+func PlatformAsset(cmd string, content []byte) Asset {
+	name := fmt.Sprintf("%s_%s_%s", cmd, runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return Asset{Name: name, Content: content}
+}
+
+// Release is a canned release.
+type Release struct {
+	// Tag is the tag name of the release such as "v1.2.3"
+	Tag string
+	// Name is the name of the release
+	Name string
+	// Notes is the release notes of the release
+	Notes string
+	// Prerelease marks the release as a pre-release
+	Prerelease bool
+	// Draft marks the release as a draft
+	Draft bool
+	// PublishedAt is the time when the release was published. When it is zero, the release is regarded as
+	// published later than the releases added before it.
+	PublishedAt time.Time
+	// Assets are the assets of the release
+	Assets []Asset
+}
+
+// Source is a fake selfupdate.ReleaseSource serving canned releases from memory. It is safe for concurrent use.
+// A repository without any release is reported as not found, the same as GitHub source.
+type Source struct {
+	mu        sync.Mutex
+	releases  map[string][]*github.RepositoryRelease
+	assets    map[int64][]byte
+	nextID    int64
+	downloads map[int64]int
+}
+
+var _ selfupdate.ReleaseSource = (*Source)(nil)
+
+// NewSource creates a new source without any release.
+func NewSource() *Source {
+	return &Source{
+		releases:  map[string][]*github.RepositoryRelease{},
+		assets:    map[int64][]byte{},
+		downloads: map[int64]int{},
+	}
+}
+
+// epoch is the publish time of the first release whose PublishedAt is not set.
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// AddRelease adds the release to the repository 'slug' ('owner/name' formatted string) and returns the release in
+// the form seen by selfupdate. Asset IDs are assigned in the order of addition. It panics when the slug is invalid.
+func (s *Source) AddRelease(slug string, rel Release) *github.RepositoryRelease {
+	if strings.Count(slug, "/") != 1 {
+		panic(fmt.Sprintf("Invalid slug format. It should be 'owner/name': %s", slug))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	published := rel.PublishedAt
+	if published.IsZero() {
+		published = epoch.Add(time.Duration(len(s.releases[slug])) * time.Hour)
+	}
+	r := &github.RepositoryRelease{
+		TagName:     github.String(rel.Tag),
+		Name:        github.String(rel.Name),
+		Body:        github.String(rel.Notes),
+		Prerelease:  github.Bool(rel.Prerelease),
+		Draft:       github.Bool(rel.Draft),
+		PublishedAt: &github.Timestamp{Time: published},
+		HTMLURL:     github.String(fmt.Sprintf("https://github.com/%s/releases/tag/%s", slug, rel.Tag)),
+	}
+	for _, a := range rel.Assets {
+		s.nextID++
+		id := s.nextID
+		s.assets[id] = a.Content
+		r.Assets = append(r.Assets, &github.ReleaseAsset{
+			ID:                 github.Int64(id),
+			Name:               github.String(a.Name),
+			Size:               github.Int(len(a.Content)),
+			BrowserDownloadURL: github.String(fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", slug, rel.Tag, a.Name)),
+		})
+	}
+	// GitHub returns the newest release first
+	s.releases[slug] = append([]*github.RepositoryRelease{r}, s.releases[slug]...)
+	return r
+}
+
+// Downloads returns how many times the asset was downloaded.
+func (s *Source) Downloads(id int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads[id]
+}
+
+// ListReleases returns the releases of the repository, newest first.
+func (s *Source) ListReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rels := s.releases[owner+"/"+repo]
+	return append([]*github.RepositoryRelease(nil), rels...), nil
+}
+
+// DownloadAsset returns the content of the asset. It fails when the asset does not exist.
+func (s *Source) DownloadAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.assets[id]
+	if !ok {
+		return nil, fmt.Errorf("Asset (ID: %d) of repository '%s/%s' was not found", id, owner, repo)
+	}
+	s.downloads[id]++
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
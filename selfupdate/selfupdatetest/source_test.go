@@ -0,0 +1,111 @@
+package selfupdatetest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/rhysd/go-github-selfupdate/selfupdate"
+)
+
+func TestSourceDrivesDetection(t *testing.T) {
+	ctx := context.Background()
+	src := NewSource()
+	src.AddRelease("foo/bar", Release{Tag: "v1.0.0", Assets: []Asset{PlatformAsset("bar", []byte("v1"))}})
+	src.AddRelease("foo/bar", Release{Tag: "v1.1.0", Notes: "Fix bugs", Assets: []Asset{PlatformAsset("bar", []byte("v1.1"))}})
+	src.AddRelease("foo/bar", Release{Tag: "v2.0.0-beta", Prerelease: true, Assets: []Asset{PlatformAsset("bar", []byte("v2"))}})
+	src.AddRelease("foo/noasset", Release{Tag: "v1.0.0", Assets: []Asset{{Name: "bar_plan9_mips.zip"}}})
+
+	up, err := selfupdate.NewUpdater(ctx, selfupdate.Config{Source: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.Version.String() != "1.1.0" || rel.ReleaseNotes != "Fix bugs" {
+		t.Fatal("Latest stable release should be detected:", rel)
+	}
+
+	rel, ok, err = up.DetectVersion(ctx, "foo/bar", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.Version.String() != "1.0.0" {
+		t.Fatal("Specified version should be detected:", rel)
+	}
+
+	if _, ok, err := up.DetectLatest(ctx, "foo/unknown"); err != nil || ok {
+		t.Fatal("Repository without release should not be detected:", ok, err)
+	}
+
+	if _, ok, err := up.DetectLatest(ctx, "foo/noasset"); err != nil || ok {
+		t.Fatal("Release without asset for the platform should not be detected:", ok, err)
+	}
+	up, err = selfupdate.NewUpdater(ctx, selfupdate.Config{Source: src, ReportNoMatchingAsset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = up.DetectLatest(ctx, "foo/noasset")
+	if !errors.Is(err, selfupdate.ErrNoMatchingAsset) {
+		t.Fatal("Release without asset for the platform should be reported:", err)
+	}
+}
+
+func TestSourceDrivesUpdate(t *testing.T) {
+	ctx := context.Background()
+	src := NewSource()
+	r := src.AddRelease("foo/bar", Release{Tag: "v1.1.0", Assets: []Asset{PlatformAsset("bar", []byte("new binary"))}})
+
+	up, err := selfupdate.NewUpdater(ctx, selfupdate.Config{Source: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdatetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := up.UpdateCommand(ctx, cmdPath, semver.MustParse("1.1.0"), "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version.String() != "1.1.0" || src.Downloads(r.Assets[0].GetID()) != 0 {
+		t.Fatal("Command should not be updated when it is already the latest:", rel)
+	}
+
+	if _, err := up.UpdateCommand(ctx, cmdPath, semver.MustParse("1.0.0"), "foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new binary" {
+		t.Fatalf("Command should be updated with the canned asset but got %q", b)
+	}
+	if n := src.Downloads(r.Assets[0].GetID()); n != 1 {
+		t.Fatal("Asset should be downloaded once but downloaded", n, "times")
+	}
+}
+
+func TestAddReleaseInvalidSlug(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Invalid slug should cause a panic")
+		}
+	}()
+	NewSource().AddRelease("foo", Release{Tag: "v1.0.0"})
+}
@@ -0,0 +1,116 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/go-github/v30/github"
+)
+
+// ReleaseSource is an interface to fetch releases and their assets from a release hosting service. GitHub Releases
+// is used by default. Other services such as GitLab can be supported by implementing this interface and setting it
+// to Config.Source. Releases are represented with the types of go-github so that the release detection is shared
+// by all sources. At least TagName, Draft, Prerelease and Assets (ID, Name, Size and BrowserDownloadURL) should be
+// filled.
+type ReleaseSource interface {
+	// ListReleases returns all releases of the repository. When the repository is not found, it should return
+	// no release without an error.
+	ListReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error)
+	// DownloadAsset returns the content of the release asset by its ID. The returned reader is closed by the caller.
+	DownloadAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error)
+}
+
+// gitHubSource is the default ReleaseSource which fetches releases via GitHub Releases API with the API client
+// of the updater.
+type gitHubSource struct {
+	up *Updater
+	// meta is the metadata of the HTTP responses of the last ListReleases call
+	meta *ResponseMetadata
+}
+
+func (s *gitHubSource) ListReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
+	s.meta = &ResponseMetadata{}
+	rels, res, err := s.up.listReleases(ctx, owner, repo, s.meta)
+	if err != nil {
+		if res != nil && res.StatusCode == 404 {
+			// 404 means repository not found or release not found. It's not an error here.
+			s.up.infof("API returned 404. Repository or release not found")
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rels, nil
+}
+
+// latestRelease fetches the latest release with the "latest release" endpoint of GitHub Releases API. The endpoint
+// excludes drafts and pre-releases on the server side. It costs only one API call.
+func (s *gitHubSource) latestRelease(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
+	s.meta = &ResponseMetadata{}
+	rel, res, err := s.up.api.Repositories.GetLatestRelease(ctx, owner, repo)
+	s.meta.update(res, true)
+	if err != nil {
+		if res != nil && res.StatusCode == 404 {
+			s.up.infof("API returned 404. Repository or latest release not found")
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []*github.RepositoryRelease{rel}, nil
+}
+
+// releaseByTag fetches the release by its tag name. The tags are tried in order and the first found release is
+// returned. When none of them is found, it returns no release without an error.
+func (s *gitHubSource) releaseByTag(ctx context.Context, owner, repo string, tags []string) ([]*github.RepositoryRelease, error) {
+	s.meta = &ResponseMetadata{}
+	for i, tag := range tags {
+		rel, res, err := s.up.api.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+		s.meta.update(res, i == 0)
+		if err != nil {
+			if res != nil && res.StatusCode == 404 {
+				s.up.debugf("API returned 404. Release for tag %s not found", tag)
+				continue
+			}
+			return nil, err
+		}
+		return []*github.RepositoryRelease{rel}, nil
+	}
+	return nil, nil
+}
+
+// DownloadAsset downloads the asset via GitHub Releases API. If a redirect occurs, it fallbacks into directly
+// downloading from the redirect URL.
+func (s *gitHubSource) DownloadAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	src, redirectURL, err := s.up.api.Repositories.DownloadReleaseAsset(ctx, owner, repo, id, s.up.httpClientForDownload())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to call GitHub Releases API for getting an asset(ID: %d) for repository '%s/%s': %w", id, owner, repo, err)
+	}
+	if redirectURL != "" {
+		s.up.debugf("Redirect URL was returned while trying to download a release asset from GitHub API. Falling back to downloading from asset URL directly: %s", redirectURL)
+		return s.up.downloadDirectlyFromURL(ctx, redirectURL)
+	}
+	return src, nil
+}
+
+// downloadAssetFrom downloads the asset via GitHub Releases API from the offset in bytes. The redirect URL returned
+// from the API is requested with Range and If-Range headers (see downloadRangeFromURL). The body is not ranged when
+// the API returned it without a redirect. In that case the body starts from the beginning of the asset.
+func (s *gitHubSource) downloadAssetFrom(ctx context.Context, owner, repo string, id int64, offset int64, ifRange string) (*rangedBody, error) {
+	src, redirectURL, err := s.up.api.Repositories.DownloadReleaseAsset(ctx, owner, repo, id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to call GitHub Releases API for getting an asset(ID: %d) for repository '%s/%s': %w", id, owner, repo, err)
+	}
+	if redirectURL == "" {
+		return &rangedBody{ReadCloser: src, ranged: offset == 0}, nil
+	}
+
+	return s.up.downloadRangeFromURL(ctx, redirectURL, offset, ifRange)
+}
+
+// releaseSource returns Config.Source or the GitHub source when it is not set.
+func (up *Updater) releaseSource() ReleaseSource {
+	if up.source != nil {
+		return up.source
+	}
+	return &gitHubSource{up: up}
+}
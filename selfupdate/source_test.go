@@ -0,0 +1,91 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-github/v30/github"
+)
+
+type fakeSource struct {
+	releases []*github.RepositoryRelease
+	assets   map[int64][]byte
+}
+
+func (s *fakeSource) ListReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
+	if owner != "foo" || repo != "bar" {
+		return nil, nil
+	}
+	return s.releases, nil
+}
+
+func (s *fakeSource) DownloadAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	b, ok := s.assets[id]
+	if !ok {
+		return nil, fmt.Errorf("asset %d not found", id)
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func TestCustomReleaseSource(t *testing.T) {
+	ctx := context.Background()
+
+	tag := "v1.2.3"
+	name := fmt.Sprintf("bar_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+	url := "https://gitlab.example.com/foo/bar/-/releases/v1.2.3/downloads/" + name
+	id := int64(42)
+	src := &fakeSource{
+		releases: []*github.RepositoryRelease{
+			{
+				TagName: &tag,
+				Assets:  []*github.ReleaseAsset{{ID: &id, Name: &name, BrowserDownloadURL: &url}},
+			},
+		},
+		assets: map[int64][]byte{id: zipScript(t, "bar", "new binary")},
+	}
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", Source: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := up.DetectLatest(ctx, "foo/unknown"); err != nil || ok {
+		t.Fatal("Unknown repository should not be detected:", ok, err)
+	}
+
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.Version.String() != "1.2.3" || rel.AssetID != id || rel.AssetURL != url {
+		t.Fatal("Release should be detected from custom source:", rel)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new binary" {
+		t.Error("Binary should be updated with the asset from custom source:", string(b))
+	}
+}
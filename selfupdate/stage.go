@@ -0,0 +1,85 @@
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StagedUpdate is an update whose binary was already downloaded and validated by Prepare. The binary at CmdPath is
+// not replaced until Commit is called, so an interactive updater can ask the user for confirmation after the slow
+// download finished and swap the binary instantly. Either Commit or Abort must be called to remove the staged file.
+type StagedUpdate struct {
+	// Release is the release to be installed
+	Release *Release
+	// CmdPath is the path to the binary to be replaced
+	CmdPath string
+	// Path is the path to the validated binary staged in a temporary file next to CmdPath
+	Path string
+
+	up   *Updater
+	mu   sync.Mutex
+	done bool
+}
+
+// Commit replaces the binary at CmdPath with the staged binary in the same way as UpdateTo. The previous binary is
+// kept so that the update can be rolled back with RollbackUpdate. Returned error is *UpdateError telling at which
+// stage it failed. The staged file is removed even when the replacement failed, and a staged update can be committed
+// only once.
+func (s *StagedUpdate) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return fmt.Errorf("Staged update of %s to version %s was already committed or aborted", s.CmdPath, s.Release.Version)
+	}
+	s.done = true
+	defer os.Remove(s.Path)
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to open staged binary %s: %s", s.Path, err)}
+	}
+	defer f.Close()
+
+	// The staged binary was already uncompressed and verified by Prepare
+	staged := func(src io.Reader, url, cmd string) (io.Reader, error) {
+		return src, nil
+	}
+	return s.up.replaceAndVerify(context.Background(), f, s.Release, s.CmdPath, staged, nil)
+}
+
+// Abort discards the staged binary without touching the binary at CmdPath. It does nothing when the staged update
+// was already committed or aborted, so it can be deferred safely.
+func (s *StagedUpdate) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil
+	}
+	s.done = true
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove staged binary %s: %s", s.Path, err)
+	}
+	return nil
+}
+
+// Prepare downloads and validates the release asset in the same way as UpdateTo and stages the uncompressed binary in
+// a temporary file next to cmdPath without replacing the binary. The binary is checked with Config.VerifyBinaryFormat
+// and Config.PostDownloadHook at this point. Call Commit of the returned StagedUpdate to replace the binary or Abort
+// to discard it. Returned error is *UpdateError telling at which stage it failed.
+func (up *Updater) Prepare(ctx context.Context, rel *Release, cmdPath string) (*StagedUpdate, error) {
+	data, err := up.downloadAndValidate(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+	path, err := uncompressToTempFile(ctx, bytes.NewReader(data), rel.AssetURL, cmdPath, filepath.Base(cmdPath), up.uncompressTargetCommand, up.binaryVerifier(), !up.disableSync)
+	if err != nil {
+		return nil, err
+	}
+	up.infof("Staged version %s of %s at %s", rel.Version, cmdPath, path)
+	return &StagedUpdate{Release: rel, CmdPath: cmdPath, Path: path, up: up}, nil
+}
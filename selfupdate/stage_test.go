@@ -0,0 +1,141 @@
+package selfupdate
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareCommitAndAbort(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	downloads := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+			http.NotFound(w, r)
+			return
+		}
+		downloads++
+		w.Write(asset)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	rel := &Release{AssetURL: "https://example.com/bar_linux_amd64.zip", AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+
+	hooked := 0
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		PostDownloadHook: func(path string) error {
+			hooked++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := up.Prepare(ctx, rel, cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(cmdPath); err != nil || string(b) != "old" {
+		t.Fatalf("Binary should not be replaced by Prepare: %q (%v)", b, err)
+	}
+	if b, err := ioutil.ReadFile(staged.Path); err != nil || string(b) != script {
+		t.Fatalf("Uncompressed binary should be staged: %q (%v)", b, err)
+	}
+	if filepath.Dir(staged.Path) != dir {
+		t.Fatal("Binary should be staged next to the command:", staged.Path)
+	}
+
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(cmdPath); err != nil || string(b) != script {
+		t.Fatalf("Binary should be replaced by Commit: %q (%v)", b, err)
+	}
+	if _, err := os.Stat(staged.Path); !os.IsNotExist(err) {
+		t.Fatal("Staged file should be removed after Commit:", err)
+	}
+	if !up.CanRollback(cmdPath) {
+		t.Fatal("Committed update should be able to be rolled back")
+	}
+	if downloads != 1 || hooked != 1 {
+		t.Fatalf("Asset should be downloaded and hooked only once: downloads=%d hooked=%d", downloads, hooked)
+	}
+	if err := staged.Commit(); err == nil {
+		t.Fatal("Staged update should not be committed twice")
+	}
+	if err := staged.Abort(); err != nil {
+		t.Fatal("Abort after Commit should do nothing:", err)
+	}
+
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	staged, err = up.Prepare(ctx, rel, cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(staged.Path); !os.IsNotExist(err) {
+		t.Fatal("Staged file should be removed after Abort:", err)
+	}
+	if b, err := ioutil.ReadFile(cmdPath); err != nil || string(b) != "old" {
+		t.Fatalf("Binary should not be replaced after Abort: %q (%v)", b, err)
+	}
+	if err := staged.Commit(); err == nil {
+		t.Fatal("Aborted update should not be committed")
+	}
+}
+
+func TestPrepareFailsOnBrokenAsset(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a zip file"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := &Release{AssetURL: "https://example.com/bar_linux_amd64.zip", AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+	_, err = up.Prepare(ctx, rel, cmdPath)
+	var uerr *UpdateError
+	if !errors.As(err, &uerr) || uerr.Stage != StageDownload {
+		t.Fatal("Broken asset should fail at download stage:", err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("No file should be staged for broken asset:", entries)
+	}
+}
@@ -3,42 +3,276 @@ package selfupdate
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/bodgit/sevenzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ulikunitz/xz"
 )
 
-func matchExecutableName(cmd, target string) bool {
-	if cmd == target {
-		return true
+// uncompressor uncompresses the executable of the command 'cmd' for the target platform from an asset. The target
+// platform decides the full executable names such as 'foo_linux_amd64' and 'limit' bounds the size of everything
+// uncompressed from the asset. 'verified' is true when the format of the uncompressed executable is verified later
+// with Config.VerifyBinaryFormat.
+type uncompressor struct {
+	cmd      string
+	os       string
+	archs    []string
+	limit    int64
+	verified bool
+	infof    func(format string, args ...interface{})
+}
+
+// newUncompressor returns the uncompressor of the command for the running platform.
+func newUncompressor(src io.Reader, cmd string) *uncompressor {
+	return &uncompressor{
+		cmd:   cmd,
+		os:    runtime.GOOS,
+		archs: withUniversalArchs(runtime.GOOS, archAliases(runtime.GOARCH, goarm())),
+		limit: uncompressLimit(src),
+		infof: log.Printf,
 	}
+}
 
-	o, a := runtime.GOOS, runtime.GOARCH
+// matchExecutableName returns whether the file name in an archive is the executable of the command. Names are
+// compared case-insensitively.
+func (u *uncompressor) matchExecutableName(target string) bool {
+	if strings.EqualFold(u.cmd, target) {
+		return true
+	}
 
 	// When the contained executable name is full name (e.g. foo_darwin_amd64),
 	// it is also regarded as a target executable file. (#19)
-	for _, d := range []rune{'_', '-'} {
-		c := fmt.Sprintf("%s%c%s%c%s", cmd, d, o, d, a)
-		if o == "windows" {
-			c += ".exe"
-		}
-		if c == target {
-			return true
+	for _, a := range u.archs {
+		for _, d := range []rune{'_', '-'} {
+			c := fmt.Sprintf("%s%c%s%c%s", u.cmd, d, u.os, d, a)
+			if u.os == "windows" {
+				c += ".exe"
+			}
+			if strings.EqualFold(c, target) {
+				return true
+			}
 		}
 	}
 
 	return false
 }
 
-func unarchiveTar(src io.Reader, url, cmd string) (io.Reader, error) {
+// DefaultArchiveDepth is the number of nested layers of archives and compressions uncompressed by UncompressCommand.
+// For example, a '.zip' archive containing a '.tar.gz' archive which contains the executable has 2 layers.
+const DefaultArchiveDepth = 2
+
+// maxUncompressedSize is the maximum size of an archive read into memory and of the uncompressed executable. It
+// guards against decompression bombs.
+var maxUncompressedSize int64 = 512 * 1024 * 1024
+
+// maxCompressionRatio bounds the size of data uncompressed from an asset by its size. Executables are rarely
+// compressed more than a few times.
+const maxCompressionRatio = 100
+
+// minUncompressLimit is the lower bound of the limit decided by maxCompressionRatio so that small assets with
+// padding such as tar archives are uncompressed.
+const minUncompressLimit = 1024 * 1024
+
+// uncompressLimit returns the maximum size of data uncompressed from the source. When the size of the source is known
+// such as a downloaded asset in memory, it is bounded by maxCompressionRatio times the size.
+func uncompressLimit(src io.Reader) int64 {
+	limit := maxUncompressedSize
+	if s, ok := src.(sizedReaderAt); ok {
+		l := s.Size() * maxCompressionRatio
+		if l < minUncompressLimit {
+			l = minUncompressLimit
+		}
+		if l < limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// limitedReader is a reader of the uncompressed executable. Unlike io.LimitReader, it fails when the source has more
+// than the limit.
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	limit int64
+	url   string
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		n = int(l.left)
+		l.left = 0
+		return n, fmt.Errorf("Executable uncompressed from %s is larger than %d bytes", l.url, l.limit)
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// readAll reads the whole source described by 'what' such as "zip file foo.zip" into memory. It fails when the
+// source is larger than the limit.
+func (u *uncompressor) readAll(src io.Reader, what string) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(src, u.limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", what, err)
+	}
+	if int64(len(b)) > u.limit {
+		return nil, fmt.Errorf("Size of %s is larger than %d bytes", what, u.limit)
+	}
+	return b, nil
+}
+
+// isArchiveName returns whether the file name has an extension of a supported archive or compression format.
+func isArchiveName(name string) bool {
+	switch assetFormat(strings.ToLower(name)) {
+	case FormatRaw, FormatDeb, FormatRpm:
+		return false
+	default:
+		return true
+	}
+}
+
+// archiveEntry is a file in an archive.
+type archiveEntry struct {
+	name string
+	mode os.FileMode
+}
+
+// isExecutable returns whether the entry is a regular file with executable permission.
+func (e archiveEntry) isExecutable() bool {
+	return e.mode.IsRegular() && e.mode.Perm()&0111 != 0
+}
+
+// nonBinaryExts are the file extensions of scripts and documents, which often have executable permission in archives
+// but are never the executable of the command.
+var nonBinaryExts = map[string]bool{
+	".sh": true, ".bash": true, ".zsh": true, ".fish": true, ".ps1": true, ".bat": true, ".cmd": true, ".py": true,
+	".rb": true, ".pl": true, ".js": true, ".txt": true, ".md": true, ".rst": true, ".html": true, ".pdf": true,
+	".json": true, ".yml": true, ".yaml": true, ".toml": true, ".1": true,
+}
+
+// docNames are the base names of documents without file extension which are often put in archives.
+var docNames = map[string]bool{
+	"license": true, "licence": true, "copying": true, "readme": true, "changelog": true, "changes": true,
+	"notice": true, "authors": true, "contributors": true,
+}
+
+// isLoneExecutableName returns whether the only executable file named 'name' in an archive may be regarded as the
+// executable of the command. Scripts and documents are never regarded. A file with another extension is only
+// regarded when its format is verified later, except for '.exe' on Windows.
+func (u *uncompressor) isLoneExecutableName(name string) bool {
+	if isArchiveName(name) {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if nonBinaryExts[ext] || docNames[strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))] {
+		return false
+	}
+	return ext == "" || (u.os == "windows" && ext == ".exe") || u.verified
+}
+
+// findExecutableEntry returns the index of the executable of the command in the entries of an archive. The base name
+// is matched regardless of the directories containing it. When no entry matches but the archive contains only one
+// executable file which looks like a binary (see isLoneExecutableName), it is regarded as the executable of the
+// command.
+func (u *uncompressor) findExecutableEntry(entries []archiveEntry) (int, bool) {
+	lone, executables := -1, 0
+	for i, e := range entries {
+		if e.mode.IsDir() {
+			continue
+		}
+		_, name := filepath.Split(e.name)
+		if u.matchExecutableName(name) {
+			return i, true
+		}
+		if e.isExecutable() && u.isLoneExecutableName(name) {
+			lone = i
+			executables++
+		}
+	}
+	if executables == 1 {
+		u.infof("Only executable file %s in archive is regarded as the command %s", entries[lone].name, u.cmd)
+		return lone, true
+	}
+	return -1, false
+}
+
+// isNestedArchiveOf returns whether the file name in an archive is a nested archive for the command such as
+// 'foo.tar.gz' in 'foo_linux_amd64.zip'.
+func isNestedArchiveOf(cmd, name string) bool {
+	return isArchiveName(name) && strings.HasPrefix(strings.ToLower(name), strings.ToLower(cmd))
+}
+
+// findNestedArchiveEntry returns the index of the nested archive which should contain the executable of the command.
+// An archive whose name starts with the command name is preferred. When no archive matches but the archive contains
+// only one nested archive, it is used.
+func findNestedArchiveEntry(cmd string, entries []archiveEntry) (int, bool) {
+	lone, archives := -1, 0
+	for i, e := range entries {
+		if e.mode.IsDir() {
+			continue
+		}
+		_, name := filepath.Split(e.name)
+		if isNestedArchiveOf(cmd, name) {
+			return i, true
+		}
+		if isArchiveName(name) {
+			lone = i
+			archives++
+		}
+	}
+	if archives == 1 {
+		return lone, true
+	}
+	return -1, false
+}
+
+// readNestedArchive reads the whole nested archive named 'name' in the asset at url.
+func (u *uncompressor) readNestedArchive(src io.Reader, name, url string) ([]byte, error) {
+	return u.readAll(src, fmt.Sprintf("nested archive %s in %s", name, url))
+}
+
+// uncompressNested uncompresses the nested archive named 'name' in the asset at url. The format of the nested
+// archive is detected from its name. 'depth' is the number of layers left for the nested archive.
+func (u *uncompressor) uncompressNested(src io.Reader, name, url string, depth int) (io.Reader, error) {
+	log.Println("Uncompressing nested archive", name, "in", url)
+	b, err := u.readNestedArchive(src, name, url)
+	if err != nil {
+		return nil, err
+	}
+	return u.uncompress(bytes.NewReader(b), name, depth)
+}
+
+// entryNotFoundError reports that the executable of the command was not found in the archive with all its entries.
+func entryNotFoundError(cmd, url string, entries []archiveEntry) error {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	return fmt.Errorf("File '%s' for the command is not found in %s (entries: %s)", cmd, url, strings.Join(names, ", "))
+}
+
+func (u *uncompressor) unarchiveTar(src io.Reader, url string, depth int) (io.Reader, error) {
 	t := tar.NewReader(src)
+	var entries []archiveEntry
+	var lone, nested []byte
+	var nestedName string
+	executables, archives := 0, 0
 	for {
 		h, err := t.Next()
 		if err == io.EOF {
@@ -47,30 +281,220 @@ func unarchiveTar(src io.Reader, url, cmd string) (io.Reader, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Failed to unarchive .tar file: %s", err)
 		}
+		e := archiveEntry{h.Name, h.FileInfo().Mode()}
+		entries = append(entries, e)
+		if e.mode.IsDir() {
+			continue
+		}
 		_, name := filepath.Split(h.Name)
-		if matchExecutableName(cmd, name) {
+		if u.matchExecutableName(name) {
 			log.Println("Executable file", h.Name, "was found in tar archive")
 			return t, nil
 		}
+		if depth > 1 && isArchiveName(name) {
+			// Keep the content of the nested archive since tar archive cannot be read again
+			archives++
+			if nested == nil || (!isNestedArchiveOf(u.cmd, nestedName) && isNestedArchiveOf(u.cmd, name)) {
+				b, err := u.readNestedArchive(t, h.Name, url)
+				if err != nil {
+					return nil, err
+				}
+				nested, nestedName = b, name
+			}
+			continue
+		}
+		if e.isExecutable() && u.isLoneExecutableName(name) {
+			// Since tar archive cannot be read again, keep the content in case it is the only executable
+			executables++
+			if executables == 1 {
+				b, err := u.readAll(t, fmt.Sprintf("file %s in %s", h.Name, url))
+				if err != nil {
+					return nil, err
+				}
+				lone = b
+			} else {
+				lone = nil
+			}
+		}
+	}
+
+	if executables == 1 {
+		u.infof("Only executable file in tar archive %s is regarded as the command %s", url, u.cmd)
+		return bytes.NewReader(lone), nil
 	}
+	if nested != nil && (archives == 1 || isNestedArchiveOf(u.cmd, nestedName)) {
+		log.Println("Uncompressing nested archive", nestedName, "in", url)
+		return u.uncompress(bytes.NewReader(nested), nestedName, depth-1)
+	}
+	return nil, entryNotFoundError(u.cmd, url, entries)
+}
 
-	return nil, fmt.Errorf("File '%s' for the command is not found in %s", cmd, url)
+// sizedReaderAt is a reader which can be read at random offsets, such as *bytes.Reader.
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// open7z opens the 7z archive at url. 7z format requires random access to the archive since its header is put at the
+// end. When the source already supports it, it is used directly without buffering the whole archive again.
+func (u *uncompressor) open7z(src io.Reader, url string) (r *sevenzip.Reader, err error) {
+	ra, ok := src.(sizedReaderAt)
+	if !ok {
+		buf, err := u.readAll(src, "7z file "+url)
+		if err != nil {
+			return nil, err
+		}
+		ra = bytes.NewReader(buf)
+	}
+
+	// The 7z reader may panic on broken headers instead of returning an error
+	defer func() {
+		if p := recover(); p != nil {
+			r, err = nil, fmt.Errorf("Failed to uncompress 7z file: broken header: %v", p)
+		}
+	}()
+
+	r, err = sevenzip.NewReader(ra, ra.Size())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to uncompress 7z file: %s", err)
+	}
+	return r, nil
+}
+
+// DecompressorFunc uncompresses the executable named 'cmdName' from the source asset and returns a reader for the
+// executable. It is registered to Config.Decompressors for a proprietary format which is not supported by
+// UncompressCommand.
+type DecompressorFunc func(src io.Reader, cmdName string) (io.Reader, error)
+
+// normalizeDecompressors normalizes the file extensions of the decompressors to lower case with a leading dot such
+// as '.enc'.
+func normalizeDecompressors(decompressors map[string]DecompressorFunc) (map[string]DecompressorFunc, error) {
+	if len(decompressors) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]DecompressorFunc, len(decompressors))
+	for ext, d := range decompressors {
+		e := strings.ToLower(strings.TrimPrefix(ext, "."))
+		if e == "" {
+			return nil, fmt.Errorf("File extension of decompressor must not be empty")
+		}
+		if d == nil {
+			return nil, fmt.Errorf("Decompressor for extension %q must not be nil", ext)
+		}
+		normalized["."+e] = d
+	}
+	return normalized, nil
+}
+
+// customExtensions returns the file extensions of Config.Decompressors in lexical order.
+func (up *Updater) customExtensions() []string {
+	exts := make([]string, 0, len(up.decompressors))
+	for ext := range up.decompressors {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// decompressorFor returns the longest file extension of Config.Decompressors which the asset name ends with and its
+// decompressor.
+func (up *Updater) decompressorFor(name string) (string, DecompressorFunc, bool) {
+	name = strings.ToLower(name)
+	ext := ""
+	for e := range up.decompressors {
+		if strings.HasSuffix(name, e) && len(e) > len(ext) {
+			ext = e
+		}
+	}
+	if ext == "" {
+		return "", nil, false
+	}
+	return ext, up.decompressors[ext], true
+}
+
+// uncompressCommand uncompresses the executable named 'cmd' from the source. A decompressor in Config.Decompressors
+// is consulted before the built-in formats of UncompressCommand. The executable is looked up for the target OS and
+// arch and nested archives are uncompressed up to Config.ArchiveDepth layers.
+func (up *Updater) uncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
+	limit := uncompressLimit(src)
+	ext, d, ok := up.decompressorFor(url)
+	if !ok {
+		depth := up.archiveDepth
+		if depth <= 0 {
+			depth = DefaultArchiveDepth
+		}
+		u := &uncompressor{
+			cmd:      cmd,
+			os:       up.targetOS(),
+			archs:    up.targetArchAliases(),
+			limit:    limit,
+			verified: up.verifyBinaryFormat,
+			infof:    up.infof,
+		}
+		return u.uncompressCommand(src, url, depth)
+	}
+	up.debugf("Uncompressing %s with decompressor for %q", url, ext)
+	r, err := d(src, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to uncompress %s with decompressor for %q: %w", url, ext, err)
+	}
+	return &limitedReader{r, limit, limit, url}, nil
+}
+
+// uncompressTargetCommand is the same as uncompressCommand but looks up the executable named Config.CommandName
+// instead of 'cmd' when it is set.
+func (up *Updater) uncompressTargetCommand(src io.Reader, url, cmd string) (io.Reader, error) {
+	if up.commandName != "" {
+		cmd = up.commandName
+	}
+	return up.uncompressCommand(src, url, cmd)
 }
 
 // UncompressCommand uncompresses the given source. Archive and compression format is
 // automatically detected from 'url' parameter, which represents the URL of asset.
 // This returns a reader for the uncompressed command given by 'cmd'. '.zip',
-// '.tar.gz', '.tar.xz', '.tgz', '.gz' and '.xz' are supported.
+// '.tar.gz', '.tar.xz', '.tgz', '.gz', '.xz', '.tar.zst', '.tzst', '.zst', '.tar.bz2', '.bz2', '.tar' and
+// '.7z' are supported. When the extension is missing or unknown, the format is detected from the magic bytes at
+// the beginning of the content. Nested archives such as a '.tar.gz' archive in a '.zip' archive are uncompressed up
+// to DefaultArchiveDepth layers.
 func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
+	return UncompressCommandDepth(src, url, cmd, DefaultArchiveDepth)
+}
+
+// UncompressCommandDepth uncompresses the given source in the same way as UncompressCommand, but nested archives are
+// uncompressed up to 'depth' layers. When the executable is not found in an archive, a nested archive whose name
+// starts with the command name (or the only nested archive) is uncompressed. A compressed stream which turns out to
+// be an archive or compressed again is also uncompressed. When depth is 1 or less, only one layer is uncompressed.
+// Updater uses Config.ArchiveDepth instead.
+//
+// To guard against decompression bombs, each archive read into memory and the uncompressed executable must not be
+// larger than 512MiB. When the size of the source is known such as *bytes.Reader, they must not be larger than 100
+// times the size either (at least 1MiB). Reading the returned reader fails when the executable is larger.
+func UncompressCommandDepth(src io.Reader, url, cmd string, depth int) (io.Reader, error) {
+	return newUncompressor(src, cmd).uncompressCommand(src, url, depth)
+}
+
+// uncompressCommand uncompresses the executable from the asset at url and limits the size of the executable.
+func (u *uncompressor) uncompressCommand(src io.Reader, url string, depth int) (io.Reader, error) {
+	r, err := u.uncompress(src, url, depth)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReader{r, u.limit, u.limit, url}, nil
+}
+
+// uncompress uncompresses the executable from the source whose format is detected from 'url'. 'depth' is the number
+// of layers left.
+func (u *uncompressor) uncompress(src io.Reader, url string, depth int) (io.Reader, error) {
 	switch {
 	case strings.HasSuffix(url, ".zip"):
 		log.Println("Uncompressing zip file", url)
 
 		// Zip format requires its file size for uncompressing.
 		// So we need to read the HTTP response into a buffer at first.
-		buf, err := ioutil.ReadAll(src)
+		buf, err := u.readAll(src, "zip file "+url)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to create buffer for zip file: %s", err)
+			return nil, err
 		}
 
 		r := bytes.NewReader(buf)
@@ -79,15 +503,50 @@ func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
 			return nil, fmt.Errorf("Failed to uncompress zip file: %s", err)
 		}
 
+		entries := make([]archiveEntry, 0, len(z.File))
 		for _, file := range z.File {
-			_, name := filepath.Split(file.Name)
-			if !file.FileInfo().IsDir() && matchExecutableName(cmd, name) {
-				log.Println("Executable file", file.Name, "was found in zip archive")
-				return file.Open()
+			entries = append(entries, archiveEntry{file.Name, file.Mode()})
+		}
+		if i, ok := u.findExecutableEntry(entries); ok {
+			log.Println("Executable file", z.File[i].Name, "was found in zip archive")
+			return z.File[i].Open()
+		}
+		if i, ok := findNestedArchiveEntry(u.cmd, entries); ok && depth > 1 {
+			f, err := z.File[i].Open()
+			if err != nil {
+				return nil, fmt.Errorf("Failed to open nested archive %s in %s: %s", z.File[i].Name, url, err)
 			}
+			defer f.Close()
+			return u.uncompressNested(f, z.File[i].Name, url, depth-1)
 		}
 
-		return nil, fmt.Errorf("File '%s' for the command is not found in %s", cmd, url)
+		return nil, entryNotFoundError(u.cmd, url, entries)
+	case strings.HasSuffix(url, ".7z"):
+		log.Println("Uncompressing 7z file", url)
+
+		z, err := u.open7z(src, url)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make([]archiveEntry, 0, len(z.File))
+		for _, file := range z.File {
+			entries = append(entries, archiveEntry{file.Name, file.Mode()})
+		}
+		if i, ok := u.findExecutableEntry(entries); ok {
+			log.Println("Executable file", z.File[i].Name, "was found in 7z archive")
+			return z.File[i].Open()
+		}
+		if i, ok := findNestedArchiveEntry(u.cmd, entries); ok && depth > 1 {
+			f, err := z.File[i].Open()
+			if err != nil {
+				return nil, fmt.Errorf("Failed to open nested archive %s in %s: %s", z.File[i].Name, url, err)
+			}
+			defer f.Close()
+			return u.uncompressNested(f, z.File[i].Name, url, depth-1)
+		}
+
+		return nil, entryNotFoundError(u.cmd, url, entries)
 	case strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz"):
 		log.Println("Uncompressing tar.gz file", url)
 
@@ -96,7 +555,7 @@ func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
 			return nil, fmt.Errorf("Failed to uncompress .tar.gz file: %s", err)
 		}
 
-		return unarchiveTar(gz, url, cmd)
+		return u.unarchiveTar(gz, url, depth)
 	case strings.HasSuffix(url, ".gzip") || strings.HasSuffix(url, ".gz"):
 		log.Println("Uncompressing gzip file", url)
 
@@ -105,13 +564,26 @@ func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
 			return nil, fmt.Errorf("Failed to uncompress gzip file downloaded from %s: %s", url, err)
 		}
 
+		// A tar archive may be compressed with gzip without '.tar' in its name
+		br := bufio.NewReader(r)
+		if isTar(br) {
+			log.Println("Uncompressed file from gzip is a tar archive", url)
+			return u.unarchiveTar(br, url, depth)
+		}
+
 		name := r.Header.Name
-		if !matchExecutableName(cmd, name) {
-			return nil, fmt.Errorf("File name '%s' does not match to command '%s' found in %s", name, cmd, url)
+		if depth > 1 && isArchiveName(name) {
+			return u.uncompressNested(br, name, url, depth-1)
+		}
+		if ext, ok := sniffFormat(br); ok && depth > 1 {
+			return u.uncompressNested(br, url+ext, url, depth-1)
+		}
+		if !u.matchExecutableName(name) {
+			return nil, fmt.Errorf("File name '%s' does not match to command '%s' found in %s", name, u.cmd, url)
 		}
 
 		log.Println("Executable file", name, "was found in gzip file")
-		return r, nil
+		return br, nil
 	case strings.HasSuffix(url, ".tar.xz"):
 		log.Println("Uncompressing tar.xz file", url)
 
@@ -120,7 +592,7 @@ func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
 			return nil, fmt.Errorf("Failed to uncompress .tar.xz file: %s", err)
 		}
 
-		return unarchiveTar(xzip, url, cmd)
+		return u.unarchiveTar(xzip, url, depth)
 	case strings.HasSuffix(url, ".xz"):
 		log.Println("Uncompressing xzip file", url)
 
@@ -129,9 +601,129 @@ func UncompressCommand(src io.Reader, url, cmd string) (io.Reader, error) {
 			return nil, fmt.Errorf("Failed to uncompress xzip file downloaded from %s: %s", url, err)
 		}
 
-		log.Println("Uncompressed file from xzip is assumed to be an executable", cmd)
-		return xzip, nil
+		return u.tarOrExecutable(xzip, "xzip", url, depth)
+	case strings.HasSuffix(url, ".tar.zst") || strings.HasSuffix(url, ".tzst"):
+		log.Println("Uncompressing tar.zst file", url)
+
+		zst, err := zstd.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to uncompress .tar.zst file: %s", err)
+		}
+
+		return u.unarchiveTar(zst, url, depth)
+	case strings.HasSuffix(url, ".zst"):
+		log.Println("Uncompressing zstd file", url)
+
+		zst, err := zstd.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to uncompress zstd file downloaded from %s: %s", url, err)
+		}
+
+		return u.tarOrExecutable(zst, "zstd", url, depth)
+	case strings.HasSuffix(url, ".tar.bz2"):
+		log.Println("Uncompressing tar.bz2 file", url)
+
+		return u.unarchiveTar(bzip2.NewReader(src), url, depth)
+	case strings.HasSuffix(url, ".bz2"):
+		log.Println("Uncompressing bzip2 file", url)
+
+		return u.tarOrExecutable(bzip2.NewReader(src), "bzip2", url, depth)
+	case strings.HasSuffix(url, ".tar"):
+		log.Println("Unarchiving tar file", url)
+
+		return u.unarchiveTar(src, url, depth)
+	}
+
+	// The extension is missing or unknown. Detect the format from the content as a fallback
+	br := bufio.NewReader(src)
+	if ext, ok := sniffFormat(br); ok {
+		log.Println("Format of", url, "was detected as", ext, "from its content")
+		return u.uncompressSniffed(br, url, ext, depth)
+	}
+	if isTar(br) {
+		log.Println("Unarchiving tar file detected from its content", url)
+		return u.unarchiveTar(br, url, depth)
 	}
 	log.Println("Uncompression is not needed", url)
-	return src, nil
+	return br, nil
+}
+
+// magicNumbers is the list of magic bytes at the beginning of the supported formats with their file extensions.
+var magicNumbers = []struct {
+	magic []byte
+	ext   string
+}{
+	{[]byte("PK\x03\x04"), ".zip"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), ".7z"},
+	{[]byte("\x1f\x8b"), ".gz"},
+	{[]byte("\xfd7zXZ\x00"), ".xz"},
+	{[]byte("\x28\xb5\x2f\xfd"), ".zst"},
+	{[]byte("BZh"), ".bz2"},
+}
+
+// sniffFormat detects the archive or compression format from the first bytes of the source and returns its file
+// extension. The source is not consumed.
+func sniffFormat(src *bufio.Reader) (string, bool) {
+	for _, m := range magicNumbers {
+		b, err := src.Peek(len(m.magic))
+		if err == nil && bytes.Equal(b, m.magic) {
+			return m.ext, true
+		}
+	}
+	return "", false
+}
+
+// isTar returns whether the source is a tar archive by checking the magic in the first header. The source is not
+// consumed.
+func isTar(src *bufio.Reader) bool {
+	b, err := src.Peek(262)
+	return err == nil && string(b[257:262]) == "ustar"
+}
+
+// uncompressSniffed uncompresses the source whose format was detected from its content. Since the name of the
+// asset tells nothing, the uncompressed stream is checked whether it is a tar archive again.
+func (u *uncompressor) uncompressSniffed(src *bufio.Reader, url, ext string, depth int) (io.Reader, error) {
+	var r io.Reader
+	switch ext {
+	case ".zip", ".7z":
+		return u.uncompress(src, url+ext, depth)
+	case ".gz":
+		gz, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to uncompress gzip file downloaded from %s: %s", url, err)
+		}
+		r = gz
+	case ".xz":
+		xzip, err := xz.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to uncompress xzip file downloaded from %s: %s", url, err)
+		}
+		r = xzip
+	case ".zst":
+		zst, err := zstd.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to uncompress zstd file downloaded from %s: %s", url, err)
+		}
+		r = zst
+	case ".bz2":
+		r = bzip2.NewReader(src)
+	}
+
+	return u.tarOrExecutable(r, ext[1:], url, depth)
+}
+
+// tarOrExecutable returns the executable from the uncompressed stream. When the stream is a tar archive (e.g.
+// '.tar.xz' named as '.xz'), the executable is looked up in the archive. When the stream is compressed or archived
+// again and 'depth' allows, it is uncompressed as a nested archive. Otherwise the stream is the executable.
+func (u *uncompressor) tarOrExecutable(r io.Reader, format, url string, depth int) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if isTar(br) {
+		log.Println("Uncompressed file from", format, "is a tar archive", url)
+		return u.unarchiveTar(br, url, depth)
+	}
+	if ext, ok := sniffFormat(br); ok && depth > 1 {
+		return u.uncompressNested(br, url+ext, url, depth-1)
+	}
+	log.Println("Uncompressed file from", format, "is assumed to be an executable", u.cmd)
+	return br, nil
 }
@@ -1,7 +1,10 @@
 package selfupdate
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -34,6 +37,12 @@ func getArchiveFileExt(file string) string {
 	if strings.HasSuffix(file, ".tar.xz") {
 		return ".tar.xz"
 	}
+	if strings.HasSuffix(file, ".tar.zst") {
+		return ".tar.zst"
+	}
+	if strings.HasSuffix(file, ".tar.bz2") {
+		return ".tar.bz2"
+	}
 	return filepath.Ext(file)
 }
 
@@ -47,6 +56,13 @@ func TestUncompress(t *testing.T) {
 		"testdata/foo.tgz",
 		"testdata/foo.tar.xz",
 		"testdata/single-file.xz",
+		"testdata/foo.tar.zst",
+		"testdata/single-file.zst",
+		"testdata/foo.tar.bz2",
+		"testdata/single-file.bz2",
+		"testdata/foo.tar",
+		"testdata/foo.7z",
+		"testdata/single-file.7z",
 	} {
 		t.Run(n, func(t *testing.T) {
 			f, err := os.Open(n)
@@ -73,6 +89,45 @@ func TestUncompress(t *testing.T) {
 	}
 }
 
+func TestUncompressDetectingFormatFromContent(t *testing.T) {
+	for _, n := range []string{
+		"testdata/foo.zip",
+		"testdata/foo.tar.gz",
+		"testdata/foo.tar.xz",
+		"testdata/single-file.xz",
+		"testdata/foo.tar.zst",
+		"testdata/single-file.zst",
+		"testdata/foo.tar.bz2",
+		"testdata/single-file.bz2",
+		"testdata/foo.tar",
+		"testdata/foo.7z",
+		"testdata/single-file.gz",
+	} {
+		t.Run(n, func(t *testing.T) {
+			f, err := os.Open(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// The asset name has no extension
+			url := "https://github.com/foo/bar/releases/download/v1.2.3/bar-linux"
+			r, err := UncompressCommand(f, url, "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bytes, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := string(bytes); s != "this is test\n" {
+				t.Fatal("Uncompressing failed into unexpected content", s)
+			}
+		})
+	}
+}
+
 func TestUncompressInvalidArchive(t *testing.T) {
 	for _, a := range []struct {
 		name string
@@ -85,6 +140,13 @@ func TestUncompressInvalidArchive(t *testing.T) {
 		{"testdata/invalid.xz", "Failed to uncompress xzip file"},
 		{"testdata/invalid-tar.tar.xz", "Failed to unarchive .tar file"},
 		{"testdata/invalid-xz.tar.xz", "Failed to uncompress .tar.xz file"},
+		{"testdata/invalid-tar.tar.zst", "Failed to unarchive .tar file"},
+		{"testdata/invalid-zst.tar.zst", "Failed to unarchive .tar file"},
+		{"testdata/invalid-tar.tar.bz2", "Failed to unarchive .tar file"},
+		{"testdata/invalid-bzip2.tar.bz2", "Failed to unarchive .tar file"},
+		{"testdata/invalid-tar.tar", "Failed to unarchive .tar file"},
+		{"testdata/invalid.7z", "Failed to uncompress 7z file"},
+		{"testdata/invalid-header.7z", "Failed to uncompress 7z file"},
 	} {
 		f, err := os.Open(a.name)
 		if err != nil {
@@ -113,6 +175,10 @@ func TestTargetNotFound(t *testing.T) {
 		{"testdata/bar-not-found.gzip", "does not match to command"},
 		{"testdata/empty.tar.gz", "command is not found"},
 		{"testdata/bar-not-found.tar.gz", "command is not found"},
+		{"testdata/bar-not-found.tar.zst", "command is not found"},
+		{"testdata/bar-not-found.tar.bz2", "command is not found"},
+		{"testdata/bar-not-found.tar", "command is not found"},
+		{"testdata/bar-not-found.7z", "command is not found"},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			f, err := os.Open(tc.name)
@@ -131,3 +197,308 @@ func TestTargetNotFound(t *testing.T) {
 		})
 	}
 }
+
+type testArchiveFile struct {
+	name string
+	mode os.FileMode
+	body string
+}
+
+func makeTestZip(t *testing.T, files []testArchiveFile) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		h := &zip.FileHeader{Name: f.name, Method: zip.Store}
+		h.SetMode(f.mode)
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTestTar(t *testing.T, files []testArchiveFile) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, f := range files {
+		h := &tar.Header{Name: f.name, Mode: int64(f.mode.Perm()), Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUncompressArchiveLayouts(t *testing.T) {
+	for _, tc := range []struct {
+		what  string
+		files []testArchiveFile
+		want  string
+	}{
+		{
+			"nested directory",
+			[]testArchiveFile{{"bar-1.2.0/README.md", 0644, "readme"}, {"bar-1.2.0/bin/bar", 0755, "this is bar"}},
+			"this is bar",
+		},
+		{
+			"different case",
+			[]testArchiveFile{{"Bar", 0644, "this is bar"}, {"LICENSE", 0644, "license"}},
+			"this is bar",
+		},
+		{
+			"lone executable",
+			[]testArchiveFile{{"README.md", 0644, "readme"}, {"dist/bar-cli", 0755, "this is bar"}, {"LICENSE", 0644, "license"}},
+			"this is bar",
+		},
+		{
+			"lone executable with scripts and documents",
+			[]testArchiveFile{{"install.sh", 0755, "#!/bin/sh"}, {"LICENSE", 0755, "license"}, {"bar-cli", 0755, "this is bar"}},
+			"this is bar",
+		},
+	} {
+		for ext, data := range map[string][]byte{
+			".zip": makeTestZip(t, tc.files),
+			".tar": makeTestTar(t, tc.files),
+		} {
+			t.Run(tc.what+ext, func(t *testing.T) {
+				url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64" + ext
+				r, err := UncompressCommand(bytes.NewReader(data), url, "bar")
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tc.want {
+					t.Fatalf("Wanted %q but got %q", tc.want, string(b))
+				}
+			})
+		}
+	}
+
+	// Multiple executables are ambiguous
+	files := []testArchiveFile{{"foo", 0755, "foo"}, {"baz", 0755, "baz"}}
+	for ext, data := range map[string][]byte{
+		".zip": makeTestZip(t, files),
+		".tar": makeTestTar(t, files),
+	} {
+		url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64" + ext
+		_, err := UncompressCommand(bytes.NewReader(data), url, "bar")
+		if err == nil {
+			t.Fatal("Error should be returned for", ext)
+		}
+		if !strings.Contains(err.Error(), "entries: foo, baz") {
+			t.Error("Error should list all entries:", err)
+		}
+	}
+
+	// Scripts, documents and files with unknown extensions are not regarded as the executable
+	files = []testArchiveFile{{"install.sh", 0755, "#!/bin/sh"}, {"LICENSE", 0755, "license"}, {"bar.bin", 0755, "this is bar"}}
+	for ext, data := range map[string][]byte{
+		".zip": makeTestZip(t, files),
+		".tar": makeTestTar(t, files),
+	} {
+		url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64" + ext
+		_, err := UncompressCommand(bytes.NewReader(data), url, "bar")
+		if err == nil {
+			t.Fatal("Error should be returned for", ext)
+		}
+		if !strings.Contains(err.Error(), "entries: install.sh, LICENSE, bar.bin") {
+			t.Error("Error should list all entries:", err)
+		}
+
+		// The file with unknown extension is accepted when its format is verified later
+		up := &Updater{verifyBinaryFormat: true}
+		r, err := up.uncompressCommand(bytes.NewReader(data), url, "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "this is bar" {
+			t.Fatalf("Unexpected content %q for %s", string(b), ext)
+		}
+	}
+}
+
+func TestUncompressTarWithoutTarExtension(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		ext  string
+	}{
+		{"testdata/foo.tar.gz", ".gz"},
+		{"testdata/foo.tar.gz", ".gzip"},
+		{"testdata/single-file.gz", ".gz"},
+		{"testdata/foo.tar.xz", ".xz"},
+		{"testdata/foo.tar.zst", ".zst"},
+		{"testdata/foo.tar.bz2", ".bz2"},
+	} {
+		t.Run(tc.file+tc.ext, func(t *testing.T) {
+			f, err := os.Open(tc.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64" + tc.ext
+			r, err := UncompressCommand(f, url, "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := string(b); s != "this is test\n" {
+				t.Fatal("Uncompressing failed into unexpected content", s)
+			}
+		})
+	}
+}
+
+func TestUncompressNestedArchive(t *testing.T) {
+	tgz, err := ioutil.ReadFile("testdata/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := makeTestZip(t, []testArchiveFile{{"bar", 0755, "this is test\n"}})
+
+	for _, tc := range []struct {
+		what string
+		ext  string
+		data []byte
+	}{
+		{"tar.gz in zip", ".zip", makeTestZip(t, []testArchiveFile{{"README.md", 0644, "readme"}, {"bar.tar.gz", 0644, string(tgz)}})},
+		{"only archive in zip", ".zip", makeTestZip(t, []testArchiveFile{{"dist/archive.tgz", 0755, string(tgz)}})},
+		{"zip in tar", ".tar", makeTestTar(t, []testArchiveFile{{"other.zip", 0644, "broken"}, {"bar-1.2.3.zip", 0644, string(inner)}})},
+		{"zip compressed with gzip", ".gz", gzipBytes(t, inner)},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64" + tc.ext
+			r, err := UncompressCommand(bytes.NewReader(tc.data), url, "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "this is test\n" {
+				t.Fatalf("Uncompressing nested archive failed into unexpected content %q", string(b))
+			}
+
+			if _, err := UncompressCommandDepth(bytes.NewReader(tc.data), url, "bar", 1); err == nil {
+				t.Fatal("Nested archive should not be uncompressed with depth 1")
+			}
+		})
+	}
+
+	saved := maxUncompressedSize
+	maxUncompressedSize = 16
+	defer func() { maxUncompressedSize = saved }()
+	data := makeTestZip(t, []testArchiveFile{{"bar.tar.gz", 0644, string(tgz)}})
+	_, err = UncompressCommand(bytes.NewReader(data), "https://github.com/foo/bar/releases/download/v1.2.3/bar.zip", "bar")
+	if err == nil {
+		t.Fatal("Error should occur for too large nested archive")
+	}
+	if !strings.Contains(err.Error(), "is larger than 16 bytes") {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUncompressSizeLimit(t *testing.T) {
+	url := "https://github.com/foo/bar/releases/download/v1.2.3/bar_linux_amd64"
+
+	bomb := gzipBytes(t, make([]byte, 2*minUncompressLimit))
+	r, err := UncompressCommand(bytes.NewReader(bomb), url, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil || !strings.Contains(err.Error(), "is larger than 1048576 bytes") {
+		t.Fatal("Reading executable larger than 100 times the asset should fail:", err)
+	}
+
+	saved := maxUncompressedSize
+	maxUncompressedSize = 16
+	defer func() { maxUncompressedSize = saved }()
+	for _, tc := range []struct {
+		what string
+		ext  string
+		data []byte
+	}{
+		{"zip", ".zip", makeTestZip(t, []testArchiveFile{{"bar", 0755, "this is test\n"}})},
+		{"lone executable in tar", ".tar", makeTestTar(t, []testArchiveFile{{"bar-cli", 0755, "this is larger than limit\n"}})},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			_, err := UncompressCommand(bytes.NewReader(tc.data), url+tc.ext, "bar")
+			if err == nil {
+				t.Fatal("Error should occur for too large archive")
+			}
+			if !strings.Contains(err.Error(), "is larger than 16 bytes") {
+				t.Fatal("Unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestUpdaterUncompressCommand(t *testing.T) {
+	url := "https://github.com/foo/bar/releases/download/v1.2.3/bar.zip"
+	data := makeTestZip(t, []testArchiveFile{
+		{"README.md", 0644, "readme"},
+		{"bar_windows_arm64.exe", 0644, "this is test\n"},
+	})
+	up := &Updater{os: "windows", arch: "arm64"}
+	r, err := up.uncompressCommand(bytes.NewReader(data), url, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "this is test\n" {
+		t.Fatalf("Unexpected content %q", string(b))
+	}
+
+	tgz, err := ioutil.ReadFile("testdata/foo.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := makeTestZip(t, []testArchiveFile{{"bar.tar.gz", 0644, string(tgz)}})
+	up = &Updater{archiveDepth: 1}
+	if _, err := up.uncompressCommand(bytes.NewReader(nested), url, "bar"); err == nil {
+		t.Fatal("Nested archive should not be uncompressed with ArchiveDepth 1")
+	}
+	up = &Updater{}
+	if _, err := up.uncompressCommand(bytes.NewReader(nested), url, "bar"); err != nil {
+		t.Fatal("Nested archive should be uncompressed with default depth:", err)
+	}
+}
@@ -3,111 +3,1071 @@ package selfupdate
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
+	"github.com/google/go-github/v30/github"
 	"github.com/inconshreveable/go-update"
 )
 
-func uncompressAndUpdate(ctx context.Context, src io.Reader, assetURL, cmdPath string) error {
-	_, cmd := filepath.Split(cmdPath)
-	asset, err := UncompressCommand(src, assetURL, cmd)
+// ProgressFunc is a callback to report progress of downloading a release asset. 'downloaded' is the number
+// of bytes downloaded so far and 'total' is the size of the asset in bytes. 'total' is zero or less when the
+// size is unknown.
+type ProgressFunc func(downloaded, total int64)
+
+// progressReader is a reader which reports the number of bytes read so far on each read.
+type progressReader struct {
+	r          io.Reader
+	downloaded int64
+	total      int64
+	progress   ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.downloaded += int64(n)
+		p.progress(p.downloaded, p.total)
+	}
+	return n, err
+}
+
+// contextReader is a reader which fails once the context is done so that cancellation stops reading a large asset
+// such as on uncompressing it.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// UpdateStage represents a stage of updating a binary.
+type UpdateStage string
+
+const (
+	// StageDownload is the stage of downloading a release asset and uncompressing it into a temporary file.
+	StageDownload UpdateStage = "download"
+	// StageValidation is the stage of validating a downloaded release asset with a Validator.
+	StageValidation UpdateStage = "validation"
+	// StageReplacement is the stage of replacing the current binary with the new one.
+	StageReplacement UpdateStage = "replacement"
+	// StageVerification is the stage of verifying the version of the new binary with Config.VersionCommand.
+	StageVerification UpdateStage = "verification"
+)
+
+// UpdateError is an error which occurred while updating a binary. Stage tells at which stage the update
+// failed. The current binary is not modified when the stage is StageDownload or StageValidation so
+// the update is safe to retry.
+type UpdateError struct {
+	Stage UpdateStage
+	Err   error
+}
+
+func (e *UpdateError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *UpdateError) Unwrap() error {
+	return e.Err
+}
+
+// uncompressAndUpdate uncompresses the asset and replaces the binary at cmdPath with it. When oldSavePath is not empty,
+// the previous binary is kept at the path after the update. When verify is not nil, it is called with the path to
+// the uncompressed binary before the replacement. When the context is done before the replacement, the binary is not
+// replaced.
+func uncompressAndUpdate(ctx context.Context, src io.Reader, assetURL, cmdPath, oldSavePath string, uncompress uncompressFunc, verify func(string) error, durable bool) error {
+	tmp, err := uncompressToTempFile(ctx, src, assetURL, cmdPath, filepath.Base(cmdPath), uncompress, verify, durable)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := ctx.Err(); err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Update of %s was cancelled before replacing it: %w", cmdPath, err)}
+	}
+
+	bin, err := os.Open(tmp)
+	if err != nil {
+		return &UpdateError{StageDownload, fmt.Errorf("Failed to open downloaded binary %s: %s", tmp, err)}
+	}
+	defer bin.Close()
+
+	// The previous binary cannot be moved to the backup on another filesystem by rename. It is copied instead
+	if oldSavePath != "" && !sameFilesystem(filepath.Dir(cmdPath), filepath.Dir(oldSavePath)) {
+		if err := copyFile(cmdPath, oldSavePath); err != nil {
+			return &UpdateError{StageReplacement, fmt.Errorf("Failed to keep previous binary: %s", err)}
+		}
+		oldSavePath = ""
+	}
+
+	if err := update.Apply(bin, update.Options{
+		TargetPath:  cmdPath,
+		OldSavePath: oldSavePath,
+	}); err != nil {
+		if isBinaryInUse(err) {
+			err = fmt.Errorf("%w: %s cannot be replaced until all processes running it exit. Please retry the update on the next start (%s)", ErrBinaryInUse, cmdPath, err)
+		}
+		return &UpdateError{StageReplacement, err}
+	}
+	if durable {
+		// The binary is written again by update.Apply so it is flushed after the replacement
+		if err := syncPath(cmdPath); err != nil {
+			return &UpdateError{StageReplacement, err}
+		}
+		syncDir(filepath.Dir(cmdPath))
+	}
+	return nil
+}
+
+// syncFile flushes the content of the file to disk. It is a variable so that tests can observe the calls.
+var syncFile = (*os.File).Sync
+
+// syncPath flushes the content of the file at path to disk.
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s to sync: %s", path, err)
+	}
+	defer f.Close()
+	if err := syncFile(f); err != nil {
+		return fmt.Errorf("Failed to sync %s: %s", path, err)
+	}
+	return nil
+}
+
+// uncompressFunc uncompresses the executable named 'cmd' from the asset such as UncompressCommand.
+type uncompressFunc func(src io.Reader, url, cmd string) (io.Reader, error)
+
+// uncompressToTempFile uncompresses the executable named 'cmd' in the asset into a temporary file next to cmdPath and
+// returns the path to the file. When durable is true, the file is flushed to disk before it is closed. The caller
+// must remove the file. Reading the asset stops when the context is done and then an error wrapping the context's
+// error is returned.
+func uncompressToTempFile(ctx context.Context, src io.Reader, assetURL, cmdPath, cmd string, uncompress uncompressFunc, verify func(string) error, durable bool) (string, error) {
+	asset, err := uncompress(&contextReader{ctx, src}, assetURL, cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("Uncompressing %s was cancelled: %w", assetURL, ctx.Err())
+		}
+		return "", &UpdateError{StageDownload, err}
+	}
+
+	// Write the whole binary to a temporary file next to the command at first so that an interrupted
+	// download or a broken archive never touches the current binary.
+	tmp, err := ioutil.TempFile(filepath.Dir(cmdPath), "."+filepath.Base(cmdPath)+".*.tmp")
+	if err != nil {
+		return "", &UpdateError{StageDownload, fmt.Errorf("Failed to create temporary file for %s: %s", cmdPath, err)}
+	}
+	if _, err := io.Copy(tmp, &contextReader{ctx, asset}); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		if ctx.Err() != nil {
+			err = fmt.Errorf("Uncompressing %s was cancelled: %w", assetURL, ctx.Err())
+		} else {
+			err = fmt.Errorf("Failed to write downloaded binary to %s: %s", tmp.Name(), err)
+		}
+		return "", &UpdateError{StageDownload, err}
+	}
+	if durable {
+		if err := syncFile(tmp); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return "", &UpdateError{StageDownload, fmt.Errorf("Failed to sync downloaded binary %s: %s", tmp.Name(), err)}
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", &UpdateError{StageDownload, fmt.Errorf("Failed to write downloaded binary to %s: %s", tmp.Name(), err)}
+	}
+
+	if verify != nil {
+		if err := verify(tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			return "", &UpdateError{StageValidation, err}
+		}
+	}
+	return tmp.Name(), nil
+}
+
+// updateVersionedSymlink puts the new binary next to the target of the symbolic link at cmdPath as '<cmd>-<version>'
+// and repoints the link to it atomically. The previous target is kept as is. When oldSavePath is not empty, a link
+// to the previous target is created at the path so that the update can be rolled back.
+func updateVersionedSymlink(ctx context.Context, src io.Reader, rel *Release, cmdPath, oldSavePath string, uncompress uncompressFunc, verify func(string) error, durable bool) error {
+	prev, err := os.Readlink(cmdPath)
+	if err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to read symlink %s: %s", cmdPath, err)}
+	}
+	linkDir := filepath.Dir(cmdPath)
+	target := prev
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(linkDir, target)
+	}
+	versioned := filepath.Join(filepath.Dir(target), filepath.Base(cmdPath)+"-"+rel.Version.String())
+
+	// The executable in the asset is named after the link, not the versioned binary
+	tmp, err := uncompressToTempFile(ctx, src, rel.AssetURL, versioned, filepath.Base(cmdPath), uncompress, verify, durable)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := ctx.Err(); err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Update of %s was cancelled before replacing it: %w", cmdPath, err)}
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to make %s executable: %s", tmp, err)}
+	}
+	if err := os.Rename(tmp, versioned); err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to put new binary at %s: %s", versioned, err)}
+	}
+
+	// Keep the same style of link as the previous one. The backup link in another directory needs an absolute path
+	backupTarget := prev
+	if !filepath.IsAbs(prev) && filepath.Dir(oldSavePath) != linkDir {
+		backupTarget = target
+	}
+	link := versioned
+	if !filepath.IsAbs(prev) {
+		if r, err := filepath.Rel(linkDir, versioned); err == nil {
+			link = r
+		}
+	}
+
+	if oldSavePath != "" {
+		os.Remove(oldSavePath)
+		if err := os.Symlink(backupTarget, oldSavePath); err != nil {
+			return &UpdateError{StageReplacement, fmt.Errorf("Failed to keep previous symlink at %s: %s", oldSavePath, err)}
+		}
+	}
+
+	newLink := filepath.Join(linkDir, "."+filepath.Base(cmdPath)+".new")
+	os.Remove(newLink)
+	if err := os.Symlink(link, newLink); err != nil {
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to create symlink to %s: %s", link, err)}
+	}
+	if err := os.Rename(newLink, cmdPath); err != nil {
+		os.Remove(newLink)
+		return &UpdateError{StageReplacement, fmt.Errorf("Failed to repoint symlink %s to %s: %s", cmdPath, link, err)}
+	}
+	if durable {
+		syncDir(filepath.Dir(versioned))
+		if filepath.Dir(versioned) != linkDir {
+			syncDir(linkDir)
+		}
+	}
+	return nil
+}
+
+// isSymlink returns whether the file at path is a symbolic link.
+func isSymlink(path string) bool {
+	s, err := os.Lstat(path)
+	return err == nil && s.Mode()&os.ModeSymlink != 0
+}
+
+// parseVersionOutput finds a semantic version in the output of a version command such as 'foo version v1.2.3'.
+func parseVersionOutput(out string) (semver.Version, bool) {
+	for _, field := range strings.Fields(out) {
+		indices := reVersion.FindStringIndex(field)
+		if indices == nil {
+			continue
+		}
+		if v, err := semver.Make(field[indices[0]:]); err == nil {
+			return v, true
+		}
+		if v, err := semver.Make(field[indices[0]:indices[1]]); err == nil {
+			return v, true
+		}
+	}
+	return semver.Version{}, false
+}
+
+// verifyVersion runs the binary at cmdPath with the arguments and checks that it reports the expected version.
+func verifyVersion(ctx context.Context, cmdPath string, args []string, expected semver.Version) error {
+	out, err := exec.CommandContext(ctx, cmdPath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to run version command %q for %s: %s", strings.Join(args, " "), cmdPath, err)
+	}
+	v, ok := parseVersionOutput(string(out))
+	if !ok {
+		return fmt.Errorf("Version was not found in output of version command %q for %s: %q", strings.Join(args, " "), cmdPath, out)
+	}
+	if !v.Equals(expected) {
+		return fmt.Errorf("Updated binary %s reports version %s but %s was expected", cmdPath, v, expected)
+	}
+	return nil
+}
+
+// backupPath returns the path where the previous binary is kept after updating the binary at cmdPath.
+func backupPath(cmdPath string) string {
+	dir, file := filepath.Split(cmdPath)
+	return filepath.Join(dir, fmt.Sprintf(".%s.old", file))
+}
+
+// updateAndVerify replaces the binary at cmdPath with the asset of the release. The previous binary is kept at
+// backupPath(cmdPath) so that the update can be rolled back with RollbackUpdate. When Config.VersionCommand is set,
+// the version of the new binary is verified and the previous binary is restored when it does not match.
+func (up *Updater) updateAndVerify(ctx context.Context, src io.Reader, rel *Release, cmdPath string) error {
+	return up.replaceAndVerify(ctx, src, rel, cmdPath, up.uncompressTargetCommand, up.binaryVerifier())
+}
+
+// binaryVerifier returns the function to check the uncompressed binary before the replacement with
+// Config.VerifyBinaryFormat and Config.PostDownloadHook. It returns nil when neither is set.
+func (up *Updater) binaryVerifier() func(string) error {
+	if !up.verifyBinaryFormat && up.postDownloadHook == nil {
+		return nil
+	}
+	return func(path string) error {
+		if up.verifyBinaryFormat {
+			if err := verifyBinaryFormat(path, up.targetOS(), up.targetArch()); err != nil {
+				return err
+			}
+		}
+		if up.postDownloadHook != nil {
+			up.debugf("Running post-download hook for %s", path)
+			if err := up.postDownloadHook(path); err != nil {
+				return fmt.Errorf("Post-download hook failed for %s: %w", path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// replaceAndVerify replaces the binary at cmdPath in the same way as updateAndVerify. The executable is uncompressed
+// from src with 'uncompress' and checked with 'verify' when it is not nil.
+func (up *Updater) replaceAndVerify(ctx context.Context, src io.Reader, rel *Release, cmdPath string, uncompress uncompressFunc, verify func(string) error) error {
+	versioned := up.symlinkStrategy == SymlinkVersioned && isSymlink(cmdPath)
+	if !versioned && isSymlink(cmdPath) {
+		p, err := filepath.EvalSymlinks(cmdPath)
+		if err != nil {
+			return &UpdateError{StageReplacement, fmt.Errorf("Failed to resolve symlink '%s' for executable: %s", cmdPath, err)}
+		}
+		// The executable in the asset is named after the link, not its target such as 'foo-1.2.3'
+		if name := filepath.Base(cmdPath); name != filepath.Base(p) {
+			inner := uncompress
+			uncompress = func(src io.Reader, url, cmd string) (io.Reader, error) {
+				return inner(src, url, name)
+			}
+		}
+		cmdPath = p
+	}
+
+	up.infof("Will update %s to version %s downloaded from %s", cmdPath, rel.Version, rel.AssetURL)
+	var orig os.FileInfo
+	if up.preservePermissions {
+		s, err := os.Stat(cmdPath)
+		if err != nil {
+			up.infof("Permissions of %s cannot be preserved: %s", cmdPath, err)
+		}
+		orig = s
+	}
+	old := up.backupPathOf(cmdPath)
+	if up.backupDir != "" {
+		if err := os.MkdirAll(up.backupDir, 0755); err != nil {
+			return &UpdateError{StageReplacement, fmt.Errorf("Failed to create backup directory %s: %s", up.backupDir, err)}
+		}
+	}
+	if err := rotateBackups(old, cmdPath, up.keepBackups); err != nil {
+		return &UpdateError{StageReplacement, err}
+	}
+	var err error
+	if versioned {
+		err = updateVersionedSymlink(ctx, src, rel, cmdPath, old, uncompress, verify, !up.disableSync)
+	} else {
+		err = uncompressAndUpdate(ctx, src, rel.AssetURL, cmdPath, old, uncompress, verify, !up.disableSync)
+	}
 	if err != nil {
+		shiftBackups(old)
 		return err
 	}
+	if orig != nil {
+		up.restorePermissions(cmdPath, orig)
+	}
+	if len(up.versionCommand) == 0 {
+		return nil
+	}
+
+	if err := verifyVersion(ctx, cmdPath, up.versionCommand, rel.Version); err != nil {
+		up.infof("Verification of updated binary failed. Rolling back to previous binary: %s", err)
+		if rerr := moveFile(old, cmdPath); rerr != nil {
+			return &UpdateError{StageVerification, fmt.Errorf("%s. Additionally failed to roll back to %s: %s", err, old, rerr)}
+		}
+		shiftBackups(old)
+		return &UpdateError{StageVerification, err}
+	}
+
+	up.infof("Verified updated binary %s reports version %s", cmdPath, rel.Version)
+	return nil
+}
+
+// restorePermissions applies the mode bits and the ownership of the previous binary to the updated binary. Since
+// changing ownership requires a privilege, failures are only logged.
+func (up *Updater) restorePermissions(cmdPath string, orig os.FileInfo) {
+	if uid, gid, ok := fileOwner(orig); ok {
+		if err := os.Chown(cmdPath, uid, gid); err != nil {
+			up.infof("Could not preserve ownership of %s: %s", cmdPath, err)
+		}
+	}
+	// Mode is set after chown since chown may clear setuid and setgid bits
+	mode := orig.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if err := os.Chmod(cmdPath, mode); err != nil {
+		up.infof("Could not preserve permissions of %s: %s", cmdPath, err)
+	}
+}
+
+// CanRollback returns whether the previous binary of cmdPath kept by the last update exists.
+func (up *Updater) CanRollback(cmdPath string) bool {
+	s, err := os.Stat(up.backupPathOf(cmdPath))
+	return err == nil && s.Mode().IsRegular()
+}
+
+// RollbackUpdate restores the previous binary kept by the last update over cmdPath. The backup is moved with
+// atomic rename so it is consumed by the rollback. When older backups are retained with Config.KeepBackups, the
+// next newest one becomes the backup so that rollbacks can be repeated. When no backup exists, the returned error
+// wraps os.ErrNotExist.
+func (up *Updater) RollbackUpdate(cmdPath string) error {
+	old := up.backupPathOf(cmdPath)
+	if _, err := os.Stat(old); err != nil {
+		return fmt.Errorf("Backup to roll back %s is not available: %w", cmdPath, err)
+	}
+	if err := moveFile(old, cmdPath); err != nil {
+		return fmt.Errorf("Failed to roll back %s to %s: %s", cmdPath, old, err)
+	}
+	shiftBackups(old)
+	up.infof("Rolled back %s to the previous binary", cmdPath)
+	return nil
+}
+
+// httpClientForDownload returns the HTTP client to download release assets. It does not add an API token to requests.
+func (up *Updater) httpClientForDownload() *http.Client {
+	if up.downloadClient == nil {
+		return http.DefaultClient
+	}
+	return up.downloadClient
+}
+
+// downloadStatusError is returned when the server responded with non-successful status while downloading directly
+// from URL.
+type downloadStatusError struct {
+	url    string
+	status int
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("Failed to download a release file from %s: Not successful status %d", e.url, e.status)
+}
+
+// DownloadRetryError is returned when downloading an asset still failed after all retries configured with
+// Config.DownloadRetries. Err is the error at the last attempt.
+type DownloadRetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *DownloadRetryError) Error() string {
+	return fmt.Sprintf("Failed to download after %d attempts: %s", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error at the last attempt.
+func (e *DownloadRetryError) Unwrap() error {
+	return e.Err
+}
 
-	log.Println("Will update", cmdPath, "to the latest downloaded from", assetURL)
-	return update.Apply(asset, update.Options{
-		TargetPath: cmdPath,
-	})
+// IntegrityError is returned from VerifyInstalled when the installed binary does not match the release.
+type IntegrityError struct {
+	// CmdPath is the path to the installed binary
+	CmdPath string
+	// Release is the release the binary was verified against
+	Release *Release
+	// Err is the reason of the mismatch. It is *ValidationError when the binary was validated with the validation
+	// asset directly
+	Err error
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("Installed binary %s does not match release %s: %s", e.CmdPath, e.Release.Version, e.Err)
+}
+
+// Unwrap returns the reason of the mismatch.
+func (e *IntegrityError) Unwrap() error {
+	return e.Err
 }
 
 func (up *Updater) downloadDirectlyFromURL(ctx context.Context, assetURL string) (io.ReadCloser, error) {
+	body, err := up.downloadRangeFromURL(ctx, assetURL, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return body.ReadCloser, nil
+}
+
+// rangedBody is the body of a response to a possibly ranged download.
+type rangedBody struct {
+	io.ReadCloser
+	// ranged is true when the body starts from the requested offset
+	ranged bool
+	// validator is the strong ETag or Last-Modified of the response to resume the download with If-Range later
+	validator string
+}
+
+// responseValidator returns the value of the response headers usable for If-Range. Weak ETags cannot be used.
+func responseValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// downloadRangeFromURL downloads the file from the URL. When offset is positive, the range from the offset is
+// requested with If-Range set to 'ifRange' so that the server sends the whole file instead when it was changed.
+// The body is ranged when the server responded with the range. Otherwise the body starts from the beginning of
+// the file.
+func (up *Updater) downloadRangeFromURL(ctx context.Context, assetURL string, offset int64, ifRange string) (*rangedBody, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", assetURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create HTTP request to %s: %s", assetURL, err)
 	}
 
 	req.Header.Add("Accept", "application/octet-stream")
+	if offset > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+		if ifRange != "" {
+			req.Header.Add("If-Range", ifRange)
+		}
+	}
 
 	// OAuth HTTP client is not available to download blob from URL when the URL is a redirect URL
 	// returned from GitHub Releases API (response status 400).
-	// Use default HTTP client instead.
-	res, err := http.DefaultClient.Do(req)
+	// Use the HTTP client without authentication instead.
+	res, err := up.httpClientForDownload().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to download a release file from %s: %s", assetURL, err)
+		return nil, fmt.Errorf("Failed to download a release file from %s: %w", assetURL, err)
 	}
 
+	validator := responseValidator(res.Header)
+	if offset > 0 && res.StatusCode == http.StatusPartialContent {
+		return &rangedBody{res.Body, true, validator}, nil
+	}
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("Failed to download a release file from %s: Not successful status %d", assetURL, res.StatusCode)
+		res.Body.Close()
+		return nil, &downloadStatusError{assetURL, res.StatusCode}
 	}
 
-	return res.Body, nil
+	return &rangedBody{res.Body, offset == 0, validator}, nil
 }
 
-// UpdateTo downloads an executable from GitHub Releases API and replace current binary with the downloaded one.
-// It downloads a release asset via GitHub Releases API so this function is available for update releases on private repository.
-// If a redirect occurs, it fallbacks into directly downloading from the redirect URL.
-func (up *Updater) UpdateTo(ctx context.Context, rel *Release, cmdPath string) error {
-	var client http.Client
-	src, redirectURL, err := up.api.Repositories.DownloadReleaseAsset(ctx, rel.RepoOwner, rel.RepoName, rel.AssetID, &client)
+// isTransientDownloadError returns true when the download failed with an error which may not happen on retry:
+// network errors, 5xx responses and partial bodies.
+func isTransientDownloadError(err error) bool {
+	var ge *github.ErrorResponse
+	if errors.As(err, &ge) {
+		return ge.Response != nil && ge.Response.StatusCode >= 500
+	}
+	var se *downloadStatusError
+	if errors.As(err, &se) {
+		return se.status >= 500
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// downloadAsset downloads the asset by its ID from the release source once. 'kind' is a human readable kind of the
+// asset used in messages. When 'progress' is true, the progress callback is reported while reading the body.
+func (up *Updater) downloadAsset(ctx context.Context, rel *Release, id int64, kind string, progress bool) ([]byte, error) {
+	var src io.ReadCloser
+	var err error
+	if u, ok := up.mirrorURL(rel, id); ok {
+		src, err = up.downloadDirectlyFromURL(ctx, u)
+	} else {
+		src, err = up.releaseSource().DownloadAsset(ctx, rel.RepoOwner, rel.RepoName, id)
+	}
 	if err != nil {
-		return fmt.Errorf("Failed to call GitHub Releases API for getting an asset(ID: %d) for repository '%s/%s': %s", rel.AssetID, rel.RepoOwner, rel.RepoName, err)
+		return nil, err
 	}
-	if redirectURL != "" {
-		log.Println("Redirect URL was returned while trying to download a release asset from GitHub API. Falling back to downloading from asset URL directly:", redirectURL)
-		src, err = up.downloadDirectlyFromURL(ctx, redirectURL)
-		if err != nil {
-			return err
+	defer src.Close()
+
+	var body io.Reader = src
+	if progress && up.progress != nil {
+		body = &progressReader{r: src, total: int64(rel.AssetByteSize), progress: up.progress}
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading %s body: %w", kind, err)
+	}
+	return data, nil
+}
+
+// mirrorURL returns the URL of the asset rewritten by Config.AssetURLRewriter. Only the asset of the release is
+// downloaded from the mirror. The validation asset and the parts of a split asset are always downloaded from the
+// release source.
+func (up *Updater) mirrorURL(rel *Release, id int64) (string, bool) {
+	if up.assetURLRewriter == nil || id != rel.AssetID || rel.AssetURL == "" || len(rel.AssetPartIDs) > 0 {
+		return "", false
+	}
+	u := up.assetURLRewriter(rel.AssetURL)
+	if u == "" {
+		return "", false
+	}
+	up.debugf("Downloading asset %q from mirror %s", rel.AssetName, u)
+	return u, true
+}
+
+// partialDownloadDir returns the directory keeping partially downloaded assets. It is in the user cache directory (or
+// a per-user directory in the temporary directory) so that the download can be resumed even after the process exits.
+// It is only accessible by the current user so that another user can never plant a file there.
+func partialDownloadDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err == nil {
+		dir = filepath.Join(dir, "selfupdate", "partial")
+	} else {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("selfupdate-%d", os.Getuid()))
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("Failed to create directory for partial downloads %s: %s", dir, err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("Failed to stat directory for partial downloads %s: %s", dir, err)
+	}
+	if !info.IsDir() || !ownedByCurrentUser(info) {
+		return "", fmt.Errorf("Directory for partial downloads %s is not a directory owned by the current user", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", fmt.Errorf("Failed to restrict permissions of directory for partial downloads %s: %s", dir, err)
 		}
 	}
-	defer src.Close()
+	return dir, nil
+}
 
-	data, err := ioutil.ReadAll(src)
+// ownedByCurrentUser returns whether the file is owned by the current user. It is always true on platforms where the
+// owner of a file is not a user ID.
+func ownedByCurrentUser(info os.FileInfo) bool {
+	uid, _, ok := fileOwner(info)
+	return !ok || uid == os.Getuid()
+}
+
+// partialDownloadPath returns the path to the file keeping the partially downloaded asset for resuming the download.
+func partialDownloadPath(rel *Release, id int64) (string, error) {
+	dir, err := partialDownloadDir()
 	if err != nil {
-		return fmt.Errorf("Failed reading asset body: %v", err)
+		return "", err
 	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%s-%d.partial", rel.RepoOwner, rel.RepoName, id)), nil
+}
 
-	if up.validator == nil {
-		return uncompressAndUpdate(ctx, bytes.NewReader(data), rel.AssetURL, cmdPath)
+// openPartialFile opens the partial download file at the path. A symbolic link or a file which is not a regular file
+// owned by the current user is removed and never followed.
+func openPartialFile(path string) (*os.File, error) {
+	if info, err := os.Lstat(path); err == nil && (!info.Mode().IsRegular() || !ownedByCurrentUser(info)) {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("Failed to remove irregular partial download file %s: %s", path, err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|openNoFollow, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open partial download file %s: %s", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || !ownedByCurrentUser(info) {
+		f.Close()
+		return nil, fmt.Errorf("Partial download file %s is not a regular file owned by the current user", path)
+	}
+	return f, nil
+}
+
+// removePartialFile removes the partial download file at the path and the validator of its response.
+func removePartialFile(path string) {
+	os.Remove(path)
+	os.Remove(path + ".validator")
+}
+
+// downloadAssetResumable downloads the asset by its ID in the same way as downloadAsset, but the body is appended to
+// a '.partial' file. When the file already exists, the download is resumed from its size with a Range request. The
+// ETag or Last-Modified of the first response is sent as If-Range so that the server sends the whole asset instead
+// when it was changed. When the server does not support ranges or no validator was recorded, the download restarts
+// from the beginning. The file is removed after the whole body was read or on a non-transient failure, so a broken
+// download is never resumed twice.
+func (up *Updater) downloadAssetResumable(ctx context.Context, rel *Release, id int64, kind string, progress bool) ([]byte, error) {
+	gs, ok := up.releaseSource().(*gitHubSource)
+	mirror, mirrored := up.mirrorURL(rel, id)
+	if !ok && !mirrored {
+		return up.downloadAsset(ctx, rel, id, kind, progress)
+	}
+	download := func(offset int64, ifRange string) (*rangedBody, error) {
+		if mirrored {
+			return up.downloadRangeFromURL(ctx, mirror, offset, ifRange)
+		}
+		return gs.downloadAssetFrom(ctx, rel.RepoOwner, rel.RepoName, id, offset, ifRange)
+	}
+
+	path, err := partialDownloadPath(rel, id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openPartialFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// fail closes the partial file and removes it unless the failure is transient so that it is not resumed again
+	fail := func(err error) ([]byte, error) {
+		if ctx.Err() == nil && !isTransientDownloadError(err) {
+			f.Close()
+			removePartialFile(path)
+		}
+		return nil, err
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fail(fmt.Errorf("Failed to seek partial download file %s: %s", path, err))
+	}
+	ifRange := ""
+	if b, err := ioutil.ReadFile(path + ".validator"); err == nil {
+		ifRange = strings.TrimSpace(string(b))
+	}
+	if ifRange == "" || (rel.AssetByteSize > 0 && offset >= int64(rel.AssetByteSize)) {
+		// The download cannot be resumed safely, was already completed or the file is broken. Start over
+		offset = 0
 	}
 
-	validationSrc, validationRedirectURL, err := up.api.Repositories.DownloadReleaseAsset(ctx, rel.RepoOwner, rel.RepoName, rel.ValidationAssetID, &client)
+	body, err := download(offset, ifRange)
+	var serr *downloadStatusError
+	if offset > 0 && errors.As(err, &serr) && serr.status == http.StatusRequestedRangeNotSatisfiable {
+		up.infof("Download of %s cannot be resumed from %d bytes. Restarting from the beginning", kind, offset)
+		offset = 0
+		body, err = download(0, "")
+	}
+	if err != nil {
+		return fail(err)
+	}
+	defer body.Close()
+	if !body.ranged {
+		offset = 0
+	}
+	if offset > 0 {
+		up.infof("Resuming download of %s from %d bytes", kind, offset)
+	} else if body.validator != "" {
+		if err := ioutil.WriteFile(path+".validator", []byte(body.validator), 0600); err != nil {
+			return fail(fmt.Errorf("Failed to write validator of partial download file %s: %s", path, err))
+		}
+	} else {
+		os.Remove(path + ".validator")
+	}
+	if err := f.Truncate(offset); err != nil {
+		return fail(fmt.Errorf("Failed to truncate partial download file %s: %s", path, err))
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("Failed to seek partial download file %s: %s", path, err))
+	}
+
+	var src io.Reader = body
+	if progress && up.progress != nil {
+		src = &progressReader{r: body, downloaded: offset, total: int64(rel.AssetByteSize), progress: up.progress}
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		return fail(fmt.Errorf("Failed reading %s body: %w", kind, err))
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("Failed to seek partial download file %s: %s", path, err))
+	}
+	data, err := ioutil.ReadAll(f)
 	if err != nil {
-		return fmt.Errorf("Failed to call GitHub Releases API for getting an validation asset(ID: %d) for repository '%s/%s': %s", rel.ValidationAssetID, rel.RepoOwner, rel.RepoName, err)
+		return fail(fmt.Errorf("Failed to read partial download file %s: %s", path, err))
 	}
-	if validationRedirectURL != "" {
-		log.Println("Redirect URL was returned while trying to download a release validation asset from GitHub API. Falling back to downloading from asset URL directly:", redirectURL)
-		validationSrc, err = up.downloadDirectlyFromURL(ctx, validationRedirectURL)
+	f.Close()
+	removePartialFile(path)
+	return data, nil
+}
+
+// defaultDownloadRetryBackoff is the wait before the first retry when Config.DownloadRetryBackoff is not set.
+const defaultDownloadRetryBackoff = time.Second
+
+// downloadAssetWithRetry calls downloadAsset and retries transient failures up to Config.DownloadRetries times with
+// exponential backoff. When the context is done, the returned error wraps the context's error. When all retries
+// failed, *DownloadRetryError is returned.
+func (up *Updater) downloadAssetWithRetry(ctx context.Context, rel *Release, id int64, kind string, progress bool) ([]byte, error) {
+	backoff := up.downloadRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultDownloadRetryBackoff
+	}
+	download := up.downloadAsset
+	if up.resumableDownloads {
+		download = up.downloadAssetResumable
+	}
+	for attempt := 1; ; attempt++ {
+		data, err := download(ctx, rel, id, kind, progress)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("Downloading %s was cancelled at attempt %d (%s): %w", kind, attempt, err, ctx.Err())
+		}
+		if up.downloadRetries <= 0 || !isTransientDownloadError(err) {
+			return nil, err
+		}
+		if attempt > up.downloadRetries {
+			return nil, &DownloadRetryError{attempt, err}
+		}
+
+		up.infof("Downloading %s failed at attempt %d. Retrying after %s: %s", kind, attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("Downloading %s was cancelled while waiting for retry (%s): %w", kind, err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// downloadWholeAsset downloads the asset of the release. The parts of a split asset are downloaded in order with
+// retries and concatenated. The progress of a split asset is reported after each part.
+func (up *Updater) downloadWholeAsset(ctx context.Context, rel *Release) ([]byte, error) {
+	if len(rel.AssetPartIDs) == 0 {
+		return up.downloadAssetWithRetry(ctx, rel, rel.AssetID, "asset", true)
+	}
+	var whole []byte
+	for i, id := range rel.AssetPartIDs {
+		kind := fmt.Sprintf("part %d/%d of asset", i+1, len(rel.AssetPartIDs))
+		data, err := up.downloadAssetWithRetry(ctx, rel, id, kind, false)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		whole = append(whole, data...)
+		if up.progress != nil {
+			up.progress(int64(len(whole)), int64(rel.AssetByteSize))
+		}
+	}
+	up.debugf("Reassembled asset %q from %d parts", rel.AssetName, len(rel.AssetPartIDs))
+	return whole, nil
+}
+
+// validatorFor returns the validator to validate the asset of the release. It is the one chosen on detection. For
+// a release not detected by this updater, Config.Validator or the first of Config.Validators is used.
+func (up *Updater) validatorFor(rel *Release) Validator {
+	if rel.validator != nil {
+		return rel.validator
+	}
+	if vs := up.configuredValidators(); len(vs) > 0 {
+		return vs[0]
+	}
+	return nil
+}
+
+// releaseFormat returns the format of the asset of the release. It is Release.Format, which may be detected from the
+// content type, or the format decided by the asset name with Config.Decompressors when it is not set.
+func (up *Updater) releaseFormat(rel *Release) AssetFormat {
+	if rel.Format != "" {
+		return rel.Format
+	}
+	return up.nameFormat(releaseAssetName(rel))
+}
+
+// downloadAndValidate downloads the asset of the release via GitHub Releases API and validates it with the validator.
+// If a redirect occurs, it fallbacks into directly downloading from the redirect URL. Config.DownloadTimeout is
+// applied to the whole download including the validation asset.
+func (up *Updater) downloadAndValidate(ctx context.Context, rel *Release) ([]byte, error) {
+	if isPackageAsset(rel) {
+		err := fmt.Errorf("%w: %q cannot be installed by self-update. Please download it from %s and install it with your package manager such as dpkg or rpm", ErrPackageAsset, rel.AssetName, rel.AssetURL)
+		return nil, &UpdateError{StageDownload, err}
+	}
+	f := up.releaseFormat(rel)
+	if !up.formatAllowed(f) {
+		return nil, &UpdateError{StageDownload, fmt.Errorf("Format %q of asset %q is not allowed by AllowedFormats", f, releaseAssetName(rel))}
+	}
+	if up.downloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, up.downloadTimeout)
+		defer cancel()
+	}
+	data, err := up.downloadWholeAsset(ctx, rel)
+	if err != nil {
+		return nil, &UpdateError{StageDownload, err}
+	}
+	if rel.AssetByteSize > 0 && len(data) != rel.AssetByteSize {
+		err := fmt.Errorf("Downloaded %d bytes of asset %q but its size is %d bytes. The download may be truncated", len(data), rel.AssetName, rel.AssetByteSize)
+		return nil, &UpdateError{StageDownload, err}
 	}
 
-	defer validationSrc.Close()
+	validator := up.validatorFor(rel)
+	if validator == nil {
+		return data, nil
+	}
 
-	validationData, err := ioutil.ReadAll(validationSrc)
+	validationData, err := up.downloadAssetWithRetry(ctx, rel, rel.ValidationAssetID, "validation asset", false)
 	if err != nil {
-		return fmt.Errorf("Failed reading validation asset body: %v", err)
+		return nil, &UpdateError{StageValidation, err}
 	}
 
-	if err := up.validator.Validate(data, validationData); err != nil {
-		return fmt.Errorf("Failed validating asset content: %v", err)
+	if err := validateAsset(validator, rel.AssetName, data, validationData); err != nil {
+		return nil, &UpdateError{StageValidation, fmt.Errorf("Failed validating asset content: %w", err)}
 	}
 
-	return uncompressAndUpdate(ctx, bytes.NewReader(data), rel.AssetURL, cmdPath)
+	return data, nil
 }
 
-// UpdateCommand updates a given command binary to the latest version.
-// 'slug' represents 'owner/name' repository on GitHub and 'current' means the current version.
-func (up *Updater) UpdateCommand(ctx context.Context, cmdPath string, current semver.Version, slug string) (*Release, error) {
+// UpdateTo downloads an executable from GitHub Releases API and replace current binary with the downloaded one.
+// It downloads a release asset via GitHub Releases API so this function is available for update releases on private repository.
+// If a redirect occurs, it fallbacks into directly downloading from the redirect URL.
+// The new binary is written to a temporary file in the same directory as cmdPath and replaces the current binary only
+// after it was downloaded and validated completely. The previous binary is kept as '.<cmd>.old' so that the update can
+// be reverted with RollbackUpdate. The backup is put in Config.BackupDir when it is set, otherwise in the same
+// directory as cmdPath, and older backups are rotated up to Config.KeepBackups. Returned error is *UpdateError telling
+// at which stage it failed.
+// When the asset of rel is a system package detected with Config.AllowPackageAssets, it is not downloaded and
+// the returned error wraps ErrPackageAsset. When the binary is locked by another running process on Windows, the
+// returned error wraps ErrBinaryInUse.
+// rel can be any release returned from DetectVersions or DetectVersion, not only the latest one. Versions are not
+// compared with the current binary so this method is also available for installing an older version.
+func (up *Updater) UpdateTo(ctx context.Context, rel *Release, cmdPath string) error {
+	data, err := up.downloadAndValidate(ctx, rel)
+	if err != nil {
+		return err
+	}
+	return up.updateAndVerify(ctx, bytes.NewReader(data), rel, cmdPath)
+}
+
+// DryRunResult describes what an update would do. It is returned by DryRunUpdateTo and DryRunUpdateCommand.
+type DryRunResult struct {
+	// Release is the release which would be installed
+	Release *Release
+	// Current is the current version of the command. It is only set by DryRunUpdateCommand
+	Current semver.Version
+	// CmdPath is the resolved path to the binary which would be replaced
+	CmdPath string
+	// UpdateNeeded is true when the binary would be replaced
+	UpdateNeeded bool
+	// SHA256 is the hex-encoded SHA-256 hash of the binary which would be installed. It is empty when no
+	// update is needed
+	SHA256 string
+	// Size is the size in bytes of the binary which would be installed
+	Size int64
+}
+
+// downloadCommand downloads and validates the release asset and writes the executable named 'cmd' uncompressed from
+// the asset to w. It returns the number of written bytes.
+func (up *Updater) downloadCommand(ctx context.Context, rel *Release, cmd string, w io.Writer) (int64, error) {
+	data, err := up.downloadAndValidate(ctx, rel)
+	if err != nil {
+		return 0, err
+	}
+	bin, err := up.uncompressTargetCommand(bytes.NewReader(data), rel.AssetURL, cmd)
+	if err != nil {
+		return 0, &UpdateError{StageDownload, err}
+	}
+	n, err := io.Copy(w, bin)
+	if err != nil {
+		return n, &UpdateError{StageDownload, fmt.Errorf("Failed to write uncompressed binary from %s: %s", rel.AssetURL, err)}
+	}
+	return n, nil
+}
+
+// DownloadReleaseAsset downloads the release asset in the same way as UpdateTo (including private assets and
+// validation) and writes the uncompressed executable to w. No file is replaced. The executable in an archive is
+// looked up by Config.CommandName or the repository name of the release. Returned error is *UpdateError telling at which stage it failed.
+func (up *Updater) DownloadReleaseAsset(ctx context.Context, rel *Release, w io.Writer) error {
+	_, err := up.downloadCommand(ctx, rel, rel.RepoName, w)
+	return err
+}
+
+// DryRunUpdateTo downloads, validates and uncompresses the release asset as UpdateTo does, but it does not replace
+// the binary at cmdPath. It reports the hash and size of the binary which would be installed.
+func (up *Updater) DryRunUpdateTo(ctx context.Context, rel *Release, cmdPath string) (*DryRunResult, error) {
+	_, cmd := filepath.Split(cmdPath)
+	h := sha256.New()
+	size, err := up.downloadCommand(ctx, rel, cmd, h)
+	if err != nil {
+		return nil, err
+	}
+
+	up.infof("Dry run: would update %s to version %s", cmdPath, rel.Version)
+	return &DryRunResult{
+		Release:      rel,
+		CmdPath:      cmdPath,
+		UpdateNeeded: true,
+		SHA256:       fmt.Sprintf("%x", h.Sum(nil)),
+		Size:         size,
+	}, nil
+}
+
+// VerifyInstalled verifies the binary currently installed at cmdPath against the release without updating it.
+// Config.Validator or Config.Validators must be set. When the asset of the release is an uncompressed binary, the installed binary is
+// validated with the validation asset directly. Otherwise the asset is downloaded and validated as UpdateTo does and
+// the executable in it is compared with the installed binary. It returns nil when the installed binary matches the
+// release and *IntegrityError when it does not. Other failures are returned as *UpdateError.
+func (up *Updater) VerifyInstalled(ctx context.Context, cmdPath string, rel *Release) error {
+	validator := up.validatorFor(rel)
+	if validator == nil {
+		return fmt.Errorf("Config.Validator or Config.Validators must be set to verify installed binary %s", cmdPath)
+	}
+	p, err := resolveCmdPath(cmdPath, false)
+	if err != nil {
+		return err
+	}
+	installed, err := ioutil.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("Failed to read installed binary %s: %s", p, err)
+	}
+
+	// The asset is uncompressed with a decompressor matching its URL even if its name looks like a raw binary
+	_, _, decompressed := up.decompressorFor(rel.AssetURL)
+	if up.releaseFormat(rel) == FormatRaw && !decompressed {
+		validationData, err := up.downloadAssetWithRetry(ctx, rel, rel.ValidationAssetID, "validation asset", false)
+		if err != nil {
+			return &UpdateError{StageDownload, err}
+		}
+		err = validateAsset(validator, rel.AssetName, installed, validationData)
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			return &IntegrityError{p, rel, err}
+		}
+		if err != nil {
+			return &UpdateError{StageValidation, err}
+		}
+		up.infof("Installed binary %s matches release %s", p, rel.Version)
+		return nil
+	}
+
+	// The executable in the asset is named after cmdPath even if it is a link to a versioned binary such as
+	// 'tool-1.2.3'
+	cmd := filepath.Base(cmdPath)
+	h := sha256.New()
+	if _, err := up.downloadCommand(ctx, rel, cmd, h); err != nil {
+		return err
+	}
+	expected, actual := fmt.Sprintf("%x", h.Sum(nil)), sha256Hex(installed)
+	if expected != actual {
+		err := fmt.Errorf("SHA256 of the installed binary is %s but the executable in %s is %s", actual, rel.AssetName, expected)
+		return &IntegrityError{p, rel, err}
+	}
+	up.infof("Installed binary %s matches release %s", p, rel.Version)
+	return nil
+}
+
+// resolveCmdPath resolves the path to the command binary. It adds '.exe' on Windows and resolves symbolic links
+// unless 'keepSymlink' is true.
+func resolveCmdPath(cmdPath string, keepSymlink bool) (string, error) {
 	if runtime.GOOS == "windows" && !strings.HasSuffix(cmdPath, ".exe") {
 		// Ensure to add '.exe' to given path on Windows
 		cmdPath = cmdPath + ".exe"
@@ -115,45 +1075,255 @@ func (up *Updater) UpdateCommand(ctx context.Context, cmdPath string, current se
 
 	stat, err := os.Lstat(cmdPath)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to stat '%s'. File may not exist: %s", cmdPath, err)
+		return "", fmt.Errorf("Failed to stat '%s'. File may not exist: %s", cmdPath, err)
 	}
-	if stat.Mode()&os.ModeSymlink != 0 {
+	if stat.Mode()&os.ModeSymlink != 0 && !keepSymlink {
 		p, err := filepath.EvalSymlinks(cmdPath)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to resolve symlink '%s' for executable: %s", cmdPath, err)
+			return "", fmt.Errorf("Failed to resolve symlink '%s' for executable: %s", cmdPath, err)
 		}
 		cmdPath = p
 	}
+	return cmdPath, nil
+}
 
+// detectUpdate detects the latest release of the slug and returns whether the current version needs to be updated.
+// When no release is detected, a release with the current version is returned.
+func (up *Updater) detectUpdate(ctx context.Context, current semver.Version, slug string) (*Release, bool, error) {
 	rel, ok, err := up.DetectLatest(ctx, slug)
+	if errors.Is(err, ErrNoMatchingAsset) || errors.Is(err, ErrNoReleaseInRange) {
+		up.infof("%s", err)
+		ok, err = false, nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if !ok {
-		log.Println("No release detected. Current version is considered up-to-date")
-		return &Release{Version: current}, nil
+		up.infof("No release detected. Current version is considered up-to-date")
+		return &Release{Version: current}, false, nil
 	}
 	if current.Equals(rel.Version) {
-		log.Println("Current version", current, "is the latest. Update is not needed")
-		return rel, nil
+		up.infof("Current version %s is the latest. Update is not needed", current)
+		return rel, false, nil
+	}
+	if err := up.checkDowngrade(current, rel.Version); err != nil {
+		return nil, false, err
+	}
+	return rel, true, nil
+}
+
+// checkDowngrade returns *DowngradeError when the version is older than the current version and
+// Config.AllowDowngrade is not set.
+func (up *Updater) checkDowngrade(current, v semver.Version) error {
+	if !v.LT(current) {
+		return nil
+	}
+	if up.allowDowngrade {
+		up.infof("Latest version %s is older than current version %s. Will downgrade", v, current)
+		return nil
 	}
-	log.Println("Will update", cmdPath, "to the latest version", rel.Version)
+	return &DowngradeError{Current: current, Version: v}
+}
+
+// UpdateCommand updates a given command binary to the latest version.
+// 'slug' represents 'owner/name' repository on GitHub and 'current' means the current version.
+func (up *Updater) UpdateCommand(ctx context.Context, cmdPath string, current semver.Version, slug string) (*Release, error) {
+	cmdPath, err := resolveCmdPath(cmdPath, up.symlinkStrategy == SymlinkVersioned)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, needed, err := up.detectUpdate(ctx, current, slug)
+	if err != nil || !needed {
+		return rel, err
+	}
+	up.infof("Will update %s to the latest version %s", cmdPath, rel.Version)
 	if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
 		return nil, err
 	}
 	return rel, nil
 }
 
+// UpdateCommands updates the commands released together in one release of the repository to the latest version so
+// that their versions never drift. 'paths' maps each command name to the path to its binary. The asset of each
+// command is looked up by the command name such as 'foo_linux_amd64.zip' and 'foo-helper_linux_amd64.zip', and the
+// latest release which has assets for all the commands is detected. All assets are downloaded, validated and
+// uncompressed before any binary is replaced. When replacing one of the binaries failed, the binaries already
+// replaced are rolled back. Releases are returned keyed by command names.
+func (up *Updater) UpdateCommands(ctx context.Context, paths map[string]string, current semver.Version, slug string) (map[string]*Release, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("No command to update in repository %s", slug)
+	}
+	cmds := make([]string, 0, len(paths))
+	resolved := make(map[string]string, len(paths))
+	for cmd, p := range paths {
+		p, err := resolveCmdPath(p, up.symlinkStrategy == SymlinkVersioned)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+		resolved[cmd] = p
+	}
+	sort.Strings(cmds)
+
+	rels, err := up.detectCommands(ctx, slug, cmds)
+	if err != nil {
+		return nil, err
+	}
+	if rels == nil {
+		up.infof("No release detected for commands %v. Current version is considered up-to-date", cmds)
+		rels = make(map[string]*Release, len(cmds))
+		for _, cmd := range cmds {
+			rels[cmd] = &Release{Version: current}
+		}
+		return rels, nil
+	}
+	if v := rels[cmds[0]].Version; current.Equals(v) {
+		up.infof("Current version %s is the latest. Update is not needed", current)
+		return rels, nil
+	} else if err := up.checkDowngrade(current, v); err != nil {
+		return nil, err
+	}
+
+	// Download and check all assets at first so that a broken asset never leaves commands in different versions
+	assets := make(map[string][]byte, len(cmds))
+	for _, cmd := range cmds {
+		rel := rels[cmd]
+		data, err := up.downloadAndValidate(ctx, rel)
+		if err != nil {
+			return nil, err
+		}
+		_, name := filepath.Split(resolved[cmd])
+		bin, err := up.uncompressCommand(bytes.NewReader(data), rel.AssetURL, name)
+		if err == nil {
+			_, err = io.Copy(ioutil.Discard, bin)
+		}
+		if err != nil {
+			return nil, &UpdateError{StageDownload, err}
+		}
+		assets[cmd] = data
+	}
+
+	updated := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		up.infof("Will update %s to the latest version %s", resolved[cmd], rels[cmd].Version)
+		if err := up.replaceAndVerify(ctx, bytes.NewReader(assets[cmd]), rels[cmd], resolved[cmd], up.uncompressCommand, up.binaryVerifier()); err != nil {
+			return nil, up.rollbackCommands(updated, err)
+		}
+		updated = append(updated, resolved[cmd])
+	}
+	return rels, nil
+}
+
+// rollbackCommands rolls back the binaries already updated by UpdateCommands when updating another binary failed
+// with err. Failures of the rollbacks are added to the returned error.
+func (up *Updater) rollbackCommands(updated []string, err error) error {
+	stage := StageReplacement
+	var uerr *UpdateError
+	if errors.As(err, &uerr) {
+		stage, err = uerr.Stage, uerr.Err
+	}
+	for _, p := range updated {
+		if rerr := up.RollbackUpdate(p); rerr != nil {
+			err = fmt.Errorf("%s. Additionally failed to roll back %s: %s", err, p, rerr)
+		}
+	}
+	return &UpdateError{stage, err}
+}
+
+// DryRunUpdateCommand reports what UpdateCommand would do without replacing the binary. It detects the latest release
+// and downloads and validates its asset when the update is needed.
+func (up *Updater) DryRunUpdateCommand(ctx context.Context, cmdPath string, current semver.Version, slug string) (*DryRunResult, error) {
+	cmdPath, err := resolveCmdPath(cmdPath, up.symlinkStrategy == SymlinkVersioned)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, needed, err := up.detectUpdate(ctx, current, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !needed {
+		return &DryRunResult{Release: rel, Current: current, CmdPath: cmdPath}, nil
+	}
+	res, err := up.DryRunUpdateTo(ctx, rel, cmdPath)
+	if err != nil {
+		return nil, err
+	}
+	res.Current = current
+	return res, nil
+}
+
 // UpdateSelf updates the running executable itself to the latest version.
 // 'slug' represents 'owner/name' repository on GitHub and 'current' means the current version.
+// The path to the executable is located with os.Executable and symbolic links are resolved. With SymlinkVersioned,
+// the symbolic link via which the executable was invoked (os.Args[0]) is updated instead. When the executable
+// cannot be replaced due to permissions, the returned error wraps os.ErrPermission so that callers can suggest
+// running the command with a privilege (e.g. sudo).
 func (up *Updater) UpdateSelf(ctx context.Context, current semver.Version, slug string) (*Release, error) {
-	cmdPath, err := os.Executable()
+	cmdPath, err := executablePath()
 	if err != nil {
 		return nil, err
 	}
+	if err := checkWritable(cmdPath); err != nil {
+		return nil, err
+	}
+	if up.symlinkStrategy == SymlinkVersioned {
+		if link, ok := invokedSymlink(cmdPath); ok {
+			if err := checkWritable(link); err != nil {
+				return nil, err
+			}
+			cmdPath = link
+		}
+	}
 	return up.UpdateCommand(ctx, cmdPath, current, slug)
 }
 
+// invokedSymlink returns the symbolic link via which the running executable at exe was invoked such as
+// '/usr/local/bin/foo' pointing to '/opt/foo/foo-1.2.3'. It returns false when it was not invoked via a symbolic link.
+func invokedSymlink(exe string) (string, bool) {
+	if len(os.Args) == 0 {
+		return "", false
+	}
+	p, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return "", false
+	}
+	if p, err = filepath.Abs(p); err != nil || !isSymlink(p) {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err != nil || resolved != exe {
+		return "", false
+	}
+	return p, true
+}
+
+// executablePath returns the path to the running executable with all symbolic links resolved.
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("Failed to locate the running executable: %s", err)
+	}
+	p, err := filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve symlink '%s' for executable: %s", exe, err)
+	}
+	return p, nil
+}
+
+// checkWritable checks that the binary at cmdPath can be replaced. Since the new binary is put next to the current
+// one and renamed, the directory containing it must be writable.
+func checkWritable(cmdPath string) error {
+	dir := filepath.Dir(cmdPath)
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(cmdPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("Executable %s cannot be updated since directory %s is not writable: %w", cmdPath, dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
 // UpdateTo downloads an executable from assetURL and replace the current binary with the downloaded one.
 // This function is low-level API to update the binary. Because it does not use GitHub API and downloads asset directly from the URL via HTTP,
 // this function is not available to update a release for private repositories.
@@ -162,10 +1332,11 @@ func UpdateTo(ctx context.Context, assetURL, cmdPath string) error {
 	up := DefaultUpdater(ctx)
 	src, err := up.downloadDirectlyFromURL(ctx, assetURL)
 	if err != nil {
-		return err
+		return &UpdateError{StageDownload, err}
 	}
 	defer src.Close()
-	return uncompressAndUpdate(ctx, src, assetURL, cmdPath)
+	up.infof("Will update %s to the latest downloaded from %s", cmdPath, assetURL)
+	return uncompressAndUpdate(ctx, src, assetURL, cmdPath, backupPath(cmdPath), UncompressCommand, nil, true)
 }
 
 // UpdateCommand updates a given command binary to the latest version.
@@ -174,6 +1345,12 @@ func UpdateCommand(ctx context.Context, cmdPath string, current semver.Version,
 	return DefaultUpdater(ctx).UpdateCommand(ctx, cmdPath, current, slug)
 }
 
+// UpdateCommands updates the commands released together in one release to the latest version.
+// This function is a shortcut version of updater.UpdateCommands.
+func UpdateCommands(ctx context.Context, paths map[string]string, current semver.Version, slug string) (map[string]*Release, error) {
+	return DefaultUpdater(ctx).UpdateCommands(ctx, paths, current, slug)
+}
+
 // UpdateSelf updates the running executable itself to the latest version.
 // This function is a shortcut version of updater.UpdateSelf.
 func UpdateSelf(ctx context.Context, current semver.Version, slug string) (*Release, error) {
@@ -1,13 +1,28 @@
 package selfupdate
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/blang/semver"
 )
@@ -391,3 +406,2380 @@ func TestUpdateFromGitHubPrivateRepo(t *testing.T) {
 		t.Error("Output from test binary after update is unexpected:", out)
 	}
 }
+
+func TestProgressReader(t *testing.T) {
+	content := strings.Repeat("x", 100)
+	reported := []int64{}
+	r := &progressReader{
+		r:     iotest.OneByteReader(strings.NewReader(content)),
+		total: int64(len(content)),
+		progress: func(downloaded, total int64) {
+			if total != 100 {
+				t.Error("Total size should be 100 but got", total)
+			}
+			reported = append(reported, downloaded)
+		},
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Error("Content was changed by progress reader:", string(b))
+	}
+	if len(reported) != 100 {
+		t.Fatal("Progress should be reported on each read but got", len(reported))
+	}
+	for i, d := range reported {
+		if d != int64(i+1) {
+			t.Error("Unexpected downloaded bytes at", i, ":", d)
+		}
+	}
+}
+
+func TestUncompressAndUpdateViaTemporaryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := uncompressAndUpdate(context.Background(), f, "https://example.com/bar.zip", cmdPath, "", UncompressCommand, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "this is test\n" {
+		t.Error("Binary was not updated:", string(b))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Error("Temporary files should be removed but got", len(files), "files")
+	}
+}
+
+func TestUncompressAndUpdateCancelled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = uncompressAndUpdate(ctx, f, "https://example.com/bar.zip", cmdPath, "", UncompressCommand, nil, true)
+	if err == nil {
+		t.Fatal("Cancelled context should cause an error")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Error should wrap context.Canceled:", err)
+	}
+
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Error("Binary should not be replaced after cancellation:", string(b))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Error("Temporary files should be removed but got", len(files), "files")
+	}
+}
+
+func TestUncompressAndUpdateBrokenAsset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open("testdata/invalid-tar.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	err = uncompressAndUpdate(context.Background(), f, "https://example.com/bar.tar.gz", cmdPath, "", UncompressCommand, nil, true)
+	if err == nil {
+		t.Fatal("Broken asset should cause an error")
+	}
+	uerr, ok := err.(*UpdateError)
+	if !ok {
+		t.Fatalf("UpdateError should be returned but got %T", err)
+	}
+	if uerr.Stage != StageDownload {
+		t.Error("Unexpected stage:", uerr.Stage)
+	}
+
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Error("Binary should not be modified on failure:", string(b))
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Error("Temporary files should be removed but got", len(files), "files")
+	}
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	for _, tc := range []struct {
+		out  string
+		want string
+		ok   bool
+	}{
+		{"v1.2.3\n", "1.2.3", true},
+		{"foo version 1.2.3 (darwin/amd64)\n", "1.2.3", true},
+		{"foo v1.2.3-rc.1\n", "1.2.3-rc.1", true},
+		{"release-1.2.3,", "1.2.3", true},
+		{"unknown\n", "", false},
+	} {
+		v, ok := parseVersionOutput(tc.out)
+		if ok != tc.ok {
+			t.Errorf("Unexpected result for %q: %v", tc.out, ok)
+			continue
+		}
+		if ok && v.String() != tc.want {
+			t.Errorf("Wanted %s for %q but got %s", tc.want, tc.out, v)
+		}
+	}
+}
+
+func zipScript(t *testing.T, name, script string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	h.SetMode(0755)
+	w, err := z.CreateHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(script)); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUpdateAndVerifyVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because shell script is used as an executable")
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	old := "#!/bin/sh\necho 'bar version 1.2.2'\n"
+	asset := zipScript(t, "bar", "#!/bin/sh\necho 'bar version 1.2.3'\n")
+	up := &Updater{versionCommand: []string{"--version"}}
+
+	for _, tc := range []struct {
+		version string
+		success bool
+	}{
+		{"1.2.4", false},
+		{"1.2.3", true},
+	} {
+		if err := ioutil.WriteFile(cmdPath, []byte(old), 0755); err != nil {
+			t.Fatal(err)
+		}
+		rel := &Release{Version: semver.MustParse(tc.version), AssetURL: "https://example.com/bar.zip"}
+		err := up.updateAndVerify(context.Background(), bytes.NewReader(asset), rel, cmdPath)
+
+		b, rerr := ioutil.ReadFile(cmdPath)
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		if tc.success {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) == old {
+				t.Error("Binary should be updated")
+			}
+		} else {
+			if err == nil {
+				t.Fatal("Version mismatch should cause an error")
+			}
+			if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageVerification {
+				t.Error("Unexpected error:", err)
+			}
+			if string(b) != old {
+				t.Error("Binary should be rolled back to previous one:", string(b))
+			}
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// On success, only the backup for rollback remains in addition to the binary
+		want := 1
+		if tc.success {
+			want = 2
+		}
+		if len(files) != want {
+			t.Error("Wanted", want, "files after removing temporary files but got", len(files), "files")
+		}
+	}
+}
+
+func TestRollbackUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	up := &Updater{}
+
+	if up.CanRollback(cmdPath) {
+		t.Fatal("Rollback should not be available before update")
+	}
+	err = up.RollbackUpdate(cmdPath)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("Missing backup should be reported:", err)
+	}
+
+	rel := &Release{Version: semver.MustParse("1.2.3"), AssetURL: "https://example.com/bar.zip"}
+	if err := up.updateAndVerify(context.Background(), bytes.NewReader(zipScript(t, "bar", "new")), rel, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	if !up.CanRollback(cmdPath) {
+		t.Fatal("Rollback should be available after update")
+	}
+	if err := up.RollbackUpdate(cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Error("Binary should be rolled back to previous one:", string(b))
+	}
+	if up.CanRollback(cmdPath) {
+		t.Error("Backup should be consumed by rollback")
+	}
+}
+
+func TestDryRunUpdateTo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(asset)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &Release{
+		Version:   semver.MustParse("1.2.3"),
+		AssetURL:  "https://example.com/bar.zip",
+		AssetID:   1,
+		RepoOwner: "foo",
+		RepoName:  "bar",
+	}
+	res, err := up.DryRunUpdateTo(ctx, rel, cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.UpdateNeeded || res.Release != rel || res.CmdPath != cmdPath {
+		t.Error("Unexpected dry run result:", res)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256([]byte("this is test\n")))
+	if res.SHA256 != want {
+		t.Error("Wanted SHA256", want, "but got", res.SHA256)
+	}
+	if res.Size != 13 {
+		t.Error("Unexpected size:", res.Size)
+	}
+
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Error("Binary should not be modified by dry run:", string(b))
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	asset := []byte("this is asset")
+	for _, tc := range []struct {
+		what     string
+		retries  int
+		failures int
+		status   int
+		partial  bool
+		attempts int
+		ok       bool
+		exhaust  bool
+	}{
+		{"no retry by default", 0, 1, 500, false, 1, false, false},
+		{"succeed after retries", 2, 2, 503, false, 3, true, false},
+		{"retry partial body", 1, 1, 0, true, 2, true, false},
+		{"exhaust retries", 1, 3, 502, false, 2, false, true},
+		{"no retry on 404", 2, 1, 404, false, 1, false, false},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			attempts := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+					http.NotFound(w, r)
+					return
+				}
+				attempts++
+				if attempts <= tc.failures {
+					if tc.partial {
+						w.Header().Set("Content-Length", "100")
+						w.Write(asset[:4])
+						return
+					}
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.Write(asset)
+			}))
+			defer ts.Close()
+
+			up, err := NewUpdater(ctx, Config{
+				APIToken:             "hogehoge",
+				EnterpriseBaseURL:    ts.URL,
+				DownloadRetries:      tc.retries,
+				DownloadRetryBackoff: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rel := &Release{AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+			data, err := up.downloadAndValidate(ctx, rel)
+			if attempts != tc.attempts {
+				t.Errorf("Wanted %d attempts but got %d", tc.attempts, attempts)
+			}
+			if tc.ok {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != string(asset) {
+					t.Fatal("Unexpected asset content:", string(data))
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Error should be returned")
+			}
+			var re *DownloadRetryError
+			if errors.As(err, &re) != tc.exhaust {
+				t.Fatalf("DownloadRetryError was expected to be %v but error is %v", tc.exhaust, err)
+			}
+			if tc.exhaust && re.Attempts != tc.attempts {
+				t.Errorf("Wanted %d attempts in error but got %d", tc.attempts, re.Attempts)
+			}
+		})
+	}
+}
+
+func TestDownloadRetriesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(500)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:             "hogehoge",
+		EnterpriseBaseURL:    ts.URL,
+		DownloadRetries:      3,
+		DownloadRetryBackoff: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &Release{AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+	_, err = up.downloadAndValidate(ctx, rel)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("Error should tell the context was cancelled:", err)
+	}
+	var re *DownloadRetryError
+	if errors.As(err, &re) {
+		t.Fatal("Cancellation should not be reported as exhausted retries:", err)
+	}
+}
+
+func TestDownloadPrivateAsset(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	asset := []byte("private asset")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/private/releases/assets/42":
+			// Private assets are only available via the API with the token and the octet-stream media type
+			if r.Header.Get("Authorization") != "Bearer hogehoge" || r.Header.Get("Accept") != "application/octet-stream" {
+				http.NotFound(w, r)
+				return
+			}
+			http.Redirect(w, r, "/signed/asset?signature=xxx", http.StatusFound)
+		case "/signed/asset":
+			// Signed storage URLs reject requests with other credentials
+			if r.Header.Get("Authorization") != "" {
+				http.Error(w, "unexpected credentials", http.StatusBadRequest)
+				return
+			}
+			w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &Release{
+		AssetURL:  "https://github.com/foo/private/releases/download/v1.2.3/private_linux_amd64",
+		AssetID:   42,
+		RepoOwner: "foo",
+		RepoName:  "private",
+	}
+	data, err := up.downloadAndValidate(ctx, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(asset) {
+		t.Fatal("Unexpected asset content:", string(data))
+	}
+}
+
+func TestDownloadSizeMismatch(t *testing.T) {
+	ctx := context.Background()
+	asset := []byte("this is asset")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(asset)
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := &Release{AssetID: 1, AssetName: "foo.zip", AssetByteSize: len(asset), RepoOwner: "foo", RepoName: "bar"}
+	if _, err := up.downloadAndValidate(ctx, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	rel.AssetByteSize = 100
+	_, err = up.downloadAndValidate(ctx, rel)
+	if err == nil {
+		t.Fatal("Error should be returned for truncated download")
+	}
+	var uerr *UpdateError
+	if !errors.As(err, &uerr) || uerr.Stage != StageDownload {
+		t.Fatal("Error at download stage should be returned:", err)
+	}
+	if !strings.Contains(err.Error(), "truncated") {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestUpdateToOlderVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because shell script is used as an executable")
+	}
+	ctx := context.Background()
+
+	assets := map[string][]byte{
+		"/api/v3/repos/foo/bar/releases/assets/1": zipScript(t, "bar", "#!/bin/sh\necho 'bar version 1.0.0'\n"),
+		"/api/v3/repos/foo/bar/releases/assets/2": zipScript(t, "bar", "#!/bin/sh\necho 'bar version 2.0.0'\n"),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/repos/foo/bar/releases" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v2.0.0", "assets": [{"id": 2, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v2.0.0/bar_linux_amd64.zip"}]},
+				{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"}]}
+			]`)
+			return
+		}
+		if b, ok := assets[r.URL.Path]; ok {
+			w.Write(b)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("#!/bin/sh\necho 'bar version 2.0.0'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		VersionCommand:    []string{"--version"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rels, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var older *Release
+	for _, r := range rels {
+		if r.Version.Equals(semver.MustParse("1.0.0")) {
+			older = r
+		}
+	}
+	if older == nil {
+		t.Fatal("Older release was not detected:", rels)
+	}
+
+	if err := up.UpdateTo(ctx, older, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "1.0.0") {
+		t.Error("Binary should be downgraded to 1.0.0:", string(b))
+	}
+}
+
+func TestUpdatePreservingPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because permission bits are not available on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	asset := zipScript(t, "bar", "#!/bin/sh\necho 'bar version 1.2.3'\n")
+	rel := &Release{Version: semver.MustParse("1.2.3"), AssetURL: "https://example.com/bar.zip"}
+	want := os.FileMode(0710) | os.ModeSetuid
+
+	for _, preserve := range []bool{true, false} {
+		if err := ioutil.WriteFile(cmdPath, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(cmdPath, want); err != nil {
+			t.Fatal(err)
+		}
+		up := &Updater{preservePermissions: preserve}
+		if err := up.updateAndVerify(context.Background(), bytes.NewReader(asset), rel, cmdPath); err != nil {
+			t.Fatal(err)
+		}
+		s, err := os.Stat(cmdPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mode := s.Mode() & (os.ModePerm | os.ModeSetuid)
+		if preserve && mode != want {
+			t.Errorf("Mode %s should be preserved but got %s", want, mode)
+		}
+		if !preserve && mode == want {
+			t.Errorf("Mode should not be preserved without the option: %s", mode)
+		}
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+
+	if err := checkWritable(cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatal("Temporary file should be removed:", files[0].Name())
+	}
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("because permission of directory cannot deny writing")
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+	err = checkWritable(cmdPath)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatal("Error should wrap os.ErrPermission:", err)
+	}
+}
+
+func TestUpdateRejectingInvalidBinaryFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	asset := zipScript(t, "bar", "#!/bin/sh\necho 'bar version 1.2.3'\n")
+	rel := &Release{Version: semver.MustParse("1.2.3"), AssetURL: "https://example.com/bar.zip"}
+
+	up := &Updater{verifyBinaryFormat: true}
+	err = up.updateAndVerify(context.Background(), bytes.NewReader(asset), rel, cmdPath)
+	var uerr *UpdateError
+	if !errors.As(err, &uerr) || uerr.Stage != StageValidation {
+		t.Fatal("Validation error should be returned for the shell script:", err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Error("Binary should not be replaced:", string(b))
+	}
+}
+
+func TestUpdateSymlinkedCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because creating symbolic links requires a privilege on Windows")
+	}
+
+	// The archive has several executables so that the executable is looked up by its name
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"bar": "#!/bin/sh\necho 'bar version 1.3.0'\n", "bar-helper": "#!/bin/sh\n"} {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		h.SetMode(0755)
+		w, err := z.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	asset := buf.Bytes()
+	rel := &Release{Version: semver.MustParse("1.3.0"), AssetURL: "https://example.com/bar.zip"}
+
+	for _, strategy := range []SymlinkStrategy{"", SymlinkReplaceTarget, SymlinkVersioned} {
+		t.Run(string(strategy), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "selfupdate-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			oldBin := filepath.Join(dir, "bar-1.2.0")
+			if err := ioutil.WriteFile(oldBin, []byte("#!/bin/sh\necho 'bar version 1.2.0'\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			cmdPath := filepath.Join(dir, "bar")
+			if err := os.Symlink("bar-1.2.0", cmdPath); err != nil {
+				t.Fatal(err)
+			}
+
+			up := &Updater{symlinkStrategy: strategy, versionCommand: []string{"--version"}}
+			if err := up.updateAndVerify(context.Background(), bytes.NewReader(asset), rel, cmdPath); err != nil {
+				t.Fatal(err)
+			}
+
+			link, err := os.Readlink(cmdPath)
+			if err != nil {
+				t.Fatal("Command should be still a symlink:", err)
+			}
+			b, err := ioutil.ReadFile(oldBin)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strategy != SymlinkVersioned {
+				if link != "bar-1.2.0" {
+					t.Error("Symlink should not be changed:", link)
+				}
+				if !strings.Contains(string(b), "1.3.0") {
+					t.Error("Target of symlink should be replaced:", string(b))
+				}
+				return
+			}
+
+			if link != "bar-1.3.0" {
+				t.Error("Symlink should point to versioned binary:", link)
+			}
+			if !strings.Contains(string(b), "1.2.0") {
+				t.Error("Previous versioned binary should be left:", string(b))
+			}
+			if !up.CanRollback(cmdPath) {
+				t.Fatal("Update should be able to be rolled back")
+			}
+			if err := up.RollbackUpdate(cmdPath); err != nil {
+				t.Fatal(err)
+			}
+			if link, err := os.Readlink(cmdPath); err != nil || link != "bar-1.2.0" {
+				t.Error("Symlink should point to previous binary after rollback:", link, err)
+			}
+		})
+	}
+}
+
+func TestInvokedSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because creating symbolic links requires a privilege on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "bar-1.2.0")
+	if err := ioutil.WriteFile(exe, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "bar")
+	if err := os.Symlink("bar-1.2.0", link); err != nil {
+		t.Fatal(err)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{link}
+	if p, ok := invokedSymlink(exe); !ok || p != link {
+		t.Fatal("Symlink invoking the executable should be found:", p, ok)
+	}
+	os.Args = []string{exe}
+	if p, ok := invokedSymlink(exe); ok {
+		t.Fatal("Executable invoked directly should not have symlink:", p)
+	}
+	os.Args = []string{link}
+	if p, ok := invokedSymlink(filepath.Join(dir, "other")); ok {
+		t.Fatal("Symlink to another executable should not be found:", p)
+	}
+}
+
+func TestDownloadReleaseAsset(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"}]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release was not detected")
+	}
+
+	var buf bytes.Buffer
+	if err := up.DownloadReleaseAsset(ctx, rel, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != script {
+		t.Fatalf("Downloaded binary is unexpected: %q", buf.String())
+	}
+
+	rel.AssetID = 2
+	err = up.DownloadReleaseAsset(ctx, rel, &buf)
+	if err == nil {
+		t.Fatal("Error should occur for missing asset")
+	}
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageDownload {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [{"id": 1, "name": "bar_linux_amd64.zip"}]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(3 * time.Second):
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", DownloadTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release was not detected")
+	}
+
+	start := time.Now()
+	err = up.DownloadReleaseAsset(ctx, rel, ioutil.Discard)
+	if err == nil {
+		t.Fatal("Error should occur when downloading exceeds DownloadTimeout")
+	}
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageDownload {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatal("Download was not cancelled by DownloadTimeout:", d)
+	}
+}
+
+func TestVerifyInstalled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because .exe is added to the command path")
+	}
+	ctx := context.Background()
+	bin := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	archive := zipScript(t, "bar", bin)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v1.0.0", "assets": [
+					{"id": 1, "name": "bar_linux_amd64"},
+					{"id": 2, "name": "bar_linux_amd64.sha256"}
+				]},
+				{"tag_name": "v0.9.0", "assets": [
+					{"id": 3, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v0.9.0/bar_linux_amd64.zip"},
+					{"id": 4, "name": "bar_linux_amd64.zip.sha256"}
+				]}
+			]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			fmt.Fprint(w, bin)
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			fmt.Fprintf(w, "%x  bar_linux_amd64\n", sha256.Sum256([]byte(bin)))
+		case "/api/v3/repos/foo/bar/releases/assets/3":
+			w.Write(archive)
+		case "/api/v3/repos/foo/bar/releases/assets/4":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.zip\n", sha256.Sum256(archive))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Validator: &SHA2Validator{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 2 {
+		t.Fatal("Both releases should be detected but got", rels)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-verify-installed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+
+	for _, rel := range rels {
+		t.Run(rel.AssetName, func(t *testing.T) {
+			if err := ioutil.WriteFile(cmdPath, []byte(bin), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := up.VerifyInstalled(ctx, cmdPath, rel); err != nil {
+				t.Fatal("Installed binary should match the release:", err)
+			}
+
+			if err := ioutil.WriteFile(cmdPath, []byte("tampered"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			err := up.VerifyInstalled(ctx, cmdPath, rel)
+			if err == nil {
+				t.Fatal("Tampered binary should not match the release")
+			}
+			var ierr *IntegrityError
+			if !errors.As(err, &ierr) || ierr.CmdPath != cmdPath {
+				t.Fatalf("Unexpected error: %#v", err)
+			}
+		})
+	}
+
+	up, err = NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.VerifyInstalled(ctx, cmdPath, rels[0]); err == nil {
+		t.Fatal("Error should occur without validator")
+	}
+}
+
+func TestVerifyInstalledVersionedSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because creating symbolic links requires a privilege on Windows")
+	}
+	ctx := context.Background()
+	bin := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	// The archive has several executables so that the executable is looked up by its name
+	archive := makeTestZip(t, []testArchiveFile{{"bar", 0755, bin}, {"bar-helper", 0755, "#!/bin/sh\n"}})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"},
+				{"id": 2, "name": "bar_linux_amd64.zip.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write(archive)
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.zip\n", sha256.Sum256(archive))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", Validator: &SHA2Validator{}, SymlinkStrategy: SymlinkVersioned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release should be detected")
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-verify-installed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "bar-1.0.0"), []byte(bin), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmdPath := filepath.Join(dir, "bar")
+	if err := os.Symlink("bar-1.0.0", cmdPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := up.VerifyInstalled(ctx, cmdPath, rel); err != nil {
+		t.Fatal("Binary linked from command should match the release:", err)
+	}
+}
+
+func TestVerifyInstalledUncompressedByFormat(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because .exe is added to the command path")
+	}
+	ctx := context.Background()
+	bin := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	encrypted := "ENC:" + bin
+	archive := zipScript(t, "bar", bin)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.enc", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.enc"},
+				{"id": 2, "name": "bar_linux_amd64.enc.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			fmt.Fprint(w, encrypted)
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.enc\n", sha256.Sum256([]byte(encrypted)))
+		case "/api/v3/repos/foo/bar/releases/assets/3":
+			w.Write(archive)
+		case "/api/v3/repos/foo/bar/releases/assets/4":
+			fmt.Fprintf(w, "%x  bar-Linux-amd64-release\n", sha256.Sum256(archive))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validator:         &SHA2Validator{},
+		Decompressors: map[string]DecompressorFunc{
+			".enc": func(src io.Reader, cmd string) (io.Reader, error) {
+				b, err := ioutil.ReadAll(src)
+				if err != nil {
+					return nil, err
+				}
+				return bytes.NewReader(bytes.TrimPrefix(b, []byte("ENC:"))), nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectVersion(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Release with custom format should be detected")
+	}
+	// Asset whose format was detected from its content type
+	byContentType := &Release{
+		Version:           rel.Version,
+		AssetURL:          "https://example.com/v1.0.0/bar-Linux-amd64-release",
+		AssetName:         "bar-Linux-amd64-release",
+		AssetID:           3,
+		ValidationAssetID: 4,
+		RepoOwner:         "foo",
+		RepoName:          "bar",
+		Format:            FormatZip,
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-verify-installed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte(bin), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rel := range []*Release{rel, byContentType} {
+		t.Run(rel.AssetName, func(t *testing.T) {
+			if err := up.VerifyInstalled(ctx, cmdPath, rel); err != nil {
+				t.Fatal("Installed binary should match the executable uncompressed from the release:", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFromMirror(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	mirrored := asset
+	apiDownloads := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.zip", "browser_download_url": "https://github.com/foo/bar/releases/download/v1.0.0/bar_linux_amd64.zip"},
+				{"id": 2, "name": "bar_linux_amd64.zip.sha256"}
+			]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			apiDownloads++
+			w.Write(asset)
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.zip\n", sha256.Sum256(asset))
+		case "/mirror/foo/bar/releases/download/v1.0.0/bar_linux_amd64.zip":
+			w.Write(mirrored)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, resumable := range []bool{false, true} {
+		mirrored = asset
+		up, err := NewUpdater(ctx, Config{
+			APIToken:           "hogehoge",
+			EnterpriseBaseURL:  ts.URL,
+			OS:                 "linux",
+			Arch:               "amd64",
+			Validator:          &SHA2Validator{},
+			ResumableDownloads: resumable,
+			AssetURLRewriter: func(u string) string {
+				return strings.Replace(u, "https://github.com/", ts.URL+"/mirror/", 1)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatal("Release was not detected")
+		}
+		if !strings.HasPrefix(rel.AssetURL, "https://github.com/") {
+			t.Fatal("Asset URL of the release should not be rewritten:", rel.AssetURL)
+		}
+
+		var buf bytes.Buffer
+		if err := up.DownloadReleaseAsset(ctx, rel, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != script {
+			t.Fatalf("Downloaded binary is unexpected: %q", buf.String())
+		}
+		if apiDownloads != 0 {
+			t.Fatal("Asset should not be downloaded via API but downloaded", apiDownloads, "times")
+		}
+
+		// Content from the mirror is validated with the validation asset on GitHub
+		mirrored = zipScript(t, "bar", "#!/bin/sh\necho 'tampered'\n")
+		err = up.DownloadReleaseAsset(ctx, rel, ioutil.Discard)
+		if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageValidation {
+			t.Fatalf("Tampered asset on the mirror should fail validation (resumable=%v): %#v", resumable, err)
+		}
+	}
+}
+
+func TestValidatorsSelectedByValidationAssets(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	sha256sum := fmt.Sprintf("%x  bar_linux_amd64.zip\n", sha256.Sum256(asset))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v3.0.0", "assets": [{"id": 30, "name": "bar_linux_amd64.zip"}]},
+				{"tag_name": "v2.0.0", "assets": [
+					{"id": 20, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v2.0.0/bar_linux_amd64.zip"},
+					{"id": 21, "name": "bar_linux_amd64.zip.sha512"}
+				]},
+				{"tag_name": "v1.0.0", "assets": [
+					{"id": 10, "name": "bar_linux_amd64.zip", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"},
+					{"id": 11, "name": "bar_linux_amd64.zip.sha256"}
+				]}
+			]`)
+		case "/api/v3/repos/foo/bar/releases/assets/10", "/api/v3/repos/foo/bar/releases/assets/20":
+			w.Write(asset)
+		case "/api/v3/repos/foo/bar/releases/assets/11":
+			fmt.Fprint(w, sha256sum)
+		case "/api/v3/repos/foo/bar/releases/assets/21":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.zip\n", sha512.Sum512(asset))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		Validators:        []Validator{&HashValidator{Hash: crypto.SHA512}, &SHA2Validator{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := up.DetectVersions(ctx, "foo/bar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int64{}
+	for _, r := range rels {
+		ids = append(ids, r.ValidationAssetID)
+	}
+	if !reflect.DeepEqual(ids, []int64{21, 11}) {
+		t.Fatal("Releases should be detected with their validation assets but got", ids)
+	}
+	want := []SkippedRelease{{"v3.0.0", "no validation file"}}
+	if skipped := up.SkippedReleases(); !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("Release without any validation file should be skipped: %v", skipped)
+	}
+
+	for _, r := range rels {
+		var buf bytes.Buffer
+		if err := up.DownloadReleaseAsset(ctx, r, &buf); err != nil {
+			t.Fatalf("Asset of %s should be validated: %s", r.Version, err)
+		}
+		if buf.String() != script {
+			t.Fatalf("Downloaded binary is unexpected: %q", buf.String())
+		}
+	}
+
+	sha256sum = strings.Repeat("0", 64) + "  bar_linux_amd64.zip\n"
+	err = up.DownloadReleaseAsset(ctx, rels[1], ioutil.Discard)
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageValidation {
+		t.Fatalf("Validation with SHA256 checksum should fail: %#v", err)
+	}
+
+	_, err = up.DetectVersions(ctx, "foo/bar", "v3.0.0")
+	if !errors.Is(err, ErrValidationAssetNotFound) || !strings.Contains(err.Error(), `".sha512", ".sha256"`) {
+		t.Fatal("Error should tell none of validation files was found:", err)
+	}
+}
+
+func TestUpdateCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because shell script is used as an executable")
+	}
+	ctx := context.Background()
+
+	assets := map[string][]byte{
+		"/api/v3/repos/foo/bar/releases/assets/1": zipScript(t, "foo", "#!/bin/sh\necho 'foo version 2.0.0'\n"),
+		"/api/v3/repos/foo/bar/releases/assets/2": zipScript(t, "foo-helper", "#!/bin/sh\necho 'foo-helper version 2.0.0'\n"),
+		"/api/v3/repos/foo/bar/releases/assets/3": zipScript(t, "foo", "#!/bin/sh\necho 'foo version 3.0.0'\n"),
+		"/api/v3/repos/foo/bar/releases/assets/4": zipScript(t, "foo-helper", "#!/bin/sh\necho 'broken'\n"),
+		"/api/v3/repos/foo/bar/releases/assets/5": zipScript(t, "foo", "#!/bin/sh\necho 'foo version 4.0.0'\n"),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/repos/foo/bar/releases" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v4.0.0", "assets": [
+					{"id": 5, "name": "foo_linux_amd64.zip", "browser_download_url": "https://example.com/v4.0.0/foo_linux_amd64.zip"}
+				]},
+				{"tag_name": "v2.0.0", "assets": [
+					{"id": 1, "name": "foo_linux_amd64.zip", "browser_download_url": "https://example.com/v2.0.0/foo_linux_amd64.zip"},
+					{"id": 2, "name": "foo-helper_linux_amd64.zip", "browser_download_url": "https://example.com/v2.0.0/foo-helper_linux_amd64.zip"}
+				]}
+			]`)
+			return
+		}
+		if r.URL.Path == "/api/v3/repos/foo/broken/releases" {
+			fmt.Fprint(w, `[
+				{"tag_name": "v3.0.0", "assets": [
+					{"id": 3, "name": "foo_linux_amd64.zip", "browser_download_url": "https://example.com/v3.0.0/foo_linux_amd64.zip"},
+					{"id": 4, "name": "foo-helper_linux_amd64.zip", "browser_download_url": "https://example.com/v3.0.0/foo-helper_linux_amd64.zip"}
+				]}
+			]`)
+			return
+		}
+		p := strings.Replace(r.URL.Path, "/foo/broken/", "/foo/bar/", 1)
+		if b, ok := assets[p]; ok {
+			w.Write(b)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := map[string]string{
+		"foo":        filepath.Join(dir, "foo"),
+		"foo-helper": filepath.Join(dir, "foo-helper"),
+	}
+	for cmd, p := range paths {
+		if err := ioutil.WriteFile(p, []byte("#!/bin/sh\necho '"+cmd+" version 1.0.0'\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		OS:                "linux",
+		Arch:              "amd64",
+		VersionCommand:    []string{"--version"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// v4.0.0 is skipped since it does not have an asset for foo-helper
+	rels, err := up.UpdateCommands(ctx, paths, semver.MustParse("1.0.0"), "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cmd, p := range paths {
+		if rels[cmd].Version.String() != "2.0.0" {
+			t.Errorf("Release for %s should be 2.0.0 but got %v", cmd, rels[cmd].Version)
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "#!/bin/sh\necho '" + cmd + " version 2.0.0'\n"; string(b) != want {
+			t.Errorf("%s was not updated: %q", cmd, string(b))
+		}
+	}
+
+	// foo is rolled back when verifying foo-helper fails
+	_, err = up.UpdateCommands(ctx, paths, semver.MustParse("2.0.0"), "foo/broken")
+	if err == nil {
+		t.Fatal("Error should occur when one of the commands cannot be updated")
+	}
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageVerification {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for cmd, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "#!/bin/sh\necho '" + cmd + " version 2.0.0'\n"; string(b) != want {
+			t.Errorf("%s was not rolled back: %q", cmd, string(b))
+		}
+	}
+}
+
+func TestUpdateWithBackupDir(t *testing.T) {
+	ctx := context.Background()
+
+	script := func(v int) string {
+		return fmt.Sprintf("#!/bin/sh\necho 'bar version %d.0.0'\n", v)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v int
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/%d", &v); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(zipScript(t, "bar", script(v)))
+	}))
+	defer ts.Close()
+
+	// A directory on tmpfs is usually on another filesystem than the temporary directory
+	roots := []string{""}
+	if runtime.GOOS == "linux" {
+		if _, err := os.Stat("/dev/shm"); err == nil {
+			roots = append(roots, "/dev/shm")
+		}
+	}
+	for _, root := range roots {
+		dir, err := ioutil.TempDir("", "selfupdate-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		top, err := ioutil.TempDir(root, "selfupdate-test-backup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(top)
+		backupDir := filepath.Join(top, "backups")
+
+		cmdPath := filepath.Join(dir, "bar")
+		if err := ioutil.WriteFile(cmdPath, []byte(script(1)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, KeepBackups: 2, BackupDir: backupDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for v := 2; v <= 3; v++ {
+			rel := &Release{
+				Version:   semver.MustParse(fmt.Sprintf("%d.0.0", v)),
+				AssetURL:  fmt.Sprintf("https://example.com/v%d.0.0/bar_linux_amd64.zip", v),
+				AssetID:   int64(v),
+				RepoOwner: "foo",
+				RepoName:  "bar",
+			}
+			if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for d, want := range map[string][]string{dir: {"bar"}, backupDir: {".bar.old", ".bar.old.1"}} {
+			entries, err := ioutil.ReadDir(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := []string{}
+			for _, e := range entries {
+				files = append(files, e.Name())
+			}
+			if !reflect.DeepEqual(files, want) {
+				t.Fatalf("Files in %s should be %v but got %v", d, want, files)
+			}
+		}
+
+		if !up.CanRollback(cmdPath) {
+			t.Fatal("Backup in BackupDir should be found")
+		}
+		if err := up.RollbackUpdate(cmdPath); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(cmdPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != script(2) {
+			t.Fatalf("Rollback should restore the backup in BackupDir but got %q", b)
+		}
+		if s, err := os.Stat(cmdPath); err != nil || s.Mode().Perm() != 0755 {
+			t.Fatal("Restored binary should be executable:", s, err)
+		}
+
+		if err := up.CleanupBackups(cmdPath); err != nil {
+			t.Fatal(err)
+		}
+		if up.CanRollback(cmdPath) {
+			t.Fatal("Backups in BackupDir should be cleaned up")
+		}
+	}
+}
+
+func TestUpdateSyncsBinary(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(asset)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	rel := &Release{AssetURL: "https://example.com/bar_linux_amd64.zip", AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+
+	type call struct {
+		name    string
+		current string
+	}
+	var calls []call
+	orig := syncFile
+	defer func() { syncFile = orig }()
+	syncFile = func(f *os.File) error {
+		b, _ := ioutil.ReadFile(cmdPath)
+		calls = append(calls, call{f.Name(), string(b)})
+		return orig(f)
+	}
+
+	for _, disable := range []bool{false, true} {
+		calls = nil
+		if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, DisableSync: disable})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+			t.Fatal(err)
+		}
+
+		if disable {
+			if len(calls) != 0 {
+				t.Fatal("Nothing should be synced with DisableSync:", calls)
+			}
+			continue
+		}
+		if len(calls) != 2 {
+			t.Fatal("Temporary file and replaced binary should be synced:", calls)
+		}
+		if ok, _ := filepath.Match(filepath.Join(dir, ".bar.*.tmp"), calls[0].name); !ok || calls[0].current != "old" {
+			t.Fatalf("Temporary file should be synced before replacing the binary: %+v", calls[0])
+		}
+		if calls[1].name != cmdPath || calls[1].current != script {
+			t.Fatalf("Binary should be synced after the replacement: %+v", calls[1])
+		}
+	}
+}
+
+func TestDefaultBackupDir(t *testing.T) {
+	dir, err := DefaultBackupDir("myapp")
+	if err != nil {
+		t.Skip("User cache directory is not available:", err)
+	}
+	if filepath.Base(dir) != "backups" || filepath.Base(filepath.Dir(dir)) != "myapp" {
+		t.Fatal("Unexpected default backup directory:", dir)
+	}
+}
+
+func TestUpdateKeepingBackups(t *testing.T) {
+	ctx := context.Background()
+
+	script := func(v int) string {
+		return fmt.Sprintf("#!/bin/sh\necho 'bar version %d.0.0'\n", v)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v int
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/v3/repos/foo/bar/releases/assets/%d", &v); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(zipScript(t, "bar", script(v)))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		keep  int
+		files []string
+	}{
+		{0, []string{".bar.old"}},
+		{3, []string{".bar.old", ".bar.old.1", ".bar.old.2"}},
+	} {
+		dir, err := ioutil.TempDir("", "selfupdate-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		cmdPath := filepath.Join(dir, "bar")
+		if err := ioutil.WriteFile(cmdPath, []byte(script(1)), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, KeepBackups: tc.keep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for v := 2; v <= 5; v++ {
+			rel := &Release{
+				Version:   semver.MustParse(fmt.Sprintf("%d.0.0", v)),
+				AssetURL:  fmt.Sprintf("https://example.com/v%d.0.0/bar_linux_amd64.zip", v),
+				AssetID:   int64(v),
+				RepoOwner: "foo",
+				RepoName:  "bar",
+			}
+			if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := []string{}
+		for _, e := range entries {
+			if e.Name() != "bar" {
+				files = append(files, e.Name())
+			}
+		}
+		if !reflect.DeepEqual(files, tc.files) {
+			t.Fatalf("Backups with KeepBackups=%d should be %v but got %v", tc.keep, tc.files, files)
+		}
+		for i, f := range tc.files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := script(4 - i); string(b) != want {
+				t.Errorf("Backup %s should be %q but got %q", f, want, string(b))
+			}
+		}
+
+		// Rollbacks can be repeated as many times as the retained backups
+		for i := range tc.files {
+			if err := up.RollbackUpdate(cmdPath); err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadFile(cmdPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := script(4 - i); string(b) != want {
+				t.Errorf("Rollback #%d should restore %q but got %q", i+1, want, string(b))
+			}
+		}
+		if up.CanRollback(cmdPath) {
+			t.Error("All backups should be consumed by rollbacks")
+		}
+
+		if err := up.UpdateTo(ctx, &Release{AssetURL: "https://example.com/bar_linux_amd64.zip", AssetID: 5, RepoOwner: "foo", RepoName: "bar"}, cmdPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := up.CleanupBackups(cmdPath); err != nil {
+			t.Fatal(err)
+		}
+		if up.CanRollback(cmdPath) {
+			t.Error("Backup should be removed by cleanup")
+		}
+		if _, err := os.Stat(cmdPath); err != nil {
+			t.Fatal("Command should not be removed by cleanup:", err)
+		}
+	}
+}
+
+func TestResumableDownloads(t *testing.T) {
+	asset := []byte("this is a large asset downloaded on flaky connection")
+	for _, tc := range []struct {
+		what       string
+		rangeable  bool
+		changed    bool
+		wantRanges []string
+	}{
+		{"resume with range", true, false, []string{"", "bytes=10-"}},
+		{"restart without range support", false, false, []string{"", "bytes=10-"}},
+		{"restart when asset was changed", true, true, []string{"", "bytes=10-"}},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			ctx := context.Background()
+			ranges := []string{}
+			ifRanges := []string{}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v3/repos/foo/resumable/releases/assets/1":
+					http.Redirect(w, r, "/download/asset", http.StatusFound)
+				case "/download/asset":
+					rng := r.Header.Get("Range")
+					ranges = append(ranges, rng)
+					ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+					etag := `"v1"`
+					if tc.changed && len(ranges) > 1 {
+						etag = `"v2"`
+					}
+					w.Header().Set("ETag", etag)
+					if len(ranges) == 1 {
+						// Connection is lost in the middle of the body
+						w.Header().Set("Content-Length", strconv.Itoa(len(asset)))
+						w.Write(asset[:10])
+						return
+					}
+					if tc.rangeable && rng != "" && r.Header.Get("If-Range") == etag {
+						var start int
+						fmt.Sscanf(rng, "bytes=%d-", &start)
+						w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(asset)-1, len(asset)))
+						w.WriteHeader(http.StatusPartialContent)
+						w.Write(asset[start:])
+						return
+					}
+					w.Write(asset)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			rel := &Release{AssetID: 1, AssetByteSize: len(asset), RepoOwner: "foo", RepoName: "resumable"}
+			path, err := partialDownloadPath(rel, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer removePartialFile(path)
+
+			var downloaded []int64
+			up, err := NewUpdater(ctx, Config{
+				APIToken:             "hogehoge",
+				EnterpriseBaseURL:    ts.URL,
+				DownloadRetries:      1,
+				DownloadRetryBackoff: time.Millisecond,
+				ResumableDownloads:   true,
+				Progress: func(n, total int64) {
+					downloaded = append(downloaded, n)
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := up.downloadAndValidate(ctx, rel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(asset) {
+				t.Fatalf("Unexpected asset content: %q", string(data))
+			}
+			if !reflect.DeepEqual(ranges, tc.wantRanges) {
+				t.Errorf("Range headers should be %q but got %q", tc.wantRanges, ranges)
+			}
+			if want := []string{"", `"v1"`}; !reflect.DeepEqual(ifRanges, want) {
+				t.Errorf("If-Range headers should be %q but got %q", want, ifRanges)
+			}
+			if last := downloaded[len(downloaded)-1]; last != int64(len(asset)) {
+				t.Errorf("Progress should reach %d bytes but got %d", len(asset), last)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Error("Partial file should be removed after download:", err)
+			}
+		})
+	}
+}
+
+func TestResumableDownloadRestart(t *testing.T) {
+	asset := []byte("this is an asset whose partial download was already completed")
+	for _, tc := range []struct {
+		what    string
+		symlink bool
+	}{
+		{"unsatisfiable range", false},
+		{"planted symlink", true},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			if tc.symlink && runtime.GOOS == "windows" {
+				t.Skip("because creating a symlink requires a privilege on Windows")
+			}
+			ctx := context.Background()
+			ranges := []string{}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v3/repos/foo/resumable/releases/assets/2":
+					http.Redirect(w, r, "/download/asset", http.StatusFound)
+				case "/download/asset":
+					ranges = append(ranges, r.Header.Get("Range"))
+					w.Header().Set("ETag", `"v1"`)
+					if r.Header.Get("Range") != "" {
+						w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+						return
+					}
+					w.Write(asset)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			// Size is unknown so the complete partial file is resumed
+			rel := &Release{AssetID: 2, RepoOwner: "foo", RepoName: "resumable"}
+			path, err := partialDownloadPath(rel, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer removePartialFile(path)
+			if err := ioutil.WriteFile(path+".validator", []byte(`"v1"`), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			victim := ""
+			if tc.symlink {
+				dir, err := ioutil.TempDir("", "selfupdate-test")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.RemoveAll(dir)
+				victim = filepath.Join(dir, "victim")
+				if err := ioutil.WriteFile(victim, []byte("precious"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(victim, path); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := ioutil.WriteFile(path, asset, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, ResumableDownloads: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := up.downloadAndValidate(ctx, rel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(asset) {
+				t.Fatalf("Unexpected asset content: %q", string(data))
+			}
+			if _, err := os.Lstat(path); !os.IsNotExist(err) {
+				t.Error("Partial file should be removed after download:", err)
+			}
+			if !tc.symlink {
+				if want := []string{fmt.Sprintf("bytes=%d-", len(asset)), ""}; !reflect.DeepEqual(ranges, want) {
+					t.Errorf("Download should restart after 416 with %q but got %q", want, ranges)
+				}
+				return
+			}
+			if want := []string{""}; !reflect.DeepEqual(ranges, want) {
+				t.Errorf("Planted symlink should not be resumed (%q) but got %q", want, ranges)
+			}
+			b, err := ioutil.ReadFile(victim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "precious" {
+				t.Fatalf("File linked from planted symlink should not be modified but got %q", b)
+			}
+		})
+	}
+}
+
+func TestPostDownloadHook(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/foo/bar/releases/assets/1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(asset)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	rel := &Release{AssetURL: "https://example.com/bar_linux_amd64.zip", AssetID: 1, RepoOwner: "foo", RepoName: "bar"}
+
+	hooked := ""
+	up, err := NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		PostDownloadHook: func(path string) error {
+			hooked = path
+			if path == cmdPath {
+				return errors.New("hook should not receive the current binary")
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if string(b) != script {
+				return fmt.Errorf("hook should receive the uncompressed binary but got %q", b)
+			}
+			return ioutil.WriteFile(path, []byte(script+"# signed\n"), 0755)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	if hooked == "" {
+		t.Fatal("Post-download hook was not called")
+	}
+	if _, err := os.Stat(hooked); !os.IsNotExist(err) {
+		t.Fatal("Temporary file given to the hook should be removed:", err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != script+"# signed\n" {
+		t.Fatalf("Binary modified by the hook should be installed but got %q", b)
+	}
+
+	hookErr := errors.New("codesign failed")
+	up, err = NewUpdater(ctx, Config{
+		APIToken:          "hogehoge",
+		EnterpriseBaseURL: ts.URL,
+		PostDownloadHook:  func(string) error { return hookErr },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = up.UpdateTo(ctx, rel, cmdPath)
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageValidation {
+		t.Fatalf("Error from the hook should abort the update at validation stage: %#v", err)
+	}
+	if !errors.Is(err, hookErr) {
+		t.Fatal("Error should wrap the error from the hook:", err)
+	}
+	b, err = ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != script+"# signed\n" {
+		t.Fatalf("Binary should not be replaced when the hook failed but got %q", b)
+	}
+}
+
+func TestUpdateWithSplitAsset(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	third := len(asset) / 3
+	parts := [][]byte{asset[:third], asset[third : 2*third], asset[2*third:]}
+	downloaded := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprintf(w, `[
+				{"tag_name": "v1.0.0", "assets": [
+					{"id": 3, "name": "bar_linux_amd64.zip.003", "size": %d, "browser_download_url": "https://example.com/bar_linux_amd64.zip.003"},
+					{"id": 1, "name": "bar_linux_amd64.zip.001", "size": %d, "browser_download_url": "https://example.com/bar_linux_amd64.zip.001"},
+					{"id": 2, "name": "bar_linux_amd64.zip.002", "size": %d, "browser_download_url": "https://example.com/bar_linux_amd64.zip.002"},
+					{"id": 4, "name": "bar_linux_amd64.zip.sha256"}
+				]},
+				{"tag_name": "v2.0.0", "assets": [
+					{"id": 11, "name": "bar_linux_amd64.zip.001", "browser_download_url": "https://example.com/bar_linux_amd64.zip.001"},
+					{"id": 13, "name": "bar_linux_amd64.zip.003", "browser_download_url": "https://example.com/bar_linux_amd64.zip.003"},
+					{"id": 14, "name": "bar_linux_amd64.zip.sha256"}
+				]}
+			]`, len(parts[2]), len(parts[0]), len(parts[1]))
+		case "/api/v3/repos/foo/bar/releases/assets/1", "/api/v3/repos/foo/bar/releases/assets/2", "/api/v3/repos/foo/bar/releases/assets/3":
+			id := r.URL.Path[len(r.URL.Path)-1:]
+			downloaded = append(downloaded, id)
+			n, _ := strconv.Atoi(id)
+			w.Write(parts[n-1])
+		case "/api/v3/repos/foo/bar/releases/assets/4":
+			fmt.Fprintf(w, "%x  bar_linux_amd64.zip\n", sha256.Sum256(asset))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", ReportNoMatchingAsset: true, Validator: &SHA2Validator{}}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("Split asset should not be detected without AllowSplitAssets:", err)
+	}
+
+	config.AllowSplitAssets = true
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Split asset should be detected")
+	}
+	if rel.Version.String() != "1.0.0" {
+		t.Fatal("Release whose split asset lacks a part should not be detected:", rel.Version)
+	}
+	if rel.AssetName != "bar_linux_amd64.zip" || rel.AssetID != 1 || rel.AssetByteSize != len(asset) || rel.Format != FormatZip {
+		t.Fatalf("Release should represent the whole asset: %+v", rel)
+	}
+	if !reflect.DeepEqual(rel.AssetPartIDs, []int64{1, 2, 3}) {
+		t.Fatal("Parts should be detected in order:", rel.AssetPartIDs)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != script {
+		t.Fatalf("Binary uncompressed from reassembled asset is unexpected: %q", b)
+	}
+	if !reflect.DeepEqual(downloaded, []string{"1", "2", "3"}) {
+		t.Fatal("Parts should be downloaded in order:", downloaded)
+	}
+
+	// Validation runs on the whole asset
+	parts[1] = bytes.Repeat([]byte{0}, len(parts[1]))
+	err = up.UpdateTo(ctx, rel, cmdPath)
+	if uerr, ok := err.(*UpdateError); !ok || uerr.Stage != StageValidation {
+		t.Fatalf("Broken part should fail validation: %#v", err)
+	}
+}
+
+func TestDetectAssetByContentType(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	asset := zipScript(t, "bar", script)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[
+				{"tag_name": "v2.0.0", "assets": [
+					{"id": 3, "name": "Bar-Linux-x86_64-release", "content_type": "application/zip"},
+					{"id": 4, "name": "Bar-Linux-amd64-debug", "content_type": "application/zip"}
+				]},
+				{"tag_name": "v1.0.0", "assets": [
+					{"id": 1, "name": "Bar-Linux-x86_64-release", "content_type": "application/zip", "browser_download_url": "https://example.com/v1.0.0/Bar-Linux-x86_64-release"},
+					{"id": 2, "name": "Bar-Linux-x86_64-README", "content_type": "text/plain"},
+					{"id": 5, "name": "Bar-Darwin-x86_64-release", "content_type": "application/zip"},
+					{"id": 6, "name": "Bar-Linux-arm64-release", "content_type": "application/zip"}
+				]}
+			]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", ReportNoMatchingAsset: true}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("Asset should not be detected by content type without AllowContentTypeFallback:", err)
+	}
+
+	config.AllowContentTypeFallback = true
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Asset should be detected by content type")
+	}
+	if rel.Version.String() != "1.0.0" {
+		t.Fatal("Release with ambiguous assets should not be detected:", rel.Version)
+	}
+	if rel.AssetID != 1 || rel.Format != FormatZip {
+		t.Fatalf("Asset and its format should be detected by content type: %+v", rel)
+	}
+
+	var buf bytes.Buffer
+	if err := up.DownloadReleaseAsset(ctx, rel, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != script {
+		t.Fatalf("Binary uncompressed from asset detected by content type is unexpected: %q", buf.String())
+	}
+
+	config.AllowedFormats = []AssetFormat{FormatTarGz}
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("Format of content type should be restricted by AllowedFormats:", err)
+	}
+}
+
+func TestCustomDecompressor(t *testing.T) {
+	ctx := context.Background()
+	script := "#!/bin/sh\necho 'bar version 1.0.0'\n"
+	encrypted := []byte(script)
+	for i := range encrypted {
+		encrypted[i] ^= 0x5a
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprint(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.ENC", "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.ENC"}
+			]}]`)
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write(encrypted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", ReportNoMatchingAsset: true}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); !errors.Is(err, ErrNoMatchingAsset) {
+		t.Fatal("Asset of unknown extension should not be detected:", err)
+	}
+
+	var name string
+	config.Decompressors = map[string]DecompressorFunc{
+		"enc": func(src io.Reader, cmdName string) (io.Reader, error) {
+			name = cmdName
+			b, err := ioutil.ReadAll(src)
+			if err != nil {
+				return nil, err
+			}
+			for i := range b {
+				b[i] ^= 0x5a
+			}
+			return bytes.NewReader(b), nil
+		},
+	}
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.AssetID != 1 {
+		t.Fatal("Asset of the extension of decompressor should be detected:", rel)
+	}
+	if rel.Format != AssetFormat("enc") {
+		t.Fatal("Format should be the extension of decompressor:", rel.Format)
+	}
+
+	var buf bytes.Buffer
+	if err := up.DownloadReleaseAsset(ctx, rel, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != script {
+		t.Fatalf("Binary should be uncompressed by decompressor but got %q", buf.String())
+	}
+	if name != "bar" {
+		t.Fatal("Command name should be passed to decompressor:", name)
+	}
+
+	config.Decompressors["enc"] = func(src io.Reader, cmdName string) (io.Reader, error) {
+		return nil, errors.New("broken container")
+	}
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = up.DownloadReleaseAsset(ctx, rel, ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "broken container") {
+		t.Fatal("Error from decompressor should be reported:", err)
+	}
+
+	for _, d := range []map[string]DecompressorFunc{{"": config.Decompressors["enc"]}, {".enc": nil}} {
+		if _, err := NewUpdater(ctx, Config{Decompressors: d}); err == nil {
+			t.Fatal("Invalid decompressors should be rejected:", d)
+		}
+	}
+}
+
+func TestUpdateWithCommandName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because the command is installed without '.exe'")
+	}
+
+	ctx := context.Background()
+	kubectl := zipScript(t, "kubectl", "new kubectl")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprintf(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "kustomize_linux_amd64.zip", "size": 10, "browser_download_url": "https://example.com/v1.0.0/kustomize_linux_amd64.zip"},
+				{"id": 2, "name": "kubectl_linux_amd64.zip", "size": %d, "browser_download_url": "https://example.com/v1.0.0/kubectl_linux_amd64.zip"}
+			]}]`, len(kubectl))
+		case "/api/v3/repos/foo/bar/releases/assets/2":
+			w.Write(kubectl)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64", CommandName: "kubectl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, ok, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || rel.AssetID != 2 {
+		t.Fatal("Asset of the command name should be detected:", rel)
+	}
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "k")
+	if err := ioutil.WriteFile(cmdPath, []byte("old kubectl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.UpdateTo(ctx, rel, cmdPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new kubectl" {
+		t.Fatalf("Binary named by the command name should be installed as 'k' but got %q", b)
+	}
+}
+
+func TestUpdateCommandRefusesDowngrade(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("because the command is installed without '.exe'")
+	}
+
+	ctx := context.Background()
+	asset := zipScript(t, "bar", "old release")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/foo/bar/releases":
+			fmt.Fprintf(w, `[{"tag_name": "v1.0.0", "assets": [
+				{"id": 1, "name": "bar_linux_amd64.zip", "size": %d, "browser_download_url": "https://example.com/v1.0.0/bar_linux_amd64.zip"}
+			]}]`, len(asset))
+		case "/api/v3/repos/foo/bar/releases/assets/1":
+			w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmdPath := filepath.Join(dir, "bar")
+	if err := ioutil.WriteFile(cmdPath, []byte("current"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, OS: "linux", Arch: "amd64"}
+	up, err := NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = up.UpdateCommand(ctx, cmdPath, semver.MustParse("1.2.0"), "foo/bar")
+	if !errors.Is(err, ErrDowngrade) {
+		t.Fatal("Downgrade should be refused:", err)
+	}
+	var derr *DowngradeError
+	if !errors.As(err, &derr) || derr.Current.String() != "1.2.0" || derr.Version.String() != "1.0.0" {
+		t.Fatal("Both versions should be reported:", err)
+	}
+	b, err := ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "current" {
+		t.Fatalf("Binary should not be replaced on refused downgrade but got %q", b)
+	}
+
+	config.AllowDowngrade = true
+	up, err = NewUpdater(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := up.UpdateCommand(ctx, cmdPath, semver.MustParse("1.2.0"), "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version.String() != "1.0.0" {
+		t.Fatal("Release should be downgraded to the latest release:", rel.Version)
+	}
+	b, err = ioutil.ReadFile(cmdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old release" {
+		t.Fatalf("Binary should be downgraded with AllowDowngrade but got %q", b)
+	}
+}
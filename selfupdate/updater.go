@@ -6,24 +6,112 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"sync"
+	"text/template"
+	"time"
 
+	"github.com/blang/semver"
 	"github.com/google/go-github/v30/github"
 	gitconfig "github.com/tcnksm/go-gitconfig"
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 )
 
 // Updater is responsible for managing the context of self-update.
 // It contains GitHub client and its context.
 type Updater struct {
-	api       *github.Client
-	validator Validator
-	filters   []*regexp.Regexp
+	api                   *github.Client
+	validator             Validator
+	validators            []Validator
+	filters               []*regexp.Regexp
+	progress              ProgressFunc
+	versionExtractor      VersionExtractor
+	downloadClient        *http.Client
+	prerelease            bool
+	cache                 *releaseCache
+	maxReleasePages       int
+	versionCommand        []string
+	filterMode            FilterMode
+	compressionPreference []string
+	preferRawAsset        bool
+	downloadRetries       int
+	downloadRetryBackoff  time.Duration
+	downloadTimeout       time.Duration
+	os                    string
+	arch                  string
+	libcVariant           string
+	logger                Logger
+	assetTemplate         *template.Template
+	allowNonSemverTags    bool
+	concurrency           int
+	source                ReleaseSource
+	minVersion            string
+	maxVersion            string
+	versionRange          semver.Range
+	minAssetSize          int
+	preservePermissions   bool
+	channel               string
+	allowPackageAssets    bool
+	latestEndpoint        bool
+	reportNoMatchingAsset bool
+	verifyBinaryFormat    bool
+	symlinkStrategy       SymlinkStrategy
+	allowedFormats        []AssetFormat
+	allowDrafts           bool
+	allowDowngrade        bool
+	tagPrefix             string
+	commandName           string
+	manifestAssetName     string
+	keepBackups           int
+	backupDir             string
+	disableSync           bool
+	checkCachePath        string
+	checkInterval         time.Duration
+	maxPollInterval       time.Duration
+	checkCacheMu          sync.Mutex
+	strictTagParsing      bool
+	resumableDownloads    bool
+	releaseFilter         func(*Release) bool
+	assetURLRewriter      func(string) string
+	postDownloadHook      func(string) error
+	allowSplitAssets      bool
+	contentTypeFallback   bool
+	decompressors         map[string]DecompressorFunc
+	archiveDepth          int
+	optionErr             error
+	skipped               skippedReleases
+	poll                  pollState
 }
 
+// SymlinkStrategy represents how to update a command installed as a symbolic link such as 'myapp -> myapp-1.2.0'.
+type SymlinkStrategy string
+
+const (
+	// SymlinkReplaceTarget follows the symbolic link and replaces the file it points to. This is the default.
+	SymlinkReplaceTarget SymlinkStrategy = "replace-target"
+	// SymlinkVersioned puts the new binary as '<cmd>-<version>' next to the file the link points to, then repoints
+	// the link to it atomically. The previous file is left as is so that the update can be rolled back.
+	SymlinkVersioned SymlinkStrategy = "versioned"
+)
+
+// FilterMode represents how multiple filters in Config.Filters are combined.
+type FilterMode int
+
+const (
+	// FilterAny selects an asset matching any one of the filters. This is the default.
+	FilterAny FilterMode = iota
+	// FilterAll selects an asset matching all of the filters.
+	FilterAll
+)
+
 // Config represents the configuration of self-update.
 type Config struct {
-	// APIToken represents GitHub API token. If it's not empty, it will be used for authentication of GitHub API
+	// APIToken represents GitHub API token. If it's not empty, it will be used for authentication of GitHub API.
+	// When it is empty, $GITHUB_TOKEN, $GH_TOKEN and the token in gitconfig are tried in this order.
 	APIToken string
+	// DisableEnvToken disables looking up the API token from environment variables and gitconfig when APIToken
+	// is empty. Set this to true to call GitHub API without authentication even if the token is available.
+	DisableEnvToken bool
 	// EnterpriseBaseURL is a base URL of GitHub API. If you want to use this library with GitHub Enterprise,
 	// please set "https://{your-organization-address}/api/v3/" to this field.
 	EnterpriseBaseURL string
@@ -32,32 +120,329 @@ type Config struct {
 	EnterpriseUploadURL string
 	// Validator represents types which enable additional validation of downloaded release.
 	Validator Validator
+	// Validators are tried in order when a release may be validated in several ways such as a PGP signature if
+	// present, otherwise a SHA256 checksum. For each release, the first validator whose validation asset exists is
+	// used. A release is dropped only when none of them finds its validation asset. When Validator is also set, it is
+	// tried before them.
+	Validators []Validator
 	// Filters are regexp used to filter on specific assets for releases with multiple assets.
-	// An asset is selected if it matches any of those, in addition to the regular tag, os, arch, extensions.
+	// Filters never widen the selection: at first an asset name must end with the suffix for the current OS and arch
+	// (e.g. 'linux_amd64.tar.gz'), then it must match the filters to be selected. How the filters are combined is
+	// decided by FilterMode. By default, an asset is selected if it matches any of those.
 	// Please make sure that your filter(s) uniquely match an asset.
 	Filters []string
+	// FilterMode decides how Filters are combined. FilterAny (default) requires matching any one of them and
+	// FilterAll requires matching all of them.
+	FilterMode FilterMode
+	// CompressionPreference is the list of file extensions of asset formats in order of preference such as
+	// []string{".tar.xz", ".tar.gz", ".zip", ""}. An empty string means an uncompressed binary. It is used to
+	// choose one asset when several assets match to the platform. Formats not in the list are less preferred.
+	CompressionPreference []string
+	// PreferRawAsset prefers an uncompressed binary to any compressed asset to skip uncompression when both exist
+	// such as 'foo_linux_amd64' and 'foo_linux_amd64.tar.gz'. It precedes CompressionPreference. By default an
+	// uncompressed binary is the least preferred.
+	PreferRawAsset bool
+	// DownloadRetries is the number of retries when downloading an asset failed with a transient error such as
+	// a network error, 5xx response or partial body. The wait between retries starts with DownloadRetryBackoff
+	// and doubles on each retry. Zero (the default) means an asset is downloaded only once.
+	DownloadRetries int
+	// DownloadRetryBackoff is the wait before the first retry of downloading an asset. One second by default.
+	DownloadRetryBackoff time.Duration
+	// DownloadTimeout is the timeout of downloading and validating a release asset. It is applied with a child
+	// context of the context given to UpdateTo, UpdateCommand and so on, so it only limits the download and the
+	// deadline of the parent context is still respected. Detection can be kept snappy by calling DetectLatest with a
+	// short deadline and UpdateTo with a context without deadline. When it is 0, only the parent context is used.
+	DownloadTimeout time.Duration
+	// OS is the OS name of assets to detect such as "darwin". When it is empty, runtime.GOOS is used.
+	// It only affects detection. Updating the binary with an asset for another platform is not supported.
+	OS string
+	// Arch is the arch name of assets to detect such as "arm64". When it is empty, runtime.GOARCH is used.
+	// It only affects detection in the same way as OS.
+	Arch string
+	// LibcVariant is the C library variant of assets to detect on linux such as "musl" or "gnu". Assets qualified
+	// with it such as 'foo_linux_amd64_musl' are preferred and the unqualified name follows as a fallback. When it
+	// is "auto", "musl" is used on a system with /etc/alpine-release or a musl dynamic loader and "gnu" is used
+	// otherwise. It is ignored for other OSes. The detection is best-effort: when no qualified asset exists, the
+	// unqualified one is used even if it was not built for the libc.
+	LibcVariant string
+	// Logger receives logging messages of the updater. When it is nil, messages are output to the logger of this
+	// package, which discards them unless EnableLog is called. Note that UncompressCommand always uses the logger
+	// of this package.
+	Logger Logger
+	// AssetNameTemplate is a text/template to render the exact name of the asset for each release such as
+	// "myapp-{{.OS}}-{{.Arch}}-{{.Version}}.tar.gz". See AssetNameTemplateData for available fields. When it is
+	// set, it is used instead of matching suffixes of asset names such as "_linux_amd64.tar.gz".
+	AssetNameTemplate string
+	// AllowNonSemverTags enables to detect releases whose tags are not adopting semver such as calendar versions.
+	// Such tags are coerced into semver by padding missing components ('2024.06' -> '2024.6.0', 'v3' -> '3.0.0').
+	// Tags which cannot be coerced are still skipped. By default, tags not adopting semver are skipped.
+	AllowNonSemverTags bool
+	// Concurrency is the maximum number of detections run in parallel by DetectLatestMulti. When it is zero or
+	// less, 4 is used.
+	Concurrency int
+	// Source is the service to fetch releases and their assets from. When it is nil, GitHub Releases API is used
+	// with the settings above such as APIToken and EnterpriseBaseURL.
+	Source ReleaseSource
+	// MinVersion is the lowest version which is a candidate of detection. A plain version such as '1.5.0' is an
+	// inclusive bound. A range such as '>1.5.0' or '2.x' is also accepted. When it is empty, there is no floor.
+	MinVersion string
+	// MaxVersion is the highest version which is a candidate of detection (e.g. '<3.0.0' to stay on the 2.x
+	// series). It accepts the same formats as MinVersion. When it is empty, there is no ceiling.
+	MaxVersion string
+	// MinAssetSize is the minimum size of an asset in bytes. Assets smaller than it are skipped on detection as
+	// broken uploads. Assets whose size is zero are always skipped.
+	MinAssetSize int
+	// PreservePermissions makes an update keep the mode bits (including setuid and setgid) and the ownership of the
+	// previous binary. When changing the ownership is not permitted, it is logged and the update still succeeds.
+	PreservePermissions bool
+	// Channel restricts detection to the releases of the channel distinguished by the first pre-release identifier
+	// of their versions. For example, "beta" selects '1.2.0-beta.1' and "nightly" selects '1.2.0-nightly.20240601'.
+	// "stable" selects versions without pre-release identifiers. When a channel other than "stable" is set, releases
+	// marked as pre-release on GitHub are candidates even if Prerelease is false. When it is empty, releases are not
+	// filtered by channel.
+	Channel string
+	// AllowPackageAssets makes system packages such as 'myapp_linux_amd64.deb' and 'myapp_linux_amd64.rpm'
+	// candidates of detection. They are less preferred than archives and binaries. Since they must be installed
+	// with a package manager, updating to such a release fails with ErrPackageAsset. It is useful to notify users
+	// of the new version with its download URL.
+	AllowPackageAssets bool
+	// UseLatestEndpoint makes DetectLatest fetch only the latest release with the "latest release" endpoint of
+	// GitHub Releases API instead of listing all releases. It costs only one API call. It is ignored when
+	// pre-releases are candidates (Prerelease or a pre-release Channel), TagPrefix, ReleaseFilter or Source is set.
+	UseLatestEndpoint bool
+	// ReportNoMatchingAsset makes DetectLatest, DetectVersion and DetectVersions return *NoMatchingAssetError
+	// (ErrNoMatchingAsset) when releases exist but none of them has an asset for the OS and arch, so that it can be
+	// told from a repository without any release. By default, they report it as no release found (found=false and
+	// no error) and the releases are only listed in SkippedReleases with reason "no asset". UpdateCommand and
+	// UpdateSelf regard it as up-to-date regardless of this option.
+	ReportNoMatchingAsset bool
+	// VerifyBinaryFormat makes an update check the header of the downloaded executable before replacing the current
+	// binary. The executable must be ELF, Mach-O or PE for the target OS and built for the target arch. It catches
+	// packaging mistakes such as an archive containing a README instead of the binary.
+	VerifyBinaryFormat bool
+	// SymlinkStrategy is how to update a command whose path is a symbolic link. See SymlinkStrategy for available
+	// strategies. When it is empty, SymlinkReplaceTarget is used.
+	SymlinkStrategy SymlinkStrategy
+	// AllowedFormats restricts the formats of assets to detect and uncompress such as
+	// []AssetFormat{FormatTarGz}. Assets in other formats are skipped on detection and refused on update even
+	// if they would be selected otherwise. Use FormatRaw to allow an uncompressed binary. When it is empty, all
+	// supported formats are allowed.
+	AllowedFormats []AssetFormat
+	// AllowDrafts makes a draft release a candidate when its tag is specified explicitly such as DetectVersion with
+	// the tag. It is useful to test a release in progress. Since GitHub only returns drafts to users who have push
+	// access to the repository, APIToken with the access is necessary. Its assets are downloaded via the
+	// authenticated asset endpoint. Drafts are never detected as the latest release.
+	AllowDrafts bool
+	// AllowDowngrade makes UpdateCommand, UpdateSelf and UpdateCommands install the latest release even when its
+	// version is older than the current version, such as after a release was deleted. By default, they refuse it
+	// with an error wrapping ErrDowngrade to prevent accidental rollbacks by a misconfigured channel or a stale
+	// mirror. UpdateTo never checks the current version, so it can be used for a deliberate downgrade.
+	AllowDowngrade bool
+	// TagPrefix scopes detection to the releases whose tags start with the prefix such as "myservice/". It is useful
+	// for a monorepo tagging each component like 'myservice/v1.4.2'. The prefix is stripped before extracting the
+	// version. When it is empty, all releases are candidates.
+	TagPrefix string
+	// CommandName is the name of the command in release assets such as "kubectl". It decides the prefix of the asset
+	// names (e.g. 'kubectl_linux_amd64.tar.gz') and the executable looked up in an archive instead of the file name
+	// of the binary, so the command can be installed with another name such as 'k'. It is ignored by UpdateCommands.
+	// When it is empty, any asset for the platform is detected and the executable is looked up by the file name.
+	CommandName string
+	// ManifestAssetName is the name of the manifest asset such as "manifest.json" listing the exact asset name and its
+	// SHA256 checksum for each platform. When a release has the manifest, the asset listed for the target OS and arch
+	// is selected instead of guessing it by name and it is validated with the checksum unless Validator or Validators
+	// is set. A release whose manifest has no entry for the platform has no asset. Releases without the manifest are
+	// detected by name as usual. Note that the manifest is downloaded for each candidate release on detection. The
+	// format is:
+	//
+	//	{"assets": [{"os": "linux", "arch": "amd64", "name": "myapp-linux-x64.tgz", "sha256": "3a5f..."}]}
+	ManifestAssetName string
+	// KeepBackups is the number of previous binaries retained for rollback. The newest one is kept as '.<cmd>.old'
+	// and older ones as '.<cmd>.old.1', '.<cmd>.old.2' and so on. When it is 1 or less, only '.<cmd>.old' is kept
+	// and replaced on each update. Updater.CleanupBackups removes them explicitly.
+	KeepBackups int
+	// BackupDir is the directory where the previous binaries are kept instead of the directory of the binary. It is
+	// created when it does not exist and decides where RollbackUpdate, CanRollback and CleanupBackups look for the
+	// backups. DefaultBackupDir returns the directory following the platform conventions. When it is on another
+	// filesystem than the binary, the previous binary is copied instead of moved by atomic rename.
+	BackupDir string
+	// DisableSync disables flushing the new binary and its directory to disk with fsync on updates. By default they
+	// are synced so that a power loss right after an update never leaves a zero-length or partially written binary.
+	// Syncing a directory is skipped on platforms which do not support it such as Windows.
+	DisableSync bool
+	// CheckCachePath is the path to a small JSON file recording the time of the last check and the latest release
+	// found by DetectLatest for each repository. Updater.ShouldCheck reads it to decide whether to check updates of
+	// a repository again.
+	CheckCachePath string
+	// CheckInterval makes DetectLatest return the release recorded in the file at CheckCachePath without calling
+	// GitHub API when the same repository was checked within the interval. It is ignored when CheckCachePath is
+	// not set.
+	CheckInterval time.Duration
+	// MaxPollInterval caps the interval returned from Updater.NextPollAfter. Since the interval is chosen from the
+	// range growing with the previous interval, it drifts up to the cap over time. When it is zero, ten times the base
+	// interval given to Updater.NextPollAfter is used.
+	MaxPollInterval time.Duration
+	// StrictTagParsing requires a tag to be a clean semantic version with an optional leading 'v' such as 'v1.2.3'.
+	// By default, a version is carved out of the tag by stripping any prefix before the version number, so a noisy
+	// tag like 'build20.1.3-final' is regarded as '20.1.3-final'. With this option, such tags are skipped instead.
+	// TagPrefix and a path prefix of the tag such as 'myservice/' are still stripped. AllowNonSemverTags is ignored.
+	StrictTagParsing bool
+	// ResumableDownloads makes downloading an asset resumable. The body is written to a '.partial' file in a directory
+	// only accessible by the current user in the user cache directory and a retry (see DownloadRetries) or the next
+	// update resumes the download from its size with a Range request. The request has an If-Range header so that an
+	// asset changed on the server is downloaded from the beginning. When the server does not support ranges or cannot
+	// satisfy the range, the download restarts from the beginning. It is only available for GitHub Releases.
+	ResumableDownloads bool
+	// RequestsPerHour caps the number of GitHub API requests made by the updater per hour. The requests are limited
+	// with a token bucket which allows RequestsPerHour requests at once and is refilled evenly over an hour. When the
+	// budget is exhausted, the request waits for the next token until the context is cancelled. Downloads from
+	// browser_download_url are not counted. When it is 0, requests are not limited.
+	RequestsPerHour int
+	// ReleaseFilter is called for each detected release after its asset was found. When it returns false, the release
+	// is dropped such as a yanked version which cannot be deleted from GitHub. Unlike Filters, it can decide with all
+	// fields of the release such as Version, Name and PublishedAt. Releases dropped by it are reported by
+	// SkippedReleases. When it is nil, no release is dropped.
+	ReleaseFilter func(*Release) bool
+	// AssetURLRewriter rewrites the URL of a release asset (Release.AssetURL) to download it from a mirror such as
+	// an internal CDN. It is only applied to downloading the asset. Detection and the validation asset still use
+	// the release source so that the content from the mirror is validated with Validator as usual. When it returns
+	// an empty string, the asset is downloaded from the release source.
+	AssetURLRewriter func(assetURL string) string
+	// PostDownloadHook is called with the path to the uncompressed new binary after it was validated and before it
+	// replaces the current binary. It is useful for platform-specific fixups such as re-signing the binary on macOS.
+	// When it returns an error, the update is aborted with StageValidation and the current binary is not modified.
+	// Note that the content of the file is copied on replacement. Changes to the content are kept, but file
+	// attributes such as extended attributes and capabilities are only kept with SymlinkVersioned.
+	PostDownloadHook func(tempPath string) error
+	// AllowSplitAssets enables detection of an asset split into numbered parts such as 'foo_linux_amd64.tar.gz.001',
+	// 'foo_linux_amd64.tar.gz.002', ... The parts are downloaded in order and concatenated into the whole asset before
+	// validation and uncompression. The validation file is for the whole asset such as 'foo_linux_amd64.tar.gz.sha256'.
+	// A series missing one of its parts is not detected.
+	AllowSplitAssets bool
+	// AllowContentTypeFallback enables detection of an asset by its content type reported by GitHub as the last
+	// resort for irregular file names. When no asset name matches the naming rules, the asset whose content type is
+	// a supported archive or compression format (e.g. "application/zip") and whose name contains the OS name and the
+	// arch name as words (e.g. 'foo-Linux-amd64-release') is detected. It is only detected when exactly one asset
+	// matches. Release.Format is the format of the content type.
+	AllowContentTypeFallback bool
+	// Decompressors maps file extensions such as ".enc" to decompressors of proprietary formats. Assets with the
+	// extensions are detected in the same way as the built-in formats (e.g. 'foo_linux_amd64.enc') and a decompressor
+	// takes precedence over the built-in uncompression when its extension matches. The format of such an asset is the
+	// extension without the leading dot (e.g. AssetFormat("enc")) unless it is a built-in one.
+	Decompressors map[string]DecompressorFunc
+	// ArchiveDepth is the number of nested layers of archives and compressions uncompressed to find the executable,
+	// such as 2 for a '.zip' archive containing a '.tar.gz' archive. When it is zero or less, DefaultArchiveDepth is
+	// used.
+	ArchiveDepth int
+	// Progress is called while downloading a release asset to report how many bytes were downloaded.
+	// It is optional. When it is nil, progress is not reported.
+	Progress ProgressFunc
+	// VersionExtractor extracts a semantic version from a Git tag name. It is useful when tags don't follow
+	// the default naming rule (an optional prefix followed by '\d+\.\d+\.\d+'). When it is nil, the default
+	// extraction is used.
+	VersionExtractor VersionExtractor
+	// HTTPClient is an HTTP client used for calling GitHub API and downloading release assets. It is useful
+	// to configure a proxy or TLS settings such as a custom CA bundle. When APIToken is set, the token is
+	// added to API requests made with this client. When it is nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// PinnedCertFingerprints is the list of SHA-256 fingerprints of certificates in hex such as "3a5f..." (colons
+	// between bytes are allowed). When it is not empty, downloading a release asset fails with an error wrapping
+	// ErrCertificateNotPinned unless the leaf certificate or a certificate in the verified chain of the download host
+	// is in the list. Note that assets on GitHub are downloaded from the host redirected from the API. The standard
+	// verification of certificates is still applied. The transport of HTTPClient must be *http.Transport.
+	PinnedCertFingerprints []string
+	// PinAPICertificates applies PinnedCertFingerprints also to GitHub API calls.
+	PinAPICertificates bool
+	// Prerelease makes releases marked as pre-release on GitHub candidates of detection (e.g. for a beta channel).
+	// Versions are compared with semantic versioning so '1.2.0-rc.1' is older than '1.2.0'. Drafts are
+	// excluded regardless of this option (see AllowDrafts).
+	Prerelease bool
+	// CacheReleases enables an in-memory cache of releases fetched from GitHub API. Releases are fetched with
+	// a conditional request using the ETag of the previous response and the cached releases are reused when
+	// they are not modified. It is useful to avoid exhausting the API rate limit when polling updates.
+	CacheReleases bool
+	// MaxReleasePages is the maximum number of pages fetched from GitHub Releases API on detecting releases.
+	// One page contains up to 100 releases. When it is zero or less, 10 is used.
+	MaxReleasePages int
+	// VersionCommand is the arguments to run the updated binary for reporting its version (e.g. []string{"--version"}).
+	// When it is set, the version in the output is verified against the version of the release after an update.
+	// When they don't match, the previous binary is restored. When it is empty, the verification is skipped.
+	VersionCommand []string
 }
 
-func newHTTPClient(ctx context.Context, token string) *http.Client {
+func newHTTPClient(ctx context.Context, token string, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
 	if token == "" {
-		return http.DefaultClient
+		return base
 	}
 	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	// oauth2 client wraps the transport of the HTTP client stored in the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, base)
 	return oauth2.NewClient(ctx, src)
 }
 
-// NewUpdater creates a new updater instance. It initializes GitHub API client.
-// If you set your API token to $GITHUB_TOKEN, the client will use it.
-func NewUpdater(ctx context.Context, config Config) (*Updater, error) {
-	token := config.APIToken
-	if token == "" {
-		token = os.Getenv("GITHUB_TOKEN")
+// limitedTransport is a HTTP transport which waits for a token of the rate limiter before sending each request.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, fmt.Errorf("Failed to wait for the budget of API requests: %w", err)
 	}
-	if token == "" {
-		token, _ = gitconfig.GithubToken()
+	return t.base.RoundTrip(req)
+}
+
+// newLimitedHTTPClient returns a copy of the HTTP client which sends at most perHour requests per hour.
+func newLimitedHTTPClient(c *http.Client, perHour int) *http.Client {
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limited := *c
+	limited.Transport = &limitedTransport{
+		base:    base,
+		limiter: rate.NewLimiter(rate.Every(time.Hour/time.Duration(perHour)), perHour),
 	}
-	hc := newHTTPClient(ctx, token)
+	return &limited
+}
 
+// defaultToken looks up the API token from the environment. $GITHUB_TOKEN is preferred to $GH_TOKEN (the same
+// convention as gh command), and the '[github] token' of gitconfig is used when neither is set.
+func defaultToken() string {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	token, _ := gitconfig.GithubToken()
+	return token
+}
+
+// Option configures an updater created by NewUpdaterFromClient.
+type Option func(up *Updater)
+
+// WithConfig returns an option which applies the configuration to the updater. Since the GitHub API client is given
+// to NewUpdaterFromClient, APIToken, DisableEnvToken, EnterpriseBaseURL, EnterpriseUploadURL, RequestsPerHour and
+// PinAPICertificates are ignored and HTTPClient is only used for downloading release assets. When Filters,
+// AssetNameTemplate, MinVersion, MaxVersion, PinnedCertFingerprints or Decompressors is invalid, NewUpdaterFromClient
+// returns the error.
+func WithConfig(config Config) Option {
+	opt, err := configOption(config)
+	if err != nil {
+		return func(up *Updater) {
+			if up.optionErr == nil {
+				up.optionErr = err
+			}
+		}
+	}
+	return opt
+}
+
+// configOption parses the configuration and returns an option to apply it.
+func configOption(config Config) (Option, error) {
 	filtersRe := make([]*regexp.Regexp, 0, len(config.Filters))
 	for _, filter := range config.Filters {
 		re, err := regexp.Compile(filter)
@@ -67,9 +452,149 @@ func NewUpdater(ctx context.Context, config Config) (*Updater, error) {
 		filtersRe = append(filtersRe, re)
 	}
 
+	var tmpl *template.Template
+	if config.AssetNameTemplate != "" {
+		t, err := template.New("asset").Parse(config.AssetNameTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse asset name template %q: %v", config.AssetNameTemplate, err)
+		}
+		tmpl = t
+	}
+
+	vr, err := parseVersionRange(config.MinVersion, config.MaxVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := configDownloadClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressors, err := normalizeDecompressors(config.Decompressors)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(up *Updater) {
+		up.validator = config.Validator
+		up.validators = config.Validators
+		up.filters = filtersRe
+		up.progress = config.Progress
+		up.versionExtractor = config.VersionExtractor
+		up.prerelease = config.Prerelease
+		up.maxReleasePages = config.MaxReleasePages
+		up.versionCommand = config.VersionCommand
+		up.filterMode = config.FilterMode
+		up.compressionPreference = config.CompressionPreference
+		up.preferRawAsset = config.PreferRawAsset
+		up.downloadRetries = config.DownloadRetries
+		up.downloadRetryBackoff = config.DownloadRetryBackoff
+		up.downloadTimeout = config.DownloadTimeout
+		up.os = config.OS
+		up.arch = config.Arch
+		up.libcVariant = config.LibcVariant
+		up.logger = config.Logger
+		up.assetTemplate = tmpl
+		up.allowNonSemverTags = config.AllowNonSemverTags
+		up.concurrency = config.Concurrency
+		up.source = config.Source
+		up.minVersion = config.MinVersion
+		up.maxVersion = config.MaxVersion
+		up.versionRange = vr
+		up.minAssetSize = config.MinAssetSize
+		up.preservePermissions = config.PreservePermissions
+		up.channel = config.Channel
+		up.allowPackageAssets = config.AllowPackageAssets
+		up.latestEndpoint = config.UseLatestEndpoint
+		up.reportNoMatchingAsset = config.ReportNoMatchingAsset
+		up.verifyBinaryFormat = config.VerifyBinaryFormat
+		up.symlinkStrategy = config.SymlinkStrategy
+		up.allowedFormats = config.AllowedFormats
+		up.allowDrafts = config.AllowDrafts
+		up.allowDowngrade = config.AllowDowngrade
+		up.tagPrefix = config.TagPrefix
+		up.commandName = config.CommandName
+		up.manifestAssetName = config.ManifestAssetName
+		up.keepBackups = config.KeepBackups
+		up.backupDir = config.BackupDir
+		up.disableSync = config.DisableSync
+		up.checkCachePath = config.CheckCachePath
+		up.checkInterval = config.CheckInterval
+		up.maxPollInterval = config.MaxPollInterval
+		up.strictTagParsing = config.StrictTagParsing
+		up.resumableDownloads = config.ResumableDownloads
+		up.releaseFilter = config.ReleaseFilter
+		up.assetURLRewriter = config.AssetURLRewriter
+		up.postDownloadHook = config.PostDownloadHook
+		up.allowSplitAssets = config.AllowSplitAssets
+		up.contentTypeFallback = config.AllowContentTypeFallback
+		up.decompressors = decompressors
+		up.archiveDepth = config.ArchiveDepth
+		if dc != nil {
+			up.downloadClient = dc
+		}
+		if config.CacheReleases {
+			up.cache = newReleaseCache()
+		}
+	}, nil
+}
+
+// configDownloadClient returns the HTTP client for downloading release assets. It is nil when http.DefaultClient
+// should be used.
+func configDownloadClient(config Config) (*http.Client, error) {
+	if len(config.PinnedCertFingerprints) == 0 {
+		return config.HTTPClient, nil
+	}
+	pins, err := parseFingerprints(config.PinnedCertFingerprints)
+	if err != nil {
+		return nil, err
+	}
+	return newPinnedHTTPClient(config.HTTPClient, pins)
+}
+
+// NewUpdaterFromClient creates a new updater instance which calls GitHub API with the given client. It is useful
+// when the client is already built and configured by the application (e.g. with its own middleware, metrics or
+// Enterprise URLs). The options such as WithConfig are applied in order. Release assets are downloaded with
+// http.DefaultClient unless Config.HTTPClient is given via WithConfig. It returns the first error of the options.
+func NewUpdaterFromClient(client *github.Client, opts ...Option) (*Updater, error) {
+	up := &Updater{api: client, downloadClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(up)
+	}
+	if up.optionErr != nil {
+		return nil, up.optionErr
+	}
+	return up, nil
+}
+
+// NewUpdater creates a new updater instance. It initializes GitHub API client.
+// When Config.APIToken is empty, the token is looked up from $GITHUB_TOKEN, $GH_TOKEN and gitconfig in this order
+// unless Config.DisableEnvToken is set.
+func NewUpdater(ctx context.Context, config Config) (*Updater, error) {
+	opt, err := configOption(config)
+	if err != nil {
+		return nil, err
+	}
+
+	token := config.APIToken
+	if token == "" && !config.DisableEnvToken {
+		token = defaultToken()
+	}
+	base := config.HTTPClient
+	if config.PinAPICertificates {
+		base, err = configDownloadClient(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hc := newHTTPClient(ctx, token, base)
+	if config.RequestsPerHour > 0 {
+		hc = newLimitedHTTPClient(hc, config.RequestsPerHour)
+	}
+
 	if config.EnterpriseBaseURL == "" {
-		client := github.NewClient(hc)
-		return &Updater{api: client, validator: config.Validator, filters: filtersRe}, nil
+		return NewUpdaterFromClient(github.NewClient(hc), opt)
 	}
 
 	u := config.EnterpriseUploadURL
@@ -80,17 +605,13 @@ func NewUpdater(ctx context.Context, config Config) (*Updater, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Updater{api: client, validator: config.Validator, filters: filtersRe}, nil
+	return NewUpdaterFromClient(client, opt)
 }
 
 // DefaultUpdater creates a new updater instance with default configuration.
 // It initializes GitHub API client with default API base URL.
-// If you set your API token to $GITHUB_TOKEN, the client will use it.
+// The API token is looked up from $GITHUB_TOKEN, $GH_TOKEN and gitconfig in this order.
 func DefaultUpdater(ctx context.Context) *Updater {
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		token, _ = gitconfig.GithubToken()
-	}
-	client := newHTTPClient(ctx, token)
-	return &Updater{api: github.NewClient(client)}
+	client := newHTTPClient(ctx, defaultToken(), nil)
+	return &Updater{api: github.NewClient(client), downloadClient: http.DefaultClient}
 }
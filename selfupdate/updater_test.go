@@ -2,9 +2,15 @@ package selfupdate
 
 import (
 	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-github/v30/github"
 )
 
 func TestGitHubTokenEnv(t *testing.T) {
@@ -123,3 +129,201 @@ func TestFilterRegexIsBroken(t *testing.T) {
 		t.Fatalf("Error message is unexpected: %q", msg)
 	}
 }
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return &http.Response{
+		StatusCode: 404,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"message": "Not Found"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestCustomHTTPClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, token := range []string{"", "hogehoge"} {
+		tr := &recordingTransport{}
+		hc := &http.Client{Transport: tr}
+		up, err := NewUpdater(ctx, Config{APIToken: token, HTTPClient: hc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if up.downloadClient != hc {
+			t.Error("Custom HTTP client should be used for downloading assets")
+		}
+
+		if _, _, err := up.api.Repositories.ListReleases(ctx, "foo", "bar", nil); err == nil {
+			t.Fatal("Error should be returned for 404 response")
+		}
+		if len(tr.requests) != 1 {
+			t.Fatal("API should be called via custom HTTP client but recorded", len(tr.requests), "requests")
+		}
+		auth := tr.requests[0].Header.Get("Authorization")
+		if token != "" && auth != "Bearer "+token {
+			t.Error("Token should be added to API requests but got", auth)
+		}
+	}
+}
+
+func TestGitHubTokenFromEnvironment(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if v, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, v)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		what       string
+		githubTok  string
+		ghTok      string
+		config     Config
+		wantHeader string
+	}{
+		{"GITHUB_TOKEN", "from-github-token", "", Config{}, "Bearer from-github-token"},
+		{"GH_TOKEN", "", "from-gh-token", Config{}, "Bearer from-gh-token"},
+		{"GITHUB_TOKEN precedes GH_TOKEN", "from-github-token", "from-gh-token", Config{}, "Bearer from-github-token"},
+		{"APIToken precedes environment", "from-github-token", "from-gh-token", Config{APIToken: "explicit"}, "Bearer explicit"},
+		{"DisableEnvToken", "from-github-token", "from-gh-token", Config{DisableEnvToken: true}, ""},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			os.Setenv("GITHUB_TOKEN", tc.githubTok)
+			os.Setenv("GH_TOKEN", tc.ghTok)
+			auth = ""
+
+			tc.config.EnterpriseBaseURL = ts.URL
+			up, err := NewUpdater(ctx, tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := up.DetectLatest(ctx, "foo/bar"); err != nil {
+				t.Fatal(err)
+			}
+			if auth != tc.wantHeader {
+				t.Fatalf("Authorization header is %q, want %q", auth, tc.wantHeader)
+			}
+		})
+	}
+}
+
+func TestRequestsPerHour(t *testing.T) {
+	ctx := context.Background()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	up, err := NewUpdater(ctx, Config{APIToken: "hogehoge", EnterpriseBaseURL: ts.URL, RequestsPerHour: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := up.DetectLatest(ctx, "foo/bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, _, err = up.DetectLatest(ctx, "foo/bar")
+	if err == nil {
+		t.Fatal("Error should occur when the budget of API requests is exhausted")
+	}
+	if !strings.Contains(err.Error(), "budget of API requests") {
+		t.Fatal("Unexpected error:", err)
+	}
+	if requests != 1 {
+		t.Fatal("Request exceeding the budget should not be sent but server received", requests, "requests")
+	}
+}
+
+type headerTransport struct {
+	header string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Test", t.header)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewUpdaterFromClient(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "instrumented" {
+			t.Errorf("Request %s was not sent with the given client", r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"tag_name": "v1.2.3", "assets": [{"id": 1, "name": "bar_linux_amd64.zip"}]},
+			{"tag_name": "v2.0.0-beta", "prerelease": true, "assets": [{"id": 2, "name": "bar_linux_amd64.zip"}]}
+		]`))
+	}))
+	defer ts.Close()
+
+	client, err := github.NewEnterpriseClient(ts.URL, ts.URL, &http.Client{Transport: &headerTransport{"instrumented"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, err := NewUpdaterFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.api != client {
+		t.Fatal("Given client should be used")
+	}
+	if up.downloadClient != http.DefaultClient {
+		t.Fatal("Default HTTP client should be used for downloading assets")
+	}
+
+	hc := &http.Client{}
+	up, err = NewUpdaterFromClient(client, WithConfig(Config{HTTPClient: hc, OS: "linux", Arch: "amd64", Prerelease: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.downloadClient != hc {
+		t.Fatal("Config.HTTPClient should be used for downloading assets")
+	}
+	latest, found, err := up.DetectLatest(ctx, "foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("Release should be detected")
+	}
+	if latest.Version.String() != "2.0.0-beta" {
+		t.Fatal("Pre-release should be detected with the configuration:", latest.Version)
+	}
+}
+
+func TestWithConfigInvalidFilter(t *testing.T) {
+	up, err := NewUpdaterFromClient(github.NewClient(nil), WithConfig(Config{Filters: []string{"(foo"}}))
+	if err == nil {
+		t.Fatal("NewUpdaterFromClient should return an error with invalid filter")
+	}
+	if up != nil {
+		t.Fatal("Updater should not be returned on error:", up)
+	}
+	if !strings.Contains(err.Error(), "(foo") {
+		t.Error("Error should mention the invalid filter:", err)
+	}
+}
@@ -1,11 +1,21 @@
 package selfupdate
 
 import (
+	"bufio"
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/sha256"
+	_ "crypto/sha512" // Register SHA384 and SHA512 for HashValidator
 	"encoding/asn1"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	_ "golang.org/x/crypto/blake2b" // Register BLAKE2b for HashValidator
 )
 
 // Validator represents an interface which enables additional validation of releases.
@@ -18,27 +28,250 @@ type Validator interface {
 	Suffix() string
 }
 
+// AssetNameValidator represents a validator which validates all assets of a release against one
+// validation file shared by them, such as 'checksums.txt'. For this kind of validator, Suffix returns
+// the whole file name of the shared validation asset instead of a suffix of the release asset name.
+type AssetNameValidator interface {
+	Validator
+	// ValidateAsset validates release bytes of the asset named 'name' against an additional asset bytes.
+	ValidateAsset(name string, release, asset []byte) error
+}
+
+// SuffixesValidator represents a validator which accepts several spellings of the suffix of its validation file
+// such as '.sha256' and '.sha256sum'. The validation file is looked up with each suffix in order.
+type SuffixesValidator interface {
+	Validator
+	// Suffixes returns the accepted suffixes in order of preference. The first one should be the same as Suffix.
+	Suffixes() []string
+}
+
+// ValidationError is returned when a release asset cannot be validated with its validation asset, such as a hash
+// mismatch, a missing line in a checksums file or a broken signature. Every validation failure of the validators in
+// this package is returned as this type so that callers can distinguish a corrupted download from other failures.
+type ValidationError struct {
+	// Validator is the name of the validation such as "sha2", "sha512", "checksum", "ecdsa" or "pgp".
+	Validator string
+	// AssetName is the name of the validated release asset. It is empty when the name is unknown.
+	AssetName string
+	// Expected is the hash written in the validation asset. It is empty for signature validation.
+	Expected string
+	// Actual is the hash of the downloaded release asset. It is SHA256 unless HashValidator is used.
+	Actual string
+	// Err is the reason why the validation failed other than hash mismatch, such as a failed signature verification
+	// or a hash missing from a checksums file. It is nil for hash mismatch.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	name := ""
+	if e.AssetName != "" {
+		name = fmt.Sprintf(" for %q", e.AssetName)
+	}
+	if e.Err != nil {
+		if e.Actual == "" {
+			return fmt.Sprintf("%s: validation failed%s: %v", e.Validator, name, e.Err)
+		}
+		return fmt.Sprintf("%s: validation failed%s (sha256=%s): %v", e.Validator, name, e.Actual, e.Err)
+	}
+	return fmt.Sprintf("%s: validation failed%s: hash mismatch: expected=%q, got=%q", e.Validator, name, e.Expected, e.Actual)
+}
+
+// Unwrap returns the reason of the validation failure other than hash mismatch.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validationAssetName returns the name of the validation asset for the release asset named 'name'.
+func validationAssetName(v Validator, name string) string {
+	if _, ok := v.(AssetNameValidator); ok {
+		return v.Suffix()
+	}
+	return name + v.Suffix()
+}
+
+// validatorSuffixes returns the suffixes of the validation asset accepted by the validator in order of preference.
+func validatorSuffixes(v Validator) []string {
+	if sv, ok := v.(SuffixesValidator); ok {
+		if suffixes := sv.Suffixes(); len(suffixes) > 0 {
+			return suffixes
+		}
+	}
+	return []string{v.Suffix()}
+}
+
+// validationAssetNames returns the candidate names of the validation asset for the release asset named 'name' in
+// order of preference.
+func validationAssetNames(v Validator, name string) []string {
+	if _, ok := v.(AssetNameValidator); ok {
+		return []string{v.Suffix()}
+	}
+	suffixes := validatorSuffixes(v)
+	names := make([]string, 0, len(suffixes))
+	for _, s := range suffixes {
+		names = append(names, name+s)
+	}
+	return names
+}
+
+// hashSuffixes returns the common spellings of the suffix of the validation file for the hash name such as '.sha256',
+// '.sha256sum' and '.sha-256'.
+func hashSuffixes(name string) []string {
+	suffixes := []string{"." + name, "." + name + "sum"}
+	if n := strings.TrimPrefix(name, "sha"); n != name {
+		suffixes = append(suffixes, ".sha-"+n)
+	}
+	return suffixes
+}
+
+// validateAsset validates release bytes with the validator. When the validator can make use of the
+// release asset name, it is passed to the validator.
+func validateAsset(v Validator, name string, release, asset []byte) error {
+	var err error
+	if nv, ok := v.(AssetNameValidator); ok && name != "" {
+		err = nv.ValidateAsset(name, release, asset)
+	} else {
+		err = v.Validate(release, asset)
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) && verr.AssetName == "" {
+		verr.AssetName = name
+	}
+	return err
+}
+
+func sha256Hex(b []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// ComputeHash computes the hash sum of the content read from r with the hash function such as crypto.SHA512 and
+// returns it as a lowercase hex string. It is the same format as the hash compared by the validators in this package
+// (the first field of the output of sha256sum, sha512sum or b2sum), so it can be used to verify a download
+// out-of-band or to generate validation files.
+func ComputeHash(r io.Reader, hash crypto.Hash) (string, error) {
+	if !hash.Available() {
+		return "", fmt.Errorf("Hash function #%d is not available", hash)
+	}
+	h := hash.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("Failed to read content to compute hash: %s", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ComputeSHA256 computes the SHA256 sum of the content read from r in the format SHA2Validator compares with.
+func ComputeSHA256(r io.Reader) (string, error) {
+	return ComputeHash(r, crypto.SHA256)
+}
+
+// ComputeSHA512 computes the SHA512 sum of the content read from r in the format HashValidator compares with.
+func ComputeSHA512(r io.Reader) (string, error) {
+	return ComputeHash(r, crypto.SHA512)
+}
+
 // SHA2Validator specifies a SHA256 validator for additional file validation
 // before updating.
 type SHA2Validator struct {
+	// FileSuffixes is the accepted suffixes of the additional asset file in order of preference. When it is empty,
+	// ".sha256", ".sha256sum" and ".sha-256" are accepted.
+	FileSuffixes []string
 }
 
 // Validate validates the SHA256 sum of the release against the contents of an
 // additional asset file.
+// The hash may be followed by a file name as generated by sha256sum.
 func (v *SHA2Validator) Validate(release, asset []byte) error {
-	calculatedHash := fmt.Sprintf("%x", sha256.Sum256(release))
-	hash := fmt.Sprintf("%s", asset[:sha256.BlockSize])
+	calculatedHash := sha256Hex(release)
+	hash := ""
+	if fields := strings.Fields(string(asset)); len(fields) > 0 {
+		hash = strings.ToLower(fields[0])
+	}
 	if calculatedHash != hash {
-		return fmt.Errorf("sha2: validation failed: hash mismatch: expected=%q, got=%q", calculatedHash, hash)
+		return &ValidationError{Validator: "sha2", Expected: hash, Actual: calculatedHash}
 	}
 	return nil
 }
 
 // Suffix returns the suffix for SHA2 validation.
 func (v *SHA2Validator) Suffix() string {
+	if len(v.FileSuffixes) > 0 {
+		return v.FileSuffixes[0]
+	}
 	return ".sha256"
 }
 
+// Suffixes returns the accepted suffixes for SHA2 validation.
+func (v *SHA2Validator) Suffixes() []string {
+	if len(v.FileSuffixes) > 0 {
+		return v.FileSuffixes
+	}
+	return hashSuffixes("sha256")
+}
+
+// HashValidator specifies a validator which validates the hash sum of the release computed with the hash function
+// such as crypto.SHA512 or crypto.BLAKE2b_512 against the contents of an additional asset file.
+type HashValidator struct {
+	// Hash is the hash function to compute the hash sum of the release. SHA256, SHA512 and BLAKE2b (256, 384 and
+	// 512) are available by default. Other hash functions need to be linked into the binary.
+	Hash crypto.Hash
+	// FileSuffix is the suffix of the additional asset file. When it is empty, the suffix for the hash function is
+	// used such as ".sha512" or ".b2".
+	FileSuffix string
+}
+
+// hashNames is the names of hash functions used for validation errors and default suffixes.
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA256:      "sha256",
+	crypto.SHA384:      "sha384",
+	crypto.SHA512:      "sha512",
+	crypto.BLAKE2b_256: "b2",
+	crypto.BLAKE2b_384: "b2",
+	crypto.BLAKE2b_512: "b2",
+}
+
+func (v *HashValidator) name() string {
+	if n, ok := hashNames[v.Hash]; ok {
+		return n
+	}
+	return "hash"
+}
+
+// Validate validates the hash sum of the release against the contents of an additional asset file.
+// The hash may be followed by a file name as generated by sha512sum or b2sum.
+func (v *HashValidator) Validate(release, asset []byte) error {
+	if !v.Hash.Available() {
+		return &ValidationError{Validator: v.name(), Err: fmt.Errorf("hash function #%d for validation is not available", v.Hash)}
+	}
+	calculatedHash, err := ComputeHash(bytes.NewReader(release), v.Hash)
+	if err != nil {
+		return &ValidationError{Validator: v.name(), Err: err}
+	}
+	hash := ""
+	if fields := strings.Fields(string(asset)); len(fields) > 0 {
+		hash = strings.ToLower(fields[0])
+	}
+	if calculatedHash != hash {
+		return &ValidationError{Validator: v.name(), Expected: hash, Actual: calculatedHash}
+	}
+	return nil
+}
+
+// Suffix returns the suffix for the hash validation.
+func (v *HashValidator) Suffix() string {
+	if v.FileSuffix != "" {
+		return v.FileSuffix
+	}
+	return "." + v.name()
+}
+
+// Suffixes returns the accepted suffixes for the hash validation. When FileSuffix is not set, the common spellings
+// such as ".sha512", ".sha512sum" and ".sha-512" are accepted.
+func (v *HashValidator) Suffixes() []string {
+	if v.FileSuffix != "" {
+		return []string{v.FileSuffix}
+	}
+	return hashSuffixes(v.name())
+}
+
 // ECDSAValidator specifies a ECDSA validator for additional file validation
 // before updating.
 type ECDSAValidator struct {
@@ -57,11 +290,11 @@ func (v *ECDSAValidator) Validate(input, signature []byte) error {
 		S *big.Int
 	}
 	if _, err := asn1.Unmarshal(signature, &rs); err != nil {
-		return fmt.Errorf("failed to unmarshal ecdsa signature: %v", err)
+		return &ValidationError{Validator: "ecdsa", Actual: sha256Hex(input), Err: fmt.Errorf("failed to unmarshal signature: %v", err)}
 	}
 
 	if !ecdsa.Verify(v.PublicKey, h.Sum([]byte{}), rs.R, rs.S) {
-		return fmt.Errorf("ecdsa: signature verification failed")
+		return &ValidationError{Validator: "ecdsa", Actual: sha256Hex(input), Err: errors.New("signature does not match the public key")}
 	}
 
 	return nil
@@ -71,3 +304,125 @@ func (v *ECDSAValidator) Validate(input, signature []byte) error {
 func (v *ECDSAValidator) Suffix() string {
 	return ".sig"
 }
+
+// ChecksumValidator specifies a SHA256 validator which validates a release against one checksums file
+// shared by all assets of the release. Each line of the file has the form '<sha256>  <filename>' as
+// generated by sha256sum or GoReleaser.
+type ChecksumValidator struct {
+	// UniqueFilename is the name of the checksums file. When it is empty, "checksums.txt" is used.
+	UniqueFilename string
+}
+
+// Validate validates the SHA256 sum of the release against any line of the checksums file.
+// ValidateAsset should be preferred since it checks the line for the asset name.
+func (v *ChecksumValidator) Validate(release, asset []byte) error {
+	calculatedHash := sha256Hex(release)
+	sums, err := parseChecksums(asset)
+	if err != nil {
+		return &ValidationError{Validator: "checksum", Actual: calculatedHash, Err: err}
+	}
+	for _, hash := range sums {
+		if hash == calculatedHash {
+			return nil
+		}
+	}
+	return &ValidationError{Validator: "checksum", Actual: calculatedHash, Err: fmt.Errorf("hash is not found in %s", v.Suffix())}
+}
+
+// ValidateAsset validates the SHA256 sum of the release against the line for the asset named 'name'
+// in the checksums file.
+func (v *ChecksumValidator) ValidateAsset(name string, release, asset []byte) error {
+	calculatedHash := sha256Hex(release)
+	sums, err := parseChecksums(asset)
+	if err != nil {
+		return &ValidationError{Validator: "checksum", AssetName: name, Actual: calculatedHash, Err: err}
+	}
+	hash, ok := sums[name]
+	if !ok {
+		return &ValidationError{Validator: "checksum", AssetName: name, Actual: calculatedHash, Err: fmt.Errorf("hash is not found in %s", v.Suffix())}
+	}
+	if calculatedHash != hash {
+		return &ValidationError{Validator: "checksum", AssetName: name, Expected: hash, Actual: calculatedHash}
+	}
+	return nil
+}
+
+// Suffix returns the file name of the checksums file.
+func (v *ChecksumValidator) Suffix() string {
+	if v.UniqueFilename == "" {
+		return "checksums.txt"
+	}
+	return v.UniqueFilename
+}
+
+// parseChecksums parses the content of a checksums file into a map from file names to hashes.
+func parseChecksums(content []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in checksums file: %q", s.Text())
+		}
+		// '*' prefix means the file was read in binary mode by sha256sum
+		name := strings.TrimPrefix(fields[1], "*")
+		sums[name] = strings.ToLower(fields[0])
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksums file: %v", err)
+	}
+	return sums, nil
+}
+
+// PGPValidator specifies a PGP validator which verifies a detached signature of a release. Both armored
+// and binary signatures are supported.
+type PGPValidator struct {
+	// KeyRing is the set of public keys which are trusted to sign releases
+	KeyRing openpgp.EntityList
+	// SignatureSuffix is the suffix of the signature file. When it is empty, ".asc" is used.
+	SignatureSuffix string
+}
+
+// NewPGPValidator creates a PGPValidator from an armored public key or key ring.
+func NewPGPValidator(armoredKeyRing []byte) (*PGPValidator, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armoredKeyRing))
+	if err != nil {
+		return nil, fmt.Errorf("pgp: failed to read armored key ring: %v", err)
+	}
+	return &PGPValidator{KeyRing: keyring}, nil
+}
+
+// Validate verifies the detached PGP signature of the release contained in an additional asset file.
+func (v *PGPValidator) Validate(release, signature []byte) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(v.KeyRing, bytes.NewReader(release), bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(v.KeyRing, bytes.NewReader(release), bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		fingerprints := make([]string, 0, len(v.KeyRing))
+		for _, e := range v.KeyRing {
+			if e.PrimaryKey != nil {
+				fingerprints = append(fingerprints, fmt.Sprintf("%X", e.PrimaryKey.Fingerprint))
+			}
+		}
+		return &ValidationError{
+			Validator: "pgp",
+			Actual:    sha256Hex(release),
+			Err:       fmt.Errorf("key(s) %s: %w", strings.Join(fingerprints, ", "), err),
+		}
+	}
+	return nil
+}
+
+// Suffix returns the suffix for PGP validation.
+func (v *PGPValidator) Suffix() string {
+	if v.SignatureSuffix == "" {
+		return ".asc"
+	}
+	return v.SignatureSuffix
+}
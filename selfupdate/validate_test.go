@@ -1,11 +1,23 @@
 package selfupdate
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 )
 
 func TestSHA2Validator(t *testing.T) {
@@ -39,6 +51,80 @@ func TestSHA2ValidatorFail(t *testing.T) {
 	}
 }
 
+func TestHashValidator(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		hash   crypto.Hash
+		suffix string
+	}{
+		{crypto.SHA256, ".sha256"},
+		{crypto.SHA512, ".sha512"},
+		{crypto.BLAKE2b_512, ".b2"},
+	} {
+		v := &HashValidator{Hash: tc.hash}
+		if s := v.Suffix(); s != tc.suffix {
+			t.Errorf("Wanted suffix %q but got %q", tc.suffix, s)
+		}
+		hashData, err := ioutil.ReadFile("testdata/foo.zip" + tc.suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Validate(data, hashData); err != nil {
+			t.Errorf("Validation with %s failed: %s", tc.suffix, err)
+		}
+		hashData[0] = '0'
+		var verr *ValidationError
+		if err := v.Validate(data, hashData); !errors.As(err, &verr) {
+			t.Errorf("ValidationError should be returned with %s: %v", tc.suffix, err)
+		}
+	}
+
+	v := &HashValidator{Hash: crypto.SHA512, FileSuffix: ".sha512sum"}
+	if s := v.Suffix(); s != ".sha512sum" {
+		t.Error("FileSuffix should be used but got", s)
+	}
+	v = &HashValidator{Hash: crypto.MD4}
+	if err := v.Validate(data, []byte("0")); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Error("Unavailable hash function should cause an error:", err)
+	}
+}
+
+func TestComputeHash(t *testing.T) {
+	for _, tc := range []struct {
+		hash    crypto.Hash
+		suffix  string
+		compute func(io.Reader) (string, error)
+	}{
+		{crypto.SHA256, ".sha256", ComputeSHA256},
+		{crypto.SHA512, ".sha512", ComputeSHA512},
+		{crypto.BLAKE2b_512, ".b2", func(r io.Reader) (string, error) { return ComputeHash(r, crypto.BLAKE2b_512) }},
+	} {
+		f, err := os.Open("testdata/foo.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := tc.compute(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashData, err := ioutil.ReadFile("testdata/foo.zip" + tc.suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Fields(string(hashData))[0]; sum != want {
+			t.Errorf("Hash #%d should be %q but got %q", tc.hash, want, sum)
+		}
+	}
+
+	if _, err := ComputeHash(strings.NewReader("foo"), crypto.MD4); err == nil {
+		t.Error("Error should occur for unavailable hash function")
+	}
+}
+
 func TestECDSAValidator(t *testing.T) {
 	pemData, err := ioutil.ReadFile("testdata/Test.crt")
 	if err != nil {
@@ -126,6 +212,14 @@ func TestValidatorSuffix(t *testing.T) {
 			v:      &ECDSAValidator{},
 			suffix: ".sig",
 		},
+		{
+			v:      &ChecksumValidator{},
+			suffix: "checksums.txt",
+		},
+		{
+			v:      &PGPValidator{},
+			suffix: ".asc",
+		},
 	} {
 		want := test.suffix
 		got := test.v.Suffix()
@@ -134,3 +228,260 @@ func TestValidatorSuffix(t *testing.T) {
 		}
 	}
 }
+
+func TestChecksumValidator(t *testing.T) {
+	validator := &ChecksumValidator{}
+	checksums, err := ioutil.ReadFile("testdata/checksums.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.zip", "foo.tar.gz"} {
+		data, err := ioutil.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validator.ValidateAsset(name, data, checksums); err != nil {
+			t.Error(name, err)
+		}
+		if err := validator.Validate(data, checksums); err != nil {
+			t.Error(name, err)
+		}
+	}
+}
+
+func TestChecksumValidatorFail(t *testing.T) {
+	validator := &ChecksumValidator{}
+	checksums, err := ioutil.ReadFile("testdata/checksums.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.ValidateAsset("foo.tar.gz", data, checksums); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Error("Hash mismatch should be reported:", err)
+	}
+	if err := validator.ValidateAsset("foo.tar.xz", data, checksums); err == nil || !strings.Contains(err.Error(), "is not found") {
+		t.Error("Missing line should be reported:", err)
+	}
+	data, err = ioutil.ReadFile("testdata/foo.tar.xz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.Validate(data, checksums); err == nil {
+		t.Error("Hash not in checksums file should be reported")
+	}
+	if err := validator.Validate(data, []byte("broken")); err == nil || !strings.Contains(err.Error(), "invalid line") {
+		t.Error("Broken checksums file should be reported:", err)
+	}
+}
+
+func TestValidationAssetName(t *testing.T) {
+	if n := validationAssetName(&SHA2Validator{}, "foo.zip"); n != "foo.zip.sha256" {
+		t.Error("Unexpected validation asset name:", n)
+	}
+	if n := validationAssetName(&ChecksumValidator{}, "foo.zip"); n != "checksums.txt" {
+		t.Error("Unexpected validation asset name:", n)
+	}
+	if n := validationAssetName(&ChecksumValidator{UniqueFilename: "SHA256SUMS"}, "foo.zip"); n != "SHA256SUMS" {
+		t.Error("Unexpected validation asset name:", n)
+	}
+}
+
+func TestValidationAssetNames(t *testing.T) {
+	for _, tc := range []struct {
+		validator Validator
+		want      []string
+	}{
+		{&SHA2Validator{}, []string{"foo.zip.sha256", "foo.zip.sha256sum", "foo.zip.sha-256"}},
+		{&SHA2Validator{FileSuffixes: []string{".checksum"}}, []string{"foo.zip.checksum"}},
+		{&HashValidator{Hash: crypto.SHA512}, []string{"foo.zip.sha512", "foo.zip.sha512sum", "foo.zip.sha-512"}},
+		{&HashValidator{Hash: crypto.BLAKE2b_512}, []string{"foo.zip.b2", "foo.zip.b2sum"}},
+		{&HashValidator{Hash: crypto.SHA512, FileSuffix: ".hash"}, []string{"foo.zip.hash"}},
+		{&ECDSAValidator{}, []string{"foo.zip.sig"}},
+		{&ChecksumValidator{}, []string{"checksums.txt"}},
+	} {
+		if names := validationAssetNames(tc.validator, "foo.zip"); !reflect.DeepEqual(names, tc.want) {
+			t.Errorf("Wanted %v for %T but got %v", tc.want, tc.validator, names)
+		}
+	}
+	if s := (&SHA2Validator{FileSuffixes: []string{".checksum"}}).Suffix(); s != ".checksum" {
+		t.Error("Suffix should be the first of FileSuffixes:", s)
+	}
+}
+
+func newTestPGPEntity(t *testing.T) (*openpgp.Entity, []byte) {
+	e, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return e, buf.Bytes()
+}
+
+func TestPGPValidator(t *testing.T) {
+	e, pub := newTestPGPEntity(t)
+	validator, err := NewPGPValidator(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var armored bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&armored, e, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.Validate(data, armored.Bytes()); err != nil {
+		t.Error("Armored signature:", err)
+	}
+
+	var binary bytes.Buffer
+	if err := openpgp.DetachSign(&binary, e, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.Validate(data, binary.Bytes()); err != nil {
+		t.Error("Binary signature:", err)
+	}
+}
+
+func TestPGPValidatorFail(t *testing.T) {
+	e, _ := newTestPGPEntity(t)
+	_, other := newTestPGPEntity(t)
+	validator, err := NewPGPValidator(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, e, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	err = validator.Validate(data, sig.Bytes())
+	if err == nil {
+		t.Fatal("Signature by untrusted key should be rejected")
+	}
+	fp := fmt.Sprintf("%X", validator.KeyRing[0].PrimaryKey.Fingerprint)
+	if !strings.Contains(err.Error(), fp) {
+		t.Error("Error should contain the fingerprint of the key:", err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/foo.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashData, err := ioutil.ReadFile("testdata/foo.zip.sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := fmt.Sprintf("%x", sha256.Sum256(data))
+	expected := "0" + strings.Fields(string(hashData))[0][1:]
+
+	err = validateAsset(&SHA2Validator{}, "foo.zip", data, []byte(expected+"  foo.zip\n"))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatal("ValidationError should be returned:", err)
+	}
+	if verr.Validator != "sha2" || verr.AssetName != "foo.zip" || verr.Expected != expected || verr.Actual != actual || verr.Err != nil {
+		t.Errorf("Unexpected validation error: %#v", verr)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "expected=\""+expected) || !strings.Contains(msg, "got=\""+actual) {
+		t.Error("Error message should contain expected and actual hashes:", msg)
+	}
+
+	if err := (&SHA2Validator{}).Validate(data, []byte("short")); err == nil {
+		t.Error("Too short hash should be rejected")
+	}
+
+	pemData, err := ioutil.ReadFile("testdata/Test.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(pemData)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureData, err := ioutil.ReadFile("testdata/foo.zip.sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = validateAsset(&ECDSAValidator{PublicKey: cert.PublicKey.(*ecdsa.PublicKey)}, "bar.zip", []byte("broken"), signatureData)
+	verr, ok = err.(*ValidationError)
+	if !ok {
+		t.Fatal("ValidationError should be returned:", err)
+	}
+	if verr.Validator != "ecdsa" || verr.AssetName != "bar.zip" || verr.Expected != "" || verr.Err == nil {
+		t.Errorf("Unexpected validation error: %#v", verr)
+	}
+	if verr.Actual != fmt.Sprintf("%x", sha256.Sum256([]byte("broken"))) {
+		t.Error("Actual hash should be the hash of the asset:", verr.Actual)
+	}
+}
+
+func TestValidationErrorForEveryFailure(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/foo.tar.xz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksums, err := ioutil.ReadFile("testdata/checksums.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData, err := ioutil.ReadFile("testdata/Test.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(pemData)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaValidator := &ECDSAValidator{PublicKey: cert.PublicKey.(*ecdsa.PublicKey)}
+
+	for _, tc := range []struct {
+		what      string
+		validator Validator
+		name      string
+		asset     []byte
+		wantName  string
+		wantMsg   string
+	}{
+		{"checksum missing for asset", &ChecksumValidator{}, "foo.tar.xz", checksums, "checksum", "is not found"},
+		{"checksum missing without name", &ChecksumValidator{}, "", checksums, "checksum", "is not found"},
+		{"broken checksums file", &ChecksumValidator{}, "foo.tar.xz", []byte("broken"), "checksum", "invalid line"},
+		{"broken ecdsa signature", ecdsaValidator, "foo.tar.xz", []byte("broken"), "ecdsa", "failed to unmarshal"},
+		{"unavailable hash function", &HashValidator{Hash: crypto.MD4}, "foo.tar.xz", []byte("0"), "hash", "not available"},
+	} {
+		t.Run(tc.what, func(t *testing.T) {
+			err := validateAsset(tc.validator, tc.name, data, tc.asset)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatal("ValidationError should be returned:", err)
+			}
+			if verr.Validator != tc.wantName || verr.AssetName != tc.name || verr.Err == nil {
+				t.Errorf("Unexpected validation error: %#v", verr)
+			}
+			if !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Error("Unexpected error message:", err)
+			}
+		})
+	}
+}